@@ -0,0 +1,139 @@
+package basicblock
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// An address is what users hand out to get paid: the SignatureScheme and the hash of a public key, which PayToAddress locks to, written in Base58Check as in Bitcoin. That is a version byte telling the network apart, the scheme, the hash and a checksum, in an alphabet without the easily confused characters 0, O, I and l. A mistyped address fails its checksum instead of sending coins nowhere, and an address of another network or of an unknown scheme is rejected.
+
+// Address is the scheme and the hash of an encoded public key, see PubKeyHash.
+type Address struct {
+	Scheme SignatureScheme
+	Hash   [32]byte
+}
+
+// addressChecksumSize is the number of bytes of the double SHA-256 of version and hash appended to an address.
+const addressChecksumSize = 4
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// NewAddress returns the address of pub, a key of any SignatureScheme.
+func NewAddress(pub crypto.PublicKey) Address {
+	scheme, _ := SchemeOf(pub)
+	return Address{Scheme: scheme, Hash: PubKeyHash(pub)}
+}
+
+// addressSize is the length of a decoded address: version, scheme, hash and checksum.
+const addressSize = 1 + 1 + 32 + addressChecksumSize
+
+// String encodes a in Base58Check with the address version of Params.
+func (a Address) String() string {
+	payload := append([]byte{Params.AddressVersion, byte(a.Scheme)}, a.Hash[:]...)
+	return base58Encode(append(payload, addressChecksum(payload)...))
+}
+
+// ParseAddress decodes an address of the network of Params, checking its checksum and version.
+func ParseAddress(s string) (Address, error) {
+	var a Address
+	b, err := base58Decode(s)
+	if err != nil {
+		return a, TxError{fmt.Sprintf("invalid address: %v", err), Generic}
+	}
+	if len(b) != addressSize {
+		return a, TxError{fmt.Sprintf("invalid address: %d bytes long instead of %d", len(b), addressSize), Generic}
+	}
+	payload, checksum := b[:len(b)-addressChecksumSize], b[len(b)-addressChecksumSize:]
+	if !bytes.Equal(checksum, addressChecksum(payload)) {
+		return a, TxError{"invalid address: checksum does not match, it was mistyped", Generic}
+	}
+	if payload[0] != Params.AddressVersion {
+		return a, TxError{fmt.Sprintf("address is for another network: version %#x instead of %#x", payload[0], Params.AddressVersion), Generic}
+	}
+	if a.Scheme = SignatureScheme(payload[1]); !a.Scheme.valid() {
+		return a, TxError{fmt.Sprintf("invalid address: unknown signature scheme %d", payload[1]), Generic}
+	}
+	copy(a.Hash[:], payload[2:])
+	return a, nil
+}
+
+// payToAddressSize is the length of a PayToAddress lock.
+const payToAddressSize = 39
+
+// PayToAddress returns the standard lock to a: DUP <scheme> CHECKSCHEMEVERIFY SHA256 <hash> EQUALVERIFY CHECKSIG. It is spent with <sig> <pubkey>, so the key itself is only revealed when the coins are spent, and the lock says which scheme that key has to be of.
+func PayToAddress(a Address) Script {
+	return new(ScriptBuilder).AddOp(OpDup).AddInt(int64(a.Scheme)).AddOp(OpCheckSchemeVerify).AddOp(OpSHA256).AddData(a.Hash[:]).AddOp(OpEqualVerify).AddOp(OpCheckSig).Script()
+}
+
+// Address returns the address s pays to if it is a PayToAddress lock.
+func (s Script) Address() (Address, bool) {
+	var a Address
+	if len(s) != payToAddressSize || s[0] != byte(OpDup) || smallInt(Opcode(s[1])) <= 0 || s[2] != byte(OpCheckSchemeVerify) || s[3] != byte(OpSHA256) || s[4] != 32 || s[37] != byte(OpEqualVerify) || s[38] != byte(OpCheckSig) {
+		return a, false
+	}
+	a.Scheme = SignatureScheme(smallInt(Opcode(s[1])))
+	copy(a.Hash[:], s[5:37])
+	return a, true
+}
+
+// EncodePublicKey returns the hex of MarshalPublicKey, which is how keys, as opposed to addresses, are passed around outside of the node. Multisig locks need them.
+func EncodePublicKey(pub crypto.PublicKey) string {
+	b, err := MarshalPublicKey(pub)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// DecodePublicKey parses a public key produced by EncodePublicKey.
+func DecodePublicKey(s string) (crypto.PublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, TxError{fmt.Sprintf("public key is not hex: %v", err), Generic}
+	}
+	return ParsePublicKey(b)
+}
+
+func addressChecksum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	return second[:addressChecksumSize]
+}
+
+// base58Encode encodes b as a number in base 58, with a leading 1 for every leading zero byte.
+func base58Encode(b []byte) string {
+	var res []byte
+	n := new(big.Int).SetBytes(b)
+	radix, digit := big.NewInt(58), new(big.Int)
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, digit)
+		res = append(res, base58Alphabet[digit.Int64()])
+	}
+	for i := 0; i < len(b) && b[i] == 0; i++ {
+		res = append(res, base58Alphabet[0])
+	}
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return string(res)
+}
+
+// base58Decode decodes what base58Encode returns.
+func base58Decode(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		digit := strings.IndexRune(base58Alphabet, c)
+		if digit < 0 {
+			return nil, fmt.Errorf("%q is not a base58 character", c)
+		}
+		n.Mul(n, radix).Add(n, big.NewInt(int64(digit)))
+	}
+	zeros := len(s) - len(strings.TrimLeft(s, base58Alphabet[:1]))
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
@@ -0,0 +1,107 @@
+package basicblock
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestBase58(t *testing.T) {
+	// Vectors from Bitcoin Core's base58_encode_decode.json.
+	for _, tt := range []struct{ hex, base58 string }{
+		{"", ""},
+		{"61", "2g"},
+		{"626262", "a3gV"},
+		{"516b6fcd0f", "ABnLTmg"},
+		{"00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+		{"00000000000000000000", "1111111111"},
+	} {
+		b, _ := hex.DecodeString(tt.hex)
+		if got := base58Encode(b); got != tt.base58 {
+			t.Errorf("base58Encode(%s) = %q, want %q", tt.hex, got, tt.base58)
+		}
+		got, err := base58Decode(tt.base58)
+		if err != nil || !bytes.Equal(got, b) {
+			t.Errorf("base58Decode(%q) = %x, %v, want %s", tt.base58, got, err, tt.hex)
+		}
+	}
+	if _, err := base58Decode("0OIl"); err == nil {
+		t.Errorf("base58Decode accepted characters outside the alphabet")
+	}
+}
+
+func TestAddress(t *testing.T) {
+	setParams(t, MainParams)
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a := NewAddress(priv.PublicKey)
+	s := a.String()
+	if !strings.HasPrefix(s, "2") {
+		t.Errorf("main network address %s does not start with 2", s)
+	}
+	if got, err := ParseAddress(s); err != nil || got != a {
+		t.Errorf("ParseAddress(%s) = %x, %v, want %x", s, got, err, a)
+	}
+	if got, ok := PayToAddress(a).Address(); !ok || got != a {
+		t.Errorf("Address of PayToAddress = %x, %v, want %x", got, ok, a)
+	}
+	if !PayToPubKeyHash(priv.PublicKey).Equal(PayToAddress(a)) {
+		t.Errorf("PayToPubKeyHash differs from PayToAddress of the key's address")
+	}
+
+	// Every single character typo is caught by the checksum.
+	for i := range s {
+		for _, c := range base58Alphabet {
+			if byte(c) == s[i] {
+				continue
+			}
+			typo := s[:i] + string(c) + s[i+1:]
+			if _, err := ParseAddress(typo); err == nil {
+				t.Fatalf("ParseAddress accepted %s, a typo of %s", typo, s)
+			}
+		}
+	}
+	unknown := append([]byte{Params.AddressVersion, 9}, a.Hash[:]...)
+	unknownScheme := base58Encode(append(unknown, addressChecksum(unknown)...))
+	for _, invalid := range []string{"", "2", s[:len(s)-1], s + "2", "0" + s[1:], unknownScheme} {
+		if _, err := ParseAddress(invalid); err == nil {
+			t.Errorf("ParseAddress(%q) succeeded", invalid)
+		}
+	}
+
+	Params = TestParams
+	test := a.String()
+	if !strings.HasPrefix(test, "4") {
+		t.Errorf("test network address %s does not start with 4", test)
+	}
+	if _, err := ParseAddress(s); err == nil {
+		t.Errorf("test network accepted main network address %s", s)
+	}
+	if got, err := ParseAddress(test); err != nil || got != a {
+		t.Errorf("ParseAddress(%s) = %x, %v, want %x", test, got, err, a)
+	}
+}
+
+func TestPublicKeyEncoding(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		b, err := MarshalPublicKey(priv.PublicKey)
+		if err != nil || len(b) != 33 {
+			t.Fatalf("MarshalPublicKey = %x, %v, want 33 bytes", b, err)
+		}
+		pub, err := DecodePublicKey(EncodePublicKey(priv.PublicKey))
+		if k, ok := pub.(*ecdsa.PublicKey); err != nil || !ok || !k.Equal(&priv.PublicKey) {
+			t.Errorf("DecodePublicKey(EncodePublicKey(pub)) = %v, want the key back", err)
+		}
+	}
+	if _, err := ParsePublicKey(make([]byte, 33)); err == nil {
+		t.Errorf("ParsePublicKey accepted a key that is not on the curve")
+	}
+	p224, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if _, err := MarshalPublicKey(p224.PublicKey); err == nil {
+		t.Errorf("MarshalPublicKey accepted a P-224 key")
+	}
+}
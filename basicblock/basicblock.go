@@ -2,12 +2,13 @@ package basicblock
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
 	"log"
-	"math"
+	"math/big"
 	"time"
+
+	"github.com/chronologos/naivecoin/logging"
 )
 
 // BlockGenerationInterval in seconds, defines how often a block should be found. (in Bitcoin this value is 10 minutes)
@@ -18,40 +19,52 @@ const DifficultyAdjustmentInterval int = 2
 
 var Difficulty int32 = 2
 
+var chainLog = logging.Logger(logging.Chain)
+
 // GenesisBlock is the very first block, duh! Package globals are usually bad!
 var GenesisBlock BasicBlock
 
 func init() {
 	here, err := time.LoadLocation("UTC")
 	if err != nil {
-		debug("error!") // TODO
+		chainLog.Error("could not load UTC location", "err", err)
 		return
 	}
 	GenesisBlock = BasicBlock{
-		Index:     1,
-		Timestamp: time.Date(1, time.January, 1, 1, 1, 1, 1, here),
-		// previousHash takes on weird default value of "01000000"...
-		Data:       []byte("this is the genesis block"),
-		Difficulty: 2,
+		BlockHeader: BlockHeader{
+			Version:   BlockVersion,
+			Index:     1,
+			Timestamp: time.Date(1, time.January, 1, 1, 1, 1, 1, here),
+			// previousHash takes on weird default value of "01000000"...
+			Difficulty: 2,
+		},
+		Data: []byte("this is the genesis block"),
 	}
+	GenesisBlock.PayloadRoot = GenesisBlock.payloadRoot()
+	GenesisBlock.Hash = GenesisBlock.calculateHash()
 }
 
-// BasicBlock - Implementation of a block of cryptocurrency!
+// BasicBlock - Implementation of a block of cryptocurrency! The header carries the proof of work, the body is Data and Transactions.
 type BasicBlock struct {
-	Index        int32
-	Hash         [32]byte
-	PreviousHash [32]byte
-	Timestamp    time.Time
+	BlockHeader
 	Data         []byte
-	Difficulty   int32
-	Nonce        []byte
+	Transactions []Transaction
 }
 
 // BlockChain basic implementation
 type BlockChain []BasicBlock
 
 func (bb *BasicBlock) String() string {
-	return fmt.Sprintf("(Index: %d, Hash: %x, PreviousHash: %x, Timestamp: %s, Data: %x, Difficulty: %d, Nonce %x)", bb.Index, bb.Hash, bb.PreviousHash, bb.Timestamp.Format(time.RFC3339), bb.Data, bb.Difficulty, bb.Nonce)
+	return fmt.Sprintf("(Version: %d, Index: %d, Hash: %x, PreviousHash: %x, PayloadRoot: %x, Timestamp: %s, Data: %x, Difficulty: %d, Nonce %x, Transactions: %d)", bb.Version, bb.Index, bb.Hash, bb.PreviousHash, bb.PayloadRoot, bb.Timestamp.Format(time.RFC3339), bb.Data, bb.Difficulty, bb.Nonce, len(bb.Transactions))
+}
+
+// Headers returns the headers of bc.
+func (bc BlockChain) Headers() HeaderChain {
+	hc := make(HeaderChain, len(bc))
+	for i := range bc {
+		hc[i] = bc[i].BlockHeader
+	}
+	return hc
 }
 
 func (bc BlockChain) String() string {
@@ -63,15 +76,15 @@ func (bc BlockChain) String() string {
 }
 
 func (bb *BasicBlock) deepEqual(bb2 *BasicBlock) bool {
-	debug("deepEqual returned %t\n", len(bb.Data) == len(bb2.Data))
 	if bb.Index == bb2.Index &&
 		bb.Hash == bb2.Hash &&
 		bb.PreviousHash == bb2.PreviousHash &&
+		bb.PayloadRoot == bb2.PayloadRoot &&
 		bb.Timestamp.Equal(bb2.Timestamp) && // works across timezones
 		len(bb.Data) == len(bb2.Data) {
 		for i, b := range bb.Data {
 			if b != bb2.Data[i] {
-				debug("deepEqual data byte %d different\n", i)
+				chainLog.Debug("deepEqual: data byte different", "byte", i)
 				return false
 			}
 		}
@@ -92,95 +105,147 @@ func deepEqual(bc1, bc2 []BasicBlock) bool {
 	return true
 }
 
-func (bb *BasicBlock) calculateHash() [32]byte {
-	h := sha256.New()
-	var hashInput bytes.Buffer
-	var buf bytes.Buffer
-	err := binary.Write(&buf, binary.LittleEndian, bb.Index)
-	if err != nil {
-		log.Fatalf("Int to binary conversion failed with error %v", err)
+func (bb *BasicBlock) payloadLeaves() [][32]byte {
+	leaves := [][32]byte{merkleLeaf(merkleDataPrefix, bb.Data)}
+	for _, tx := range bb.Transactions {
+		leaves = append(leaves, tx.merkleLeaf())
 	}
-	hashInput.Write(buf.Bytes())
-
-	var prevHash []byte
-	prevHash = bb.PreviousHash[:]
-	hashInput.Write(prevHash)
-
-	debug("previoushash: %x\n", hashInput.Bytes())
-	timeStr := bb.Timestamp.Format(time.RFC3339)
-	hashInput.WriteString(timeStr)
+	return leaves
+}
 
-	hashInput.Write(bb.Data)
-	debug("in: %x\n", hashInput.Bytes())
+// payloadRoot commits to the body: the Merkle root over the hash of Data followed by the witness hashes of the transactions, which cover their unlocking scripts as well as their ids. Data and transaction leaves are hashed under different prefixes, so Data can not pose as a transaction.
+func (bb *BasicBlock) payloadRoot() [32]byte {
+	return MerkleRoot(bb.payloadLeaves())
+}
 
-	hashInput.Write(bb.Nonce)
+// TxInclusionProof lets a client that only has headers check that Transaction is the leaf at Index, counting the Data leaf as 0, of the block with hash BlockHash.
+type TxInclusionProof struct {
+	Transaction Transaction
+	BlockHash   [32]byte
+	Index       int
+	Proof       MerkleProof
+}
 
-	_, err = h.Write(hashInput.Bytes())
-	if err != nil {
-		log.Fatalln("sha256 failed")
+// ProveTransaction returns the inclusion proof of the transaction with the given id, if the block contains it.
+func (bb *BasicBlock) ProveTransaction(id [32]byte) (TxInclusionProof, bool) {
+	for i, tx := range bb.Transactions {
+		if tx.id == id {
+			return TxInclusionProof{tx, bb.Hash, i + 1, NewMerkleProof(bb.payloadLeaves(), i+1)}, true
+		}
 	}
+	return TxInclusionProof{}, false
+}
 
-	res := h.Sum(nil)
-	var ret [32]byte
-	for i, d := range res {
-		ret[i] = d
+// Verify checks that the transaction's id matches its content and that the proof leads from its leaf at Index, which may not be the Data leaf, to the PayloadRoot of header.
+func (p *TxInclusionProof) Verify(header *BlockHeader) bool {
+	if p.Index < 1 || p.Proof.Index() != p.Index || p.Index>>len(p.Proof) != 0 {
+		return false
 	}
-	return ret
+	return p.BlockHash == header.Hash && p.Transaction.getID() == p.Transaction.id && p.Proof.Verify(p.Transaction.merkleLeaf(), header.PayloadRoot)
 }
 
-// IsValid makes sure that the current BasicBlock has the correct Hash and PreviousHash.
+// IsValid makes sure that the current BasicBlock has a valid header following prev, that its body matches the header's PayloadRoot, and that it stays within the consensus limits.
 func (bb *BasicBlock) IsValid(prev *BasicBlock) bool {
-	computedHash := bb.calculateHash()
-	return bb.PreviousHash == prev.Hash && computedHash == bb.Hash && hashMatchesDifficulty(bb.Difficulty, bb.Hash[:]) && bb.isValidTimestamp(prev)
+	return bb.BlockHeader.IsValid(&prev.BlockHeader) && bb.payloadRoot() == bb.PayloadRoot && bb.withinLimits()
 }
 
-// IsValid makes sure that the entire blockChain is valid
+// IsValid makes sure that the entire blockChain is valid, with timestamps checked against NetworkClock.
 func (bc BlockChain) IsValid() bool {
+	return bc.IsValidAt(NetworkClock.Now())
+}
+
+// IsValidAt is IsValid at the network-adjusted time now, for a node that keeps its own NetworkTime.
+func (bc BlockChain) IsValidAt(now time.Time) bool {
 	if len(bc) < 1 {
-		debug("IsValidBasicBlockchain: Length of blockchain is 0.\n")
+		chainLog.Debug("invalid blockchain: length is 0")
 		return false
 	}
 	if !bc[0].deepEqual(&GenesisBlock) {
-		debug("IsValidBasicBlockchain: Wrong genesis block.\n")
+		chainLog.Debug("invalid blockchain: wrong genesis block")
 		return false
 	}
+	headers := bc.Headers()
 	for i, blk := range bc {
 		if i == 0 { // genesis block is already verified.
 			continue
 		} else {
 			if !blk.IsValid(&bc[i-1]) {
-				debug("IsValidBasicBlockchain: Block %d was invalid.\n", i)
+				chainLog.Debug("invalid blockchain: invalid block", "index", blk.Index)
+				return false
+			}
+			if !blk.isValidTimestamp(headers[:i], now) {
+				chainLog.Debug("invalid blockchain: invalid timestamp", "index", blk.Index, "timestamp", blk.Timestamp)
 				return false
 			}
 		}
 	}
+	if _, err := bc.UnspentTxOuts(); err != nil {
+		chainLog.Debug("invalid blockchain", "err", err)
+		return false
+	}
 	return true
 }
 
-// isValidTimestamp is used to mitigate attacks in which a false timestamp is introduced in order to manipulate the difficulty. A block is valid, if the timestamp is at most 1 min in the future from the time we perceive. A block in the chain is valid, if the timestamp is at most 1 min in the past of the previous block.
-func (bb *BasicBlock) isValidTimestamp(prev *BasicBlock) bool {
-	return bb.Timestamp.After(prev.Timestamp.Add(-60*time.Second)) && bb.Timestamp.Before(time.Now().Add(60*time.Second))
+// UnspentTxOuts replays the transactions of every block and returns the unspent outputs at the tip. It fails if a block contains invalid transactions.
+func (bc BlockChain) UnspentTxOuts() ([]UnspentTxOut, error) {
+	var aUnspentTxOuts []UnspentTxOut
+	headers := bc.Headers()
+	for i, blk := range bc {
+		var err error
+		aUnspentTxOuts, err = processTransactions(blk.Transactions, aUnspentTxOuts, BlockContext{blk.Index - GenesisBlock.Index, headers[:i].MedianTimePast()})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return aUnspentTxOuts, nil
+}
+
+// NextHeight is the height of the block that would extend bc. Transactions entering the pool are checked against it.
+func (bc BlockChain) NextHeight() int32 {
+	return bc[len(bc)-1].Index + 1 - GenesisBlock.Index
+}
+
+// NextTimestamp is the timestamp of a block mined on bc at time now: now, but at least a second past the median time past of bc. A peer whose clock runs ahead of ours, within MaxFutureDrift, can relay a valid chain whose median time past is later than our clock, and a block stamped with our clock would not be valid on it.
+func (bc BlockChain) NextTimestamp(now time.Time) time.Time {
+	if earliest := bc[max(0, len(bc)-MedianTimeSpan):].Headers().MedianTimePast().Add(time.Second); now.Before(earliest) {
+		return earliest
+	}
+	return now
+}
+
+// FindTransaction looks up the transaction with the given id by scanning bc, and returns it together with where it is.
+func (bc BlockChain) FindTransaction(id [32]byte) (Transaction, TxLocation, bool) {
+	for _, blk := range bc {
+		for j, tx := range blk.Transactions {
+			if tx.id == id {
+				return tx, TxLocation{BlockHash: blk.Hash, BlockIndex: blk.Index, Position: j}, true
+			}
+		}
+	}
+	return Transaction{}, TxLocation{}, false
 }
 
 // PossiblyReplace accepts a "contender blockchain", if the contender is valid AND has a larger cumulative difficulty than the blockchain we currently have, we replace it. Assumption: orig is valid.
 func PossiblyReplace(orig BlockChain, next BlockChain) []BasicBlock {
-	if !next.IsValid() {
+	return PossiblyReplaceAt(orig, next, NetworkClock.Now())
+}
+
+// PossiblyReplaceAt is PossiblyReplace validating next at the network-adjusted time now.
+func PossiblyReplaceAt(orig, next BlockChain, now time.Time) []BasicBlock {
+	if !next.IsValidAt(now) {
 		return orig
 	}
-	var cumOrig int32
-	var cumNext int32
-	for _, x := range orig {
-		cumOrig += int32(math.Pow(2, float64(x.Difficulty)))
-	}
-	for _, x := range next {
-		cumNext += int32(math.Pow(2, float64(x.Difficulty)))
-	}
-	if cumOrig > cumNext {
+	if CumulativeDifficulty(orig).Cmp(CumulativeDifficulty(next)) > 0 {
 		return orig
 	}
 	return next
 }
 
+// CumulativeDifficulty sums 2^difficulty over all blocks, which is the expected amount of work that went into the chain. Only the headers are needed for this.
+func CumulativeDifficulty(bc BlockChain) *big.Int {
+	return bc.Headers().CumulativeDifficulty()
+}
+
 func getConseqZeroes(hash byte) int32 {
 	b := byte(hash)
 	if b&255 == 0 {
@@ -214,28 +279,46 @@ func getConseqZeroes(hash byte) int32 {
 func hashMatchesDifficulty(difficulty int32, hash []byte) bool {
 	zeroes := int32(0)
 	for _, x := range hash {
-		debug("x is %08b\n", x)
 		cz := getConseqZeroes(x)
 		zeroes += cz
 		if cz != 8 {
 			break
 		}
 	}
-	debug("hmd: hash had = %d, difficulty : %d\n\n", zeroes, difficulty)
 	return zeroes >= difficulty
 }
 
 // FindBlock finds the next block with the expected difficulty.
 func (bb *BasicBlock) FindBlock(data []byte) BasicBlock {
+	return bb.FindBlockWithTransactions(data, nil)
+}
+
+// FindBlockWithTransactions finds the next block with the expected difficulty that includes txs. The first transaction should be the coinbase.
+func (bb *BasicBlock) FindBlockWithTransactions(data []byte, txs []Transaction) BasicBlock {
+	return bb.FindBlockAtDifficulty(data, txs, Difficulty)
+}
+
+// FindBlockAtDifficulty is FindBlockWithTransactions for a caller that tracks the difficulty itself rather than through the Difficulty global, like a node sharing its process with others.
+func (bb *BasicBlock) FindBlockAtDifficulty(data []byte, txs []Transaction, difficulty int32) BasicBlock {
+	return bb.FindBlockAt(data, txs, difficulty, NetworkClock.Now())
+}
+
+// FindBlockAt is FindBlockAtDifficulty for a block stamped with timestamp, which a miner gets from NextTimestamp of its chain.
+func (bb *BasicBlock) FindBlockAt(data []byte, txs []Transaction, difficulty int32, timestamp time.Time) BasicBlock {
 	nonceInt := int32(0) // TODO this is a problem! we may not always be able to find a solution with a limited number of bits
 	result := &BasicBlock{
-		Index:        bb.Index + 1,
-		PreviousHash: bb.Hash,
-		Timestamp:    time.Now(),
-		Difficulty:   Difficulty,
-		Nonce:        []byte{0},
+		BlockHeader: BlockHeader{
+			Version:      BlockVersion,
+			Index:        bb.Index + 1,
+			PreviousHash: bb.Hash,
+			Timestamp:    timestamp,
+			Difficulty:   difficulty,
+			Nonce:        []byte{0},
+		},
 		Data:         data,
+		Transactions: txs,
 	}
+	result.PayloadRoot = result.payloadRoot()
 	for {
 		var buf bytes.Buffer
 		err := binary.Write(&buf, binary.LittleEndian, nonceInt)
@@ -244,10 +327,8 @@ func (bb *BasicBlock) FindBlock(data []byte) BasicBlock {
 		}
 
 		result.Nonce = buf.Bytes()
-		debug("n: %x\n", result.Nonce)
 
 		hash := result.calculateHash()
-		debug("h: %08b\n", hash)
 
 		if hashMatchesDifficulty(result.Difficulty, hash[:]) {
 			result.Hash = hash
@@ -275,13 +356,22 @@ func getAdjustedDifficulty(latestBlock BasicBlock, bc BlockChain) (int32, error)
 	timeExpected := BlockGenerationInterval * DifficultyAdjustmentInterval
 	timeTaken := latestBlock.Timestamp.Second() - prevAdjustmentBlock.Timestamp.Second()
 	if timeTaken < timeExpected/2 {
-		log.Println("difficulty up.")
+		chainLog.Info("difficulty up", "difficulty", prevAdjustmentBlock.Difficulty+1)
 		return prevAdjustmentBlock.Difficulty + 1, nil
 	} else if timeTaken > timeExpected*2 {
-		log.Println("difficulty down.")
+		chainLog.Info("difficulty down", "difficulty", prevAdjustmentBlock.Difficulty-1)
 		return prevAdjustmentBlock.Difficulty - 1, nil
 	} else {
-		log.Println("difficulty unchanged.")
+		chainLog.Debug("difficulty unchanged", "difficulty", prevAdjustmentBlock.Difficulty)
 		return prevAdjustmentBlock.Difficulty, nil
 	}
 }
+
+// ForkPoint returns how many leading blocks orig and next have in common. Blocks of orig past that point are the ones that get disconnected when switching to next.
+func ForkPoint(orig, next BlockChain) int {
+	i := 0
+	for i < len(orig) && i < len(next) && orig[i].Hash == next[i].Hash && orig[i].Index == next[i].Index {
+		i++
+	}
+	return i
+}
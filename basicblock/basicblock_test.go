@@ -1,13 +1,11 @@
 package basicblock
 
 import (
+	"math/big"
 	"testing"
 	"time"
 )
 
-var TestBlock1 = GenesisBlock.FindBlock([]byte{})
-var TestBlock2 = TestBlock1.FindBlock([]byte{})
-
 func TestGetConseqZeroes(t *testing.T) {
 	if getConseqZeroes(byte(0)) != 8 {
 		t.Fail()
@@ -48,10 +46,7 @@ func TestEmptyBlockchain(t *testing.T) {
 }
 
 func TestInvalidExtraBlock(t *testing.T) {
-	blockChain := BlockChain{GenesisBlock}
-	for i := 0; i < 5; i++ {
-		blockChain = append(blockChain, blockChain[len(blockChain)-1].FindBlock([]byte{}))
-	}
+	blockChain := newHarness(t).chain(5)
 	blockChain = append(blockChain, BasicBlock{})
 	if blockChain.IsValid() {
 		t.Fail()
@@ -59,10 +54,7 @@ func TestInvalidExtraBlock(t *testing.T) {
 }
 
 func TestInvalidGenesisBlock(t *testing.T) {
-	blockChain := BlockChain{GenesisBlock}
-	for i := 0; i < 5; i++ {
-		blockChain = append(blockChain, blockChain[len(blockChain)-1].FindBlock([]byte{}))
-	}
+	blockChain := newHarness(t).chain(5)
 	blockChain[0].Data = []byte("DEADBEEF")
 	if blockChain.IsValid() {
 		t.Fail()
@@ -70,6 +62,9 @@ func TestInvalidGenesisBlock(t *testing.T) {
 }
 
 func TestBlockValidation(t *testing.T) {
+	h := newHarness(t)
+	blockChain := h.chain(2)
+	TestBlock1, TestBlock2 := blockChain[1], blockChain[2]
 	TestBlock2HashWrong := TestBlock2
 	TestBlock2HashWrong.Hash = [32]byte{}
 	TestBlock1HashWrong := TestBlock1
@@ -77,10 +72,12 @@ func TestBlockValidation(t *testing.T) {
 	TestBlock2MutatedData := TestBlock2
 	TestBlock2MutatedData.Data = []byte("DEADBEEF")
 	TestBlock2TimestampTooEarly := TestBlock2
-	TestBlock2TimestampTooEarly.Timestamp = TestBlock2TimestampTooEarly.Timestamp.Add(-62 * time.Second)
+	TestBlock2TimestampTooEarly.Timestamp = TestBlock1.Timestamp.Add(-1 * time.Second)
+	TestBlock2TimestampTooLate := TestBlock2
+	TestBlock2TimestampTooLate.Timestamp = h.clock.Now().Add(MaxFutureDrift + time.Second)
 	TestBlock2TimestampOk := TestBlock2
-	TestBlock2TimestampOk.Timestamp = TestBlock2TimestampOk.Timestamp.Add(-5 * time.Second)
-	// log.Printf("invalid timestamps: %s and %s \n", TestBlock2TimestampOk.Timestamp.String(), TestBlock1.Timestamp.String())
+	TestBlock2TimestampOk.Timestamp = TestBlock1.Timestamp
+	prevs := HeaderChain{GenesisBlock.BlockHeader, TestBlock1.BlockHeader}
 
 	if !TestBlock2.IsValid(&TestBlock1) {
 		t.Fail()
@@ -94,36 +91,64 @@ func TestBlockValidation(t *testing.T) {
 	if TestBlock2MutatedData.IsValid(&TestBlock1) {
 		t.Fail()
 	}
-	if TestBlock2TimestampTooEarly.isValidTimestamp(&TestBlock1) {
+	if TestBlock2TimestampTooEarly.isValidTimestamp(prevs, NetworkClock.Now()) {
 		t.Fail()
 	}
-	if !TestBlock2TimestampOk.isValidTimestamp(&TestBlock1) {
+	if TestBlock2TimestampTooLate.isValidTimestamp(prevs, NetworkClock.Now()) {
+		t.Fail()
+	}
+	if !TestBlock2TimestampOk.isValidTimestamp(prevs, NetworkClock.Now()) {
 		t.Fail()
 	}
 }
 
 func TestValidBlockchain(t *testing.T) {
-	blockChain := BlockChain{GenesisBlock}
-	for i := 0; i < 5; i++ {
-		blockChain = append(blockChain, blockChain[len(blockChain)-1].FindBlock([]byte{}))
-	}
+	blockChain := newHarness(t).chain(5)
 	if !blockChain.IsValid() {
 		t.Fail()
 	}
 }
 
 func TestBlockchainReplace(t *testing.T) {
-	blockChainShort := []BasicBlock{GenesisBlock}
-	blockChainLong := []BasicBlock{GenesisBlock}
-	for i := 0; i < 3; i++ {
-		blockChainShort = append(blockChainShort, blockChainShort[len(blockChainShort)-1].FindBlock([]byte{}))
-	}
-	for i := 0; i < 5; i++ {
-		blockChainLong = append(blockChainLong, blockChainLong[len(blockChainLong)-1].FindBlock([]byte{}))
-	}
+	h := newHarness(t)
+	blockChainShort := h.chain(3)
+	blockChainLong := h.extend(BlockChain{GenesisBlock}, 5, "long")
 
 	res := PossiblyReplace(blockChainShort, blockChainLong)
 	if !deepEqual(res, blockChainLong) {
 		t.Fail()
 	}
+	res = PossiblyReplace(blockChainLong, blockChainShort)
+	if !deepEqual(res, blockChainLong) {
+		t.Fail()
+	}
+}
+
+func TestForkPoint(t *testing.T) {
+	h := newHarness(t)
+	blockChain := h.chain(3)
+	fork := h.extend(blockChain[:2], 1, "fork")
+
+	if ForkPoint(blockChain, blockChain) != 4 {
+		t.Fail()
+	}
+	if ForkPoint(blockChain, fork) != 2 {
+		t.Fail()
+	}
+	if ForkPoint(blockChain[:3], blockChain) != 3 {
+		t.Fail()
+	}
+}
+
+func TestCumulativeDifficulty(t *testing.T) {
+	blockChain := BlockChain{GenesisBlock, BasicBlock{BlockHeader: BlockHeader{Difficulty: 3}}, BasicBlock{BlockHeader: BlockHeader{Difficulty: 0}}}
+	if CumulativeDifficulty(blockChain).Cmp(big.NewInt(4+8+1)) != 0 {
+		t.Fail()
+	}
+	// Realistic difficulties overflow 32 and 64 bit integers.
+	hard := HeaderChain{{Difficulty: 40}, {Difficulty: 40}, {Difficulty: 70}}
+	want := new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 41), new(big.Int).Lsh(big.NewInt(1), 70))
+	if got := hard.CumulativeDifficulty(); got.Cmp(want) != 0 {
+		t.Errorf("CumulativeDifficulty = %v, want %v", got, want)
+	}
 }
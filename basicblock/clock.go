@@ -0,0 +1,171 @@
+package basicblock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and makes tickers. Consensus code and the server go through LocalClock instead of the time package, so that tests can control time.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the part of time.Ticker that a Clock hands out.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+type systemTicker struct{ t *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.t.C }
+
+func (t systemTicker) Stop() { t.t.Stop() }
+
+// SystemClock is the real time.
+var SystemClock Clock = systemClock{}
+
+// LocalClock is our own clock, before any adjustment by the network. Tests replace it with a ManualClock to get deterministic timestamps.
+var LocalClock = SystemClock
+
+// ManualClock is a Clock that only moves when Advance is called. Its tickers fire during Advance, and like time.Ticker drop ticks nobody is waiting for.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*manualTicker
+}
+
+type manualTicker struct {
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time // guarded by the clock's mu, as is stopped
+	stopped bool
+	clock   *ManualClock
+}
+
+// NewManualClock returns a ManualClock standing at now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now implements Clock.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker implements Clock.
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for ManualClock.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &manualTicker{c: make(chan time.Time, 1), period: d, next: c.now.Add(d), clock: c}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward by d and fires the tickers that came due.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		for !t.stopped && !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+func (t *manualTicker) C() <-chan time.Time { return t.c }
+
+func (t *manualTicker) Stop() {
+	t.clock.mu.Lock()
+	t.stopped = true
+	t.clock.mu.Unlock()
+}
+
+// MaxTimeAdjustment bounds how far the network time may pull us away from our own clock. If peers disagree with us by more than this, our clock is probably fine and theirs are not, so we do not adjust at all. It is half of MaxFutureDrift, so that a node adjusted as far as it goes still accepts blocks stamped with the true time (Bitcoin uses 70 minutes against 2 hours).
+var MaxTimeAdjustment = MaxFutureDrift / 2
+
+// MaxTimeSamples is how many peers' clocks NetworkTime keeps. Later peers are not sampled, so that whoever opens many connections can not take over the median (as in Bitcoin).
+var MaxTimeSamples = 200
+
+// NetworkTime is our clock corrected by the median offset of our peers' clocks, which they report when they connect.
+type NetworkTime struct {
+	clock   Clock // our own clock, LocalClock if nil
+	mu      sync.Mutex
+	offsets map[string]time.Duration // by peer host
+}
+
+// NetworkClock is the network-adjusted time used to validate and timestamp blocks by code that does not keep its own NetworkTime.
+var NetworkClock NetworkTime
+
+// NewNetworkTime returns a NetworkTime correcting clock instead of LocalClock, for a node that shares its process with others and must not share their clocks and peers.
+func NewNetworkTime(clock Clock) *NetworkTime {
+	return &NetworkTime{clock: clock}
+}
+
+func (nt *NetworkTime) local() Clock {
+	if nt.clock != nil {
+		return nt.clock
+	}
+	return LocalClock
+}
+
+// AddSample records that the clock of the peer at host read peerTime when we received it. Only the first sample of a host counts, so a peer can not move the median by connecting again or from other ports, and no more than MaxTimeSamples hosts are sampled.
+func (nt *NetworkTime) AddSample(host string, peerTime time.Time) {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	if nt.offsets == nil {
+		nt.offsets = make(map[string]time.Duration)
+	}
+	if _, ok := nt.offsets[host]; ok || len(nt.offsets) >= MaxTimeSamples {
+		return
+	}
+	nt.offsets[host] = peerTime.Sub(nt.local().Now())
+	chainLog.Debug("peer time sample", "host", host, "offset", nt.offsets[host])
+}
+
+// RemoveSample forgets the offset of host, e.g. when its last peer disconnects.
+func (nt *NetworkTime) RemoveSample(host string) {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	delete(nt.offsets, host)
+}
+
+// Offset is the median of our peers' offsets, counting our own clock as an offset of 0.
+func (nt *NetworkTime) Offset() time.Duration {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	offsets := []time.Duration{0}
+	for _, o := range nt.offsets {
+		offsets = append(offsets, o)
+	}
+	slices.Sort(offsets)
+	median := offsets[len(offsets)/2]
+	if median > MaxTimeAdjustment || median < -MaxTimeAdjustment {
+		chainLog.Debug("peers' clocks are too far off ours, not adjusting", "offset", median)
+		return 0
+	}
+	return median
+}
+
+// Now returns the network-adjusted time.
+func (nt *NetworkTime) Now() time.Time {
+	return nt.local().Now().Add(nt.Offset())
+}
@@ -0,0 +1,160 @@
+package basicblock
+
+import (
+	"testing"
+	"time"
+)
+
+// fixClock makes LocalClock stand still at now for the rest of the test.
+func fixClock(t *testing.T, now time.Time) *ManualClock {
+	clock := NewManualClock(now)
+	orig := LocalClock
+	LocalClock = clock
+	t.Cleanup(func() { LocalClock = orig })
+	return clock
+}
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	ticker := clock.NewTicker(10 * time.Second)
+	clock.Advance(9 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Errorf("ticker fired early")
+	default:
+	}
+	clock.Advance(25 * time.Second) // ticks at 10s, 20s and 30s, only the first is kept
+	if tick := <-ticker.C(); !tick.Equal(start.Add(10 * time.Second)) {
+		t.Errorf("tick at %v, want 10s", tick)
+	}
+	select {
+	case <-ticker.C():
+		t.Errorf("ticker did not drop ticks nobody waited for")
+	default:
+	}
+	ticker.Stop()
+	clock.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		t.Errorf("stopped ticker fired")
+	default:
+	}
+	if !clock.Now().Equal(start.Add(94 * time.Second)) {
+		t.Errorf("clock is at %v", clock.Now())
+	}
+}
+
+func TestMedianTimePast(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var hc HeaderChain
+	for i := 0; i < 20; i++ {
+		hc = append(hc, BlockHeader{Timestamp: start.Add(time.Duration(i) * time.Minute)})
+	}
+	// The median of the last 11 headers, i.e. of minutes 9 to 19.
+	if mtp := hc.MedianTimePast(); !mtp.Equal(start.Add(14 * time.Minute)) {
+		t.Errorf("median time past is %v, want minute 14", mtp)
+	}
+
+	// A miner that keeps stepping back a minute is eventually below the median, even though every block is within a minute of the last.
+	walkingBack := hc[:len(hc):len(hc)]
+	for i := 1; i <= 6; i++ {
+		h := BlockHeader{Timestamp: start.Add(time.Duration(19-i) * time.Minute)}
+		valid := h.isValidTimestamp(walkingBack, NetworkClock.Now())
+		if valid != (i <= 3) {
+			t.Errorf("block %d minutes before the tip: valid is %t", i, valid)
+		}
+		walkingBack = append(walkingBack, h)
+	}
+}
+
+func TestNetworkTime(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fixClock(t, now)
+	var nt NetworkTime
+	if nt.Offset() != 0 {
+		t.Errorf("offset without peers should be 0")
+	}
+	nt.AddSample("a", now.Add(10*time.Second))
+	nt.AddSample("b", now.Add(20*time.Second))
+	nt.AddSample("c", now.Add(-5*time.Second))
+	// Offsets -5s, 0s (ours), 10s and 20s.
+	if nt.Offset() != 10*time.Second {
+		t.Errorf("offset is %v, want 10s", nt.Offset())
+	}
+	if !nt.Now().Equal(now.Add(10 * time.Second)) {
+		t.Errorf("network time is %v", nt.Now())
+	}
+	nt.RemoveSample("b")
+	if nt.Offset() != 0 {
+		t.Errorf("offset after removing a peer is %v, want 0", nt.Offset())
+	}
+
+	nt.AddSample("b", now.Add(2*MaxTimeAdjustment))
+	nt.AddSample("d", now.Add(2*MaxTimeAdjustment))
+	nt.AddSample("e", now.Add(2*MaxTimeAdjustment))
+	if nt.Offset() != 0 {
+		t.Errorf("offsets beyond MaxTimeAdjustment should be ignored, got %v", nt.Offset())
+	}
+	// A NetworkTime of its own follows its clock, not LocalClock, and not the samples of others.
+	own := NewNetworkTime(NewManualClock(now.Add(time.Hour)))
+	own.AddSample("a", now.Add(time.Hour+10*time.Second))
+	if !own.Now().Equal(now.Add(time.Hour + 10*time.Second)) {
+		t.Errorf("own network time is %v, want its clock plus the peer's offset", own.Now())
+	}
+	if nt.Offset() != 0 {
+		t.Errorf("a sample added to another NetworkTime changed this one")
+	}
+}
+
+func TestNetworkTimeSamples(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fixClock(t, now)
+	var nt NetworkTime
+	nt.AddSample("a", now.Add(10*time.Second))
+	nt.AddSample("b", now.Add(20*time.Second))
+	// A host that connects again does not get to report another clock.
+	nt.AddSample("a", now.Add(-20*time.Second))
+	if nt.Offset() != 10*time.Second {
+		t.Errorf("offset is %v, want 10s from the first sample of each host", nt.Offset())
+	}
+
+	origMax := MaxTimeSamples
+	MaxTimeSamples = 2
+	t.Cleanup(func() { MaxTimeSamples = origMax })
+	nt.AddSample("c", now.Add(-5*time.Second))
+	nt.AddSample("d", now.Add(-5*time.Second))
+	if nt.Offset() != 10*time.Second {
+		t.Errorf("offset is %v, samples beyond MaxTimeSamples should be ignored", nt.Offset())
+	}
+}
+
+func TestNextTimestamp(t *testing.T) {
+	// A peer whose clock is 50s ahead of ours, within MaxFutureDrift, mined these.
+	bc := newHarness(t).chain(5)
+	fixClock(t, harnessStart.Add(time.Second))
+	if !bc.IsValid() {
+		t.Fatalf("chain within MaxFutureDrift of our clock was invalid")
+	}
+	mtp := bc.Headers().MedianTimePast()
+	if !mtp.After(LocalClock.Now()) {
+		t.Fatalf("median time past %v is not ahead of our clock", mtp)
+	}
+
+	ownClock := bc[5].FindBlockAt(nil, nil, 0, NetworkClock.Now())
+	if append(bc[:6:6], ownClock).IsValid() {
+		t.Errorf("block stamped before the median time past was valid")
+	}
+	ts := bc.NextTimestamp(NetworkClock.Now())
+	if !ts.Equal(mtp.Add(time.Second)) {
+		t.Errorf("NextTimestamp = %v, want a second past the median time past %v", ts, mtp)
+	}
+	if !append(bc[:6:6], bc[5].FindBlockAt(nil, nil, 0, ts)).IsValid() {
+		t.Errorf("block stamped with NextTimestamp was invalid")
+	}
+
+	later := mtp.Add(time.Minute)
+	if ts := bc.NextTimestamp(later); !ts.Equal(later) {
+		t.Errorf("NextTimestamp(%v) = %v, want the time itself once it is past the median", later, ts)
+	}
+}
@@ -1,10 +0,0 @@
-// +build debug
-
-package basicblock
-
-import "log"
-
-// to use: go test -tags debug
-func debug(fmt string, args ...interface{}) {
-	log.Printf(fmt, args...)
-}
@@ -0,0 +1,259 @@
+package basicblock
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Transactions keep their fields unexported so that only this package can build valid ones. The types below are what goes over the wire instead, both as JSON for the http API and as gob between peers.
+
+type txInWire struct {
+	TxOutID    string `json:"txOutId"`
+	TxOutIndex int32  `json:"txOutIndex"`
+	Sequence   uint32 `json:"sequence,omitempty"`
+	Script     string `json:"script,omitempty"`
+}
+
+type txOutWire struct {
+	Script string `json:"script"`
+	Amount int32  `json:"amount"`
+}
+
+type transactionWire struct {
+	ID       string      `json:"id"`
+	TxIns    []txInWire  `json:"txIns"`
+	TxOuts   []txOutWire `json:"txOuts"`
+	LockTime uint32      `json:"lockTime,omitempty"`
+}
+
+type unspentTxOutWire struct {
+	TxOutID     string    `json:"txOutId"`
+	TxOutIndex  int32     `json:"txOutIndex"`
+	Script      string    `json:"script"`
+	Amount      int32     `json:"amount"`
+	BlockHeight int32     `json:"blockHeight"`
+	MedianTime  time.Time `json:"medianTime"`
+	Coinbase    bool      `json:"coinbase"`
+}
+
+func decodeHash(s string) ([32]byte, error) {
+	var res [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return res, err
+	}
+	if len(b) != 32 {
+		return res, fmt.Errorf("hash has length %d, want 32", len(b))
+	}
+	copy(res[:], b)
+	return res, nil
+}
+
+func decodeScript(s string) (Script, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, TxError{fmt.Sprintf("script is not hex: %v", err), InvalidTx}
+	}
+	if len(b) == 0 {
+		return nil, nil
+	}
+	return b, nil
+}
+
+func (tx Transaction) toWire() transactionWire {
+	w := transactionWire{ID: hex.EncodeToString(tx.id[:]), LockTime: tx.lockTime}
+	for _, txIn := range tx.txIns {
+		w.TxIns = append(w.TxIns, txInWire{hex.EncodeToString(txIn.txOutID[:]), txIn.txOutIndex, txIn.sequence, hex.EncodeToString(txIn.unlockingScript)})
+	}
+	for _, txOut := range tx.txOuts {
+		w.TxOuts = append(w.TxOuts, txOutWire{hex.EncodeToString(txOut.lockingScript), txOut.amount})
+	}
+	return w
+}
+
+func (tx *Transaction) fromWire(w transactionWire) error {
+	id, err := decodeHash(w.ID)
+	if err != nil {
+		return TxError{fmt.Sprintf("invalid transaction id: %v", err), InvalidTx}
+	}
+	res := Transaction{id: id, lockTime: w.LockTime}
+	for _, wIn := range w.TxIns {
+		txIn := TxIn{txOutIndex: wIn.TxOutIndex, sequence: wIn.Sequence}
+		if txIn.txOutID, err = decodeHash(wIn.TxOutID); err != nil {
+			return TxError{fmt.Sprintf("invalid txOutId: %v", err), InvalidTx}
+		}
+		if txIn.unlockingScript, err = decodeScript(wIn.Script); err != nil {
+			return err
+		}
+		res.txIns = append(res.txIns, txIn)
+	}
+	for _, wOut := range w.TxOuts {
+		lockingScript, err := decodeScript(wOut.Script)
+		if err != nil {
+			return err
+		}
+		res.txOuts = append(res.txOuts, TxOut{lockingScript, wOut.Amount})
+	}
+	*tx = res
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (tx Transaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tx.toWire())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The id is taken as is, validation happens when the transaction is added to a pool or block.
+func (tx *Transaction) UnmarshalJSON(b []byte) error {
+	var w transactionWire
+	if err := json.Unmarshal(b, &w); err != nil {
+		return err
+	}
+	return tx.fromWire(w)
+}
+
+// GobEncode implements gob.GobEncoder so that blocks carrying transactions can be sent to peers.
+func (tx Transaction) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(tx.toWire())
+	return buf.Bytes(), err
+}
+
+// GobDecode implements gob.GobDecoder.
+func (tx *Transaction) GobDecode(b []byte) error {
+	var w transactionWire
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&w); err != nil {
+		return err
+	}
+	return tx.fromWire(w)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (utxo UnspentTxOut) MarshalJSON() ([]byte, error) {
+	return json.Marshal(unspentTxOutWire{hex.EncodeToString(utxo.txOutId[:]), utxo.txOutIndex, hex.EncodeToString(utxo.lockingScript), utxo.amount, utxo.blockHeight, utxo.medianTime, utxo.coinbase})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (utxo *UnspentTxOut) UnmarshalJSON(b []byte) error {
+	var w unspentTxOutWire
+	if err := json.Unmarshal(b, &w); err != nil {
+		return err
+	}
+	txOutID, err := decodeHash(w.TxOutID)
+	if err != nil {
+		return TxError{fmt.Sprintf("invalid txOutId: %v", err), Generic}
+	}
+	lockingScript, err := decodeScript(w.Script)
+	if err != nil {
+		return err
+	}
+	*utxo = UnspentTxOut{txOutID, w.TxOutIndex, lockingScript, w.Amount, w.BlockHeight, w.MedianTime, w.Coinbase}
+	return nil
+}
+
+type blockHeaderWire struct {
+	Version      int32     `json:"version"`
+	Index        int32     `json:"index"`
+	Hash         string    `json:"hash"`
+	PreviousHash string    `json:"previousHash"`
+	PayloadRoot  string    `json:"payloadRoot"`
+	Timestamp    time.Time `json:"timestamp"`
+	Difficulty   int32     `json:"difficulty"`
+	Nonce        string    `json:"nonce"`
+}
+
+type merkleStepWire struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left,omitempty"`
+}
+
+type txInclusionProofWire struct {
+	Transaction Transaction      `json:"transaction"`
+	BlockHash   string           `json:"blockHash"`
+	Index       int              `json:"index"`
+	Proof       []merkleStepWire `json:"proof"`
+}
+
+func headerToWire(h *BlockHeader) blockHeaderWire {
+	return blockHeaderWire{h.Version, h.Index, hex.EncodeToString(h.Hash[:]), hex.EncodeToString(h.PreviousHash[:]), hex.EncodeToString(h.PayloadRoot[:]), h.Timestamp, h.Difficulty, hex.EncodeToString(h.Nonce)}
+}
+
+func headerFromWire(w blockHeaderWire) (BlockHeader, error) {
+	res := BlockHeader{Version: w.Version, Index: w.Index, Timestamp: w.Timestamp, Difficulty: w.Difficulty}
+	var err error
+	if res.Hash, err = decodeHash(w.Hash); err != nil {
+		return res, fmt.Errorf("invalid hash: %v", err)
+	}
+	if res.PreviousHash, err = decodeHash(w.PreviousHash); err != nil {
+		return res, fmt.Errorf("invalid previousHash: %v", err)
+	}
+	if res.PayloadRoot, err = decodeHash(w.PayloadRoot); err != nil {
+		return res, fmt.Errorf("invalid payloadRoot: %v", err)
+	}
+	if res.Nonce, err = hex.DecodeString(w.Nonce); err != nil {
+		return res, fmt.Errorf("invalid nonce: %v", err)
+	}
+	return res, nil
+}
+
+// MarshalJSON implements json.Marshaler. It lives on HeaderChain rather than BlockHeader so that it is not promoted to BasicBlock, which embeds the header.
+func (hc HeaderChain) MarshalJSON() ([]byte, error) {
+	w := []blockHeaderWire{}
+	for i := range hc {
+		w = append(w, headerToWire(&hc[i]))
+	}
+	return json.Marshal(w)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (hc *HeaderChain) UnmarshalJSON(b []byte) error {
+	var w []blockHeaderWire
+	if err := json.Unmarshal(b, &w); err != nil {
+		return err
+	}
+	res := HeaderChain{}
+	for _, wh := range w {
+		h, err := headerFromWire(wh)
+		if err != nil {
+			return err
+		}
+		res = append(res, h)
+	}
+	*hc = res
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p TxInclusionProof) MarshalJSON() ([]byte, error) {
+	w := txInclusionProofWire{Transaction: p.Transaction, BlockHash: hex.EncodeToString(p.BlockHash[:]), Index: p.Index, Proof: []merkleStepWire{}}
+	for _, step := range p.Proof {
+		w.Proof = append(w.Proof, merkleStepWire{hex.EncodeToString(step.Hash[:]), step.Left})
+	}
+	return json.Marshal(w)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *TxInclusionProof) UnmarshalJSON(b []byte) error {
+	var w txInclusionProofWire
+	if err := json.Unmarshal(b, &w); err != nil {
+		return err
+	}
+	res := TxInclusionProof{Transaction: w.Transaction, Index: w.Index}
+	var err error
+	if res.BlockHash, err = decodeHash(w.BlockHash); err != nil {
+		return fmt.Errorf("invalid blockHash: %v", err)
+	}
+	for _, step := range w.Proof {
+		h, err := decodeHash(step.Hash)
+		if err != nil {
+			return fmt.Errorf("invalid proof: %v", err)
+		}
+		res.Proof = append(res.Proof, MerkleStep{h, step.Left})
+	}
+	*p = res
+	return nil
+}
@@ -0,0 +1,116 @@
+package basicblock
+
+import (
+	"crypto"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// A transaction may send less than it spends, the difference is its fee and goes to the miner of the block including it, on top of the subsidy. Fee rates are in coins per 1000 bytes of Transaction.Size.
+
+// MinRelayFeeRate is the lowest fee rate a transaction needs to enter the pool. This is policy, not consensus: blocks may include transactions paying less.
+var MinRelayFeeRate int64 = 1
+
+// FeeEstimateBlocks is how many recent blocks EstimateFeeRate looks at.
+const FeeEstimateBlocks int = 10
+
+// FeeForSize is the fee a transaction of size bytes has to pay to reach feeRate, rounded up.
+func FeeForSize(size int, feeRate int64) int64 {
+	return (int64(size)*feeRate + 999) / 1000
+}
+
+// Fee is what the inputs of tx spend minus what its outputs send. Inputs not in aUnspentTxOuts count as 0, so this only makes sense for transactions that are valid against them.
+func (tx *Transaction) Fee(aUnspentTxOuts []UnspentTxOut) int64 {
+	var fee int64
+	for _, txIn := range tx.txIns {
+		utxo, _ := findUnspentTxOut(txIn.txOutID, txIn.txOutIndex, aUnspentTxOuts)
+		fee += int64(utxo.amount)
+	}
+	for _, txOut := range tx.txOuts {
+		fee -= int64(txOut.amount)
+	}
+	return fee
+}
+
+// feeRate is fee per 1000 bytes of tx, rounded down.
+func feeRate(tx *Transaction, fee int64) int64 {
+	return fee * 1000 / int64(tx.Size())
+}
+
+// NewCoinbaseTransactionWithFees is NewCoinbaseTransaction for a block whose other transactions pay fees, which the coinbase claims on top of the subsidy. It fails if the fees are negative or the subsidy and fees together do not fit in an output's amount, rather than returning a coinbase whose amount wrapped around; the block has to leave transactions out then.
+func NewCoinbaseTransactionWithFees(address crypto.PublicKey, blockHeight int32, fees int64) (Transaction, error) {
+	tx := NewCoinbaseTransaction(address, blockHeight)
+	if fees < 0 || int64(tx.txOuts[0].amount)+fees > math.MaxInt32 {
+		return Transaction{}, TxError{fmt.Sprintf("coinbase can not claim %d in fees on top of the subsidy of %d", fees, tx.txOuts[0].amount), InvalidTx}
+	}
+	tx.txOuts[0].amount += int32(fees)
+	tx.id = tx.getID()
+	return tx, nil
+}
+
+// EstimateFeeRate suggests a fee rate for getting into one of the next blocks: the median fee rate paid in the last FeeEstimateBlocks blocks of bc, but at least MinRelayFeeRate. The outputs their transactions spent are looked up in idx, the TxIndex following bc, or in a single scan of bc if idx is nil.
+func (bc BlockChain) EstimateFeeRate(idx TxIndex) int64 {
+	recent := bc[max(0, len(bc)-FeeEstimateBlocks):]
+	spent := bc.spentTransactions(recent, idx)
+	var rates []int64
+	for _, blk := range recent {
+		for i := range blk.Transactions {
+			tx := &blk.Transactions[i]
+			if tx.IsCoinbase() {
+				continue
+			}
+			rates = append(rates, feeRate(tx, confirmedFee(tx, spent)))
+		}
+	}
+	if len(rates) == 0 {
+		return MinRelayFeeRate
+	}
+	slices.Sort(rates)
+	return max(MinRelayFeeRate, rates[len(rates)/2])
+}
+
+// spentTransactions finds the transactions in bc whose outputs the transactions of blocks spend, by id, through idx if it is not nil.
+func (bc BlockChain) spentTransactions(blocks []BasicBlock, idx TxIndex) map[[32]byte]Transaction {
+	wanted := make(map[[32]byte]bool)
+	for _, blk := range blocks {
+		for _, tx := range blk.Transactions {
+			if !tx.IsCoinbase() {
+				for _, txIn := range tx.txIns {
+					wanted[txIn.txOutID] = true
+				}
+			}
+		}
+	}
+	found := make(map[[32]byte]Transaction, len(wanted))
+	if idx != nil {
+		for id := range wanted {
+			if tx, _, ok := idx.Find(bc, id); ok {
+				found[id] = tx
+			}
+		}
+		return found
+	}
+	for _, blk := range bc {
+		for _, tx := range blk.Transactions {
+			if wanted[tx.id] {
+				found[tx.id] = tx
+			}
+		}
+	}
+	return found
+}
+
+// confirmedFee is the fee of tx, which is confirmed, given the transactions whose outputs it spent.
+func confirmedFee(tx *Transaction, spent map[[32]byte]Transaction) int64 {
+	var fee int64
+	for _, txIn := range tx.txIns {
+		if from, ok := spent[txIn.txOutID]; ok && int(txIn.txOutIndex) < len(from.txOuts) {
+			fee += int64(from.txOuts[txIn.txOutIndex].amount)
+		}
+	}
+	for _, txOut := range tx.txOuts {
+		fee -= int64(txOut.amount)
+	}
+	return fee
+}
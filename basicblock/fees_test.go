@@ -0,0 +1,98 @@
+package basicblock
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math"
+	"testing"
+)
+
+// spend returns a signed transaction sending all of output 0 of from back to priv, minus fee.
+func spend(t *testing.T, priv *ecdsa.PrivateKey, from Transaction, fee int32, utxos []UnspentTxOut) Transaction {
+	t.Helper()
+	tx := NewTransaction([]TxIn{NewTxIn(from.id, 0)}, []TxOut{NewTxOut(priv.PublicKey, from.txOuts[0].amount-fee)})
+	if err := tx.Sign(priv, utxos); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// coinbaseWithFees is NewCoinbaseTransactionWithFees for fees that fit.
+func coinbaseWithFees(t *testing.T, address crypto.PublicKey, blockHeight int32, fees int64) Transaction {
+	t.Helper()
+	tx, err := NewCoinbaseTransactionWithFees(address, blockHeight, fees)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestFees(t *testing.T) {
+	setParams(t, ChainParams{InitialSubsidy: 50, CoinbaseMaturity: 1})
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	h := newHarness(t)
+	blockChain := BlockChain{GenesisBlock}
+	var coinbases []Transaction
+	for height := int32(1); height <= 3; height++ {
+		coinbases = append(coinbases, NewCoinbaseTransaction(priv.PublicKey, height))
+		blockChain = append(blockChain, h.next(&blockChain[len(blockChain)-1], "", coinbases[height-1]))
+	}
+	utxos, err := blockChain.UnspentTxOuts()
+	checkFatal(err)
+	if rate := blockChain.EstimateFeeRate(nil); rate != MinRelayFeeRate {
+		t.Errorf("estimate without any fees paid = %d, want MinRelayFeeRate", rate)
+	}
+
+	var pool TransactionPool
+	for i, fee := range []int32{1, 5, 3} {
+		pool, _, err = pool.Add(spend(t, priv, coinbases[i], fee, utxos), utxos, blockChain.NextContext())
+		checkFatal(err)
+	}
+	txs, fees := pool.ForBlock(utxos, nil)
+	if len(txs) != 3 || fees != 9 {
+		t.Fatalf("ForBlock picked %d transactions paying %d, want 3 paying 9", len(txs), fees)
+	}
+	for i, want := range []int64{5, 3, 1} {
+		if fee := txs[i].Fee(utxos); fee != want {
+			t.Errorf("transaction %d pays %d, want %d: not ordered by fee rate", i, fee, want)
+		}
+	}
+
+	tip := &blockChain[len(blockChain)-1]
+	greedy := coinbaseWithFees(t, priv.PublicKey, blockChain.NextHeight(), fees+1)
+	if append(blockChain, h.next(tip, "", append([]Transaction{greedy}, txs...)...)).IsValid() {
+		t.Errorf("coinbase claiming more than the fees was valid")
+	}
+	coinbase := coinbaseWithFees(t, priv.PublicKey, blockChain.NextHeight(), fees)
+	blockChain = append(blockChain, h.next(tip, "", append([]Transaction{coinbase}, txs...)...))
+	if !blockChain.IsValid() {
+		t.Fatalf("coinbase claiming the fees was invalid")
+	}
+	want := feeRate(&txs[1], 3)
+	if rate := blockChain.EstimateFeeRate(nil); rate != want {
+		t.Errorf("estimate = %d, want the median fee rate %d", rate, want)
+	}
+	if rate := blockChain.EstimateFeeRate(NewTxIndex(blockChain)); rate != want {
+		t.Errorf("estimate through the index = %d, want the median fee rate %d", rate, want)
+	}
+
+	if _, err := NewCoinbaseTransactionWithFees(priv.PublicKey, blockChain.NextHeight(), math.MaxInt32); err == nil {
+		t.Errorf("coinbase claiming fees that overflow its amount was created")
+	}
+	if _, err := NewCoinbaseTransactionWithFees(priv.PublicKey, blockChain.NextHeight(), -1); err == nil {
+		t.Errorf("coinbase claiming negative fees was created")
+	}
+}
+
+func TestFeeForSize(t *testing.T) {
+	for _, tt := range []struct {
+		size       int
+		rate, want int64
+	}{{0, 5, 0}, {1, 1, 1}, {1000, 1, 1}, {1001, 1, 2}, {250, 8, 2}} {
+		if got := FeeForSize(tt.size, tt.rate); got != tt.want {
+			t.Errorf("FeeForSize(%d, %d) = %d, want %d", tt.size, tt.rate, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package basicblock
+
+import (
+	"testing"
+	"time"
+)
+
+// harness builds chains for consensus tests without depending on real time or real mining. Blocks are timestamped by a ManualClock that advances BlockGenerationInterval per block, and mined at difficulty 0 so that the first nonce always works. The same calls always produce the same blocks.
+type harness struct {
+	clock *ManualClock
+}
+
+// harnessStart is when the harness clock starts.
+var harnessStart = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// newHarness installs the harness clock and difficulty for the duration of the test. Tests using it must not run in parallel, as both are package globals.
+func newHarness(t *testing.T) *harness {
+	h := &harness{NewManualClock(harnessStart)}
+	origClock, origDifficulty := LocalClock, Difficulty
+	LocalClock, Difficulty = h.clock, 0
+	t.Cleanup(func() { LocalClock, Difficulty = origClock, origDifficulty })
+	return h
+}
+
+// next mines the block following prev.
+func (h *harness) next(prev *BasicBlock, data string, txs ...Transaction) BasicBlock {
+	h.clock.Advance(time.Duration(BlockGenerationInterval) * time.Second)
+	return prev.FindBlockWithTransactions([]byte(data), txs)
+}
+
+// extend returns bc with n more blocks carrying data. bc itself is not modified, so forks can be grown from a shared prefix.
+func (h *harness) extend(bc BlockChain, n int, data string) BlockChain {
+	res := append(BlockChain{}, bc...)
+	for i := 0; i < n; i++ {
+		res = append(res, h.next(&res[len(res)-1], data))
+	}
+	return res
+}
+
+// chain returns the genesis block followed by n empty blocks.
+func (h *harness) chain(n int) BlockChain {
+	return h.extend(BlockChain{GenesisBlock}, n, "")
+}
+
+func TestHarnessIsDeterministic(t *testing.T) {
+	a := newHarness(t).chain(5)
+	b := newHarness(t).chain(5)
+	if !a.IsValid() {
+		t.Errorf("harness chain is invalid")
+	}
+	if !deepEqual(a, b) {
+		t.Errorf("harness produced different chains")
+	}
+	if !a[5].Timestamp.Equal(harnessStart.Add(5 * time.Duration(BlockGenerationInterval) * time.Second)) {
+		t.Errorf("unexpected timestamp %v", a[5].Timestamp)
+	}
+}
@@ -0,0 +1,119 @@
+package basicblock
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math/big"
+	"slices"
+	"time"
+)
+
+// BlockVersion is the header version produced by this code.
+const BlockVersion int32 = 1
+
+// MedianTimeSpan is the number of previous blocks whose median timestamp a new block may not precede. (in Bitcoin this value is 11 blocks)
+const MedianTimeSpan int = 11
+
+// MaxFutureDrift is how far ahead of the network-adjusted time a block's timestamp may be. (in Bitcoin this value is 2 hours)
+var MaxFutureDrift = 60 * time.Second
+
+// BlockHeader holds everything that goes into a block's proof of work. The body (Data and Transactions) is committed to through PayloadRoot, so a chain of headers can be validated and relayed without the bodies.
+type BlockHeader struct {
+	Version      int32
+	Index        int32
+	Hash         [32]byte // claimed hash of the other fields, checked by IsValid
+	PreviousHash [32]byte
+	PayloadRoot  [32]byte
+	Timestamp    time.Time
+	Difficulty   int32
+	Nonce        []byte
+}
+
+// HeaderChain is a BlockChain stripped of its bodies.
+type HeaderChain []BlockHeader
+
+func (h *BlockHeader) String() string {
+	return fmt.Sprintf("(Version: %d, Index: %d, Hash: %x, PreviousHash: %x, PayloadRoot: %x, Timestamp: %s, Difficulty: %d, Nonce %x)", h.Version, h.Index, h.Hash, h.PreviousHash, h.PayloadRoot, h.Timestamp.Format(time.RFC3339), h.Difficulty, h.Nonce)
+}
+
+func (h *BlockHeader) calculateHash() [32]byte {
+	var hashInput bytes.Buffer
+	for _, n := range []int32{h.Version, h.Index, h.Difficulty} {
+		err := binary.Write(&hashInput, binary.LittleEndian, n)
+		if err != nil {
+			log.Fatalf("Int to binary conversion failed with error %v", err)
+		}
+	}
+	hashInput.Write(h.PreviousHash[:])
+	hashInput.Write(h.PayloadRoot[:])
+	// Seconds and nanoseconds at a fixed width, so timestamps differing by less than a second do not hash the same.
+	ts := binary.LittleEndian.AppendUint64(nil, uint64(h.Timestamp.Unix()))
+	hashInput.Write(binary.LittleEndian.AppendUint32(ts, uint32(h.Timestamp.Nanosecond())))
+	hashInput.Write(h.Nonce)
+	return sha256.Sum256(hashInput.Bytes())
+}
+
+// IsValid checks the header's proof of work and that it correctly follows prev. It says nothing about the body, and the timestamp is checked against the whole chain by isValidTimestamp.
+func (h *BlockHeader) IsValid(prev *BlockHeader) bool {
+	return h.Index == prev.Index+1 && h.PreviousHash == prev.Hash && h.calculateHash() == h.Hash && hashMatchesDifficulty(h.Difficulty, h.Hash[:])
+}
+
+// isValidTimestamp is used to mitigate attacks in which a false timestamp is introduced in order to manipulate the difficulty. A block is valid, if the timestamp is at most MaxFutureDrift ahead of the network-adjusted time now, and not before the median time past of the blocks prevs preceding it. Comparing against the median rather than the previous block means a miner can not walk time backwards block by block.
+func (h *BlockHeader) isValidTimestamp(prevs HeaderChain, now time.Time) bool {
+	return !h.Timestamp.Before(prevs.MedianTimePast()) && h.Timestamp.Before(now.Add(MaxFutureDrift))
+}
+
+// MedianTimePast is the median timestamp of the last MedianTimeSpan headers. A block following hc may not be older than this.
+func (hc HeaderChain) MedianTimePast() time.Time {
+	if len(hc) == 0 {
+		return time.Time{}
+	}
+	var timestamps []time.Time
+	for _, h := range hc[max(0, len(hc)-MedianTimeSpan):] {
+		timestamps = append(timestamps, h.Timestamp)
+	}
+	slices.SortFunc(timestamps, time.Time.Compare)
+	return timestamps[len(timestamps)/2]
+}
+
+// IsValid makes sure that the headers start at the genesis block and link up with valid proof of work, with timestamps checked against NetworkClock.
+func (hc HeaderChain) IsValid() bool {
+	return hc.IsValidAt(NetworkClock.Now())
+}
+
+// IsValidAt is IsValid at the network-adjusted time now, for a node that keeps its own NetworkTime.
+func (hc HeaderChain) IsValidAt(now time.Time) bool {
+	if len(hc) < 1 {
+		chainLog.Debug("invalid header chain: length is 0")
+		return false
+	}
+	if hc[0].Hash != GenesisBlock.Hash || hc[0].calculateHash() != GenesisBlock.calculateHash() {
+		chainLog.Debug("invalid header chain: wrong genesis header")
+		return false
+	}
+	for i := 1; i < len(hc); i++ {
+		if !hc[i].IsValid(&hc[i-1]) {
+			chainLog.Debug("invalid header chain: invalid header", "index", hc[i].Index)
+			return false
+		}
+		if !hc[i].isValidTimestamp(hc[:i], now) {
+			chainLog.Debug("invalid header chain: invalid timestamp", "index", hc[i].Index, "timestamp", hc[i].Timestamp)
+			return false
+		}
+	}
+	return true
+}
+
+// CumulativeDifficulty sums 2^difficulty over all headers, which is the expected amount of work that went into the chain. It is a big.Int as a single block at difficulty 64 already overflows a uint64; blocks below difficulty 0 add nothing.
+func (hc HeaderChain) CumulativeDifficulty() *big.Int {
+	cum := new(big.Int)
+	for _, h := range hc {
+		if h.Difficulty >= 0 {
+			cum.Add(cum, new(big.Int).Lsh(big.NewInt(1), uint(h.Difficulty)))
+		}
+	}
+	return cum
+}
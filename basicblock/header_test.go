@@ -0,0 +1,165 @@
+package basicblock
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMerkleRoot(t *testing.T) {
+	a, b, c := sha256.Sum256([]byte("a")), sha256.Sum256([]byte("b")), sha256.Sum256([]byte("c"))
+	if MerkleRoot(nil) != [32]byte{} {
+		t.Errorf("root of no leaves should be zero")
+	}
+	if MerkleRoot([][32]byte{a}) != a {
+		t.Errorf("root of one leaf should be the leaf")
+	}
+	ab := merkleParent(a, b)
+	if MerkleRoot([][32]byte{a, b}) != ab {
+		t.Errorf("root of two leaves should be their parent")
+	}
+	if MerkleRoot([][32]byte{a, b, c}) != merkleParent(ab, merkleParent(c, c)) {
+		t.Errorf("odd levels should pair the last node with itself")
+	}
+}
+
+func TestHeaderChain(t *testing.T) {
+	blockChain := newHarness(t).extend(BlockChain{GenesisBlock}, 3, "body")
+	headers := blockChain.Headers()
+	if !headers.IsValid() {
+		t.Errorf("headers of a valid blockchain were invalid")
+	}
+	if headers.CumulativeDifficulty().Cmp(CumulativeDifficulty(blockChain)) != 0 {
+		t.Errorf("header and block cumulative difficulty differ")
+	}
+
+	// The header alone fixes the hash, a different body only shows up through the payload root.
+	mutated := blockChain[2]
+	mutated.Data = []byte("other body")
+	if mutated.calculateHash() != mutated.Hash {
+		t.Errorf("body changed the header hash")
+	}
+	if mutated.IsValid(&blockChain[1]) {
+		t.Errorf("block with a body not matching its payload root was valid")
+	}
+
+	wrongPayload := headers[2]
+	wrongPayload.PayloadRoot = [32]byte{1}
+	if wrongPayload.IsValid(&headers[1]) {
+		t.Errorf("header with a changed payload root kept its proof of work")
+	}
+
+	later := headers[2]
+	later.Timestamp = later.Timestamp.Add(time.Millisecond)
+	if later.calculateHash() == headers[2].calculateHash() {
+		t.Errorf("headers differing by a millisecond hash the same")
+	}
+
+	skipped := HeaderChain{headers[0], headers[2]}
+	if skipped.IsValid() {
+		t.Errorf("header chain with a gap was valid")
+	}
+}
+
+func TestPayloadCommitsToUnlockingScripts(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	coinbase := NewCoinbaseTransaction(priv.PublicKey, 1)
+	tx := NewTransaction([]TxIn{NewTxIn(coinbase.id, 0)}, []TxOut{NewTxOut(priv.PublicKey, 50)})
+	tx.SetUnlockingScript(0, new(ScriptBuilder).AddData([]byte("signature")).Script())
+	block := newHarness(t).next(&GenesisBlock, "body", coinbase, tx)
+
+	// A relayer swapping the unlocking script keeps the transaction id, but not the payload root.
+	swapped := tx.Clone()
+	swapped.SetUnlockingScript(0, new(ScriptBuilder).AddData([]byte("garbage")).Script())
+	if swapped.ID() != tx.ID() {
+		t.Fatalf("unlocking script changed the transaction id")
+	}
+	mutated := block
+	mutated.Transactions = []Transaction{coinbase, swapped}
+	if mutated.IsValid(&GenesisBlock) {
+		t.Errorf("block with a swapped unlocking script was valid")
+	}
+	proof, ok := block.ProveTransaction(tx.ID())
+	if !ok || !proof.Verify(&block.BlockHeader) {
+		t.Fatalf("inclusion proof did not verify")
+	}
+	proof.Transaction = swapped
+	if proof.Verify(&block.BlockHeader) {
+		t.Errorf("inclusion proof verified for a transaction with a swapped unlocking script")
+	}
+}
+
+func TestMerkleProof(t *testing.T) {
+	var leaves [][32]byte
+	for i := 0; i < 5; i++ {
+		leaves = append(leaves, sha256.Sum256([]byte{byte(i)}))
+	}
+	root := MerkleRoot(leaves)
+	for i, leaf := range leaves {
+		if !NewMerkleProof(leaves, i).Verify(leaf, root) {
+			t.Errorf("proof for leaf %d did not verify", i)
+		}
+	}
+	if NewMerkleProof(leaves, 1).Verify(leaves[2], root) {
+		t.Errorf("proof verified for the wrong leaf")
+	}
+}
+
+func TestDataCanNotPoseAsTransaction(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	coinbase := NewCoinbaseTransaction(priv.PublicKey, 1)
+	// Without inputs, a transaction's witness hash is the hash of its id, so Data equal to the id hashes to the same value.
+	fake := NewTransaction(nil, []TxOut{NewTxOut(priv.PublicKey, 1000000)})
+	block := newHarness(t).next(&GenesisBlock, string(fake.id[:]), coinbase)
+	dataProof := NewMerkleProof(block.payloadLeaves(), 0)
+	for _, index := range []int{0, 1} {
+		forged := TxInclusionProof{fake, block.Hash, index, dataProof}
+		if forged.Verify(&block.BlockHeader) {
+			t.Errorf("the Data leaf verified as a transaction at index %d", index)
+		}
+	}
+}
+
+func TestTxInclusionProof(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	coinbase := NewCoinbaseTransaction(priv.PublicKey, 1)
+	block := newHarness(t).next(&GenesisBlock, "body", coinbase)
+	proof, ok := block.ProveTransaction(coinbase.ID())
+	if !ok {
+		t.Fatalf("no proof for a transaction in the block")
+	}
+	if !proof.Verify(&block.BlockHeader) {
+		t.Errorf("inclusion proof did not verify")
+	}
+	if proof.Verify(&GenesisBlock.BlockHeader) {
+		t.Errorf("inclusion proof verified against another header")
+	}
+
+	b, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded TxInclusionProof
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Verify(&block.BlockHeader) {
+		t.Errorf("inclusion proof did not survive JSON")
+	}
+
+	headers := HeaderChain{GenesisBlock.BlockHeader, block.BlockHeader}
+	if b, err = json.Marshal(headers); err != nil {
+		t.Fatal(err)
+	}
+	var decodedHeaders HeaderChain
+	if err := json.Unmarshal(b, &decodedHeaders); err != nil {
+		t.Fatal(err)
+	}
+	if !decodedHeaders.IsValid() || decodedHeaders[1].Hash != block.Hash {
+		t.Errorf("headers did not survive JSON")
+	}
+}
@@ -0,0 +1,60 @@
+package basicblock
+
+// Consensus limits. A block breaking any of them is invalid, so they bound the memory and work a single block can cost the nodes validating it.
+
+// MaxBlockSize in bytes, as counted by BasicBlock.Size. (in Bitcoin this value was 1MB before SegWit)
+const MaxBlockSize int = 100000
+
+// MaxBlockTransactions is the most transactions a block may hold, including the coinbase.
+const MaxBlockTransactions int = 500
+
+// MaxBlockDataSize is the most bytes of free-form Data a block may carry.
+const MaxBlockDataSize int = 1000
+
+// MaxTxSize in bytes, as counted by Transaction.Size. It keeps every valid transaction small enough to fit into a block.
+const MaxTxSize int = 10000
+
+// Sizes of the parts of a block in a compact binary encoding, which is what the limits are expressed in. The wire encodings are hex and a bit larger.
+const (
+	headerSize = 4 + 4 + 32 + 32 + 32 + 8 + 4 // version, index, hash, previous hash, payload root, timestamp, difficulty; the nonce is added as is
+	txInSize   = 32 + 4 + 4 + 2               // spent output, sequence and unlocking script length; the script is added as is
+	txOutSize  = 2 + 4                        // locking script length and amount; the script is added as is
+
+	coinbaseSize = 32 + 4 + txInSize + txOutSize + payToAddressSize // with a PayToPubKeyHash output
+)
+
+// Size is what the transaction counts against MaxTxSize and MaxBlockSize.
+func (tx *Transaction) Size() int {
+	size := 32 + 4 // id and lock time
+	for _, txIn := range tx.txIns {
+		size += txInSize + len(txIn.unlockingScript)
+	}
+	for _, txOut := range tx.txOuts {
+		size += txOutSize + len(txOut.lockingScript)
+	}
+	return size
+}
+
+// Size is what the block counts against MaxBlockSize: its header, data and transactions.
+func (bb *BasicBlock) Size() int {
+	size := headerSize + len(bb.Nonce) + len(bb.Data)
+	for i := range bb.Transactions {
+		size += bb.Transactions[i].Size()
+	}
+	return size
+}
+
+// withinLimits checks the block against the consensus limits.
+func (bb *BasicBlock) withinLimits() bool {
+	switch {
+	case len(bb.Data) > MaxBlockDataSize:
+		chainLog.Debug("invalid block: too much data", "index", bb.Index, "size", len(bb.Data))
+	case len(bb.Transactions) > MaxBlockTransactions:
+		chainLog.Debug("invalid block: too many transactions", "index", bb.Index, "transactions", len(bb.Transactions))
+	case bb.Size() > MaxBlockSize:
+		chainLog.Debug("invalid block: too large", "index", bb.Index, "size", bb.Size())
+	default:
+		return true
+	}
+	return false
+}
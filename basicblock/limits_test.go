@@ -0,0 +1,42 @@
+package basicblock
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+)
+
+// txOuts returns n empty outputs locked to priv.
+func txOuts(priv *ecdsa.PrivateKey, n int) []TxOut {
+	res := make([]TxOut, n)
+	for i := range res {
+		res[i] = NewTxOut(priv.PublicKey, 0)
+	}
+	return res
+}
+
+func TestBlockLimits(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	h := newHarness(t)
+	blockChain := h.chain(1)
+
+	data := string(bytes.Repeat([]byte("x"), MaxBlockDataSize))
+	if !append(blockChain, h.next(&blockChain[1], data)).IsValid() {
+		t.Errorf("block with MaxBlockDataSize data was invalid")
+	}
+	if append(blockChain, h.next(&blockChain[1], data+"x")).IsValid() {
+		t.Errorf("block with too much data was valid")
+	}
+
+	tooMany := make([]Transaction, MaxBlockTransactions+1)
+	if blk := h.next(&blockChain[1], "", tooMany...); blk.IsValid(&blockChain[1]) {
+		t.Errorf("block with too many transactions was valid")
+	}
+
+	big := Transaction{txOuts: txOuts(priv, MaxTxSize/(txOutSize+payToAddressSize)+1)}
+	if big.Size() <= MaxTxSize || validateTransaction(big, nil, atHeight(1)) {
+		t.Errorf("transaction of size %d was valid", big.Size())
+	}
+}
@@ -0,0 +1,76 @@
+package basicblock
+
+import "time"
+
+// A transaction can be locked until a block height or time (Transaction.LockTime), and each of its inputs until the output it spends is old enough (TxIn.Sequence). A locked transaction is valid, it just can not go into a block yet, and so not into the pool either. Times are compared with the median time past of the chain rather than block timestamps, which miners can choose.
+
+// LockTimeThreshold splits lock times: below it a lock time is a block height, otherwise a unix timestamp in seconds.
+const LockTimeThreshold uint32 = 500000000
+
+// Sequence flags and masks for relative locks, as in Bitcoin's BIP 68.
+const (
+	SequenceDisableFlag     uint32 = 1 << 31 // the input has no relative lock
+	SequenceTypeFlag        uint32 = 1 << 22 // the lock is in units of SequenceGranularity instead of blocks
+	SequenceMask            uint32 = 0xffff  // bits holding the lock value
+	SequenceGranularityBits        = 9       // time locks count in units of 2^9 = 512 seconds
+)
+
+// BlockContext is what lock times are checked against: the height of a block and the median time past of the blocks before it.
+type BlockContext struct {
+	Height         int32
+	MedianTimePast time.Time
+}
+
+// NextContext returns the context of the block following bc.
+func (bc BlockChain) NextContext() BlockContext {
+	return BlockContext{bc.NextHeight(), bc.Headers().MedianTimePast()}
+}
+
+// NewTransactionWithLockTime creates a transaction which can not go into a block before lockTime, a height or unix time, see LockTimeThreshold.
+func NewTransactionWithLockTime(txIns []TxIn, txOuts []TxOut, lockTime uint32) Transaction {
+	tx := Transaction{txIns: txIns, txOuts: txOuts, lockTime: lockTime}
+	tx.id = tx.getID()
+	return tx
+}
+
+// NewTxInWithSequence creates a TxIn spending output txOutIndex of transaction txOutID, with a relative lock. RelativeHeightLock and RelativeTimeLock build sequence.
+func NewTxInWithSequence(txOutID [32]byte, txOutIndex int32, sequence uint32) TxIn {
+	return TxIn{txOutID: txOutID, txOutIndex: txOutIndex, sequence: sequence}
+}
+
+// RelativeHeightLock is the sequence of an input that can only be spent blocks blocks after the output it spends.
+func RelativeHeightLock(blocks uint16) uint32 {
+	return uint32(blocks)
+}
+
+// RelativeTimeLock is the sequence of an input that can only be spent d after the output it spends, measured in median time past and rounded up to 512 seconds.
+func RelativeTimeLock(d time.Duration) uint32 {
+	units := (int64(d/time.Second) + 1<<SequenceGranularityBits - 1) >> SequenceGranularityBits
+	return SequenceTypeFlag | uint32(min(units, int64(SequenceMask)))
+}
+
+// LockTime returns the earliest height or time at which tx can go into a block. 0 means tx is not locked.
+func (tx *Transaction) LockTime() uint32 {
+	return tx.lockTime
+}
+
+// isFinal reports whether the absolute lock of tx has passed in the block at ctx.
+func (tx *Transaction) isFinal(ctx BlockContext) bool {
+	if tx.lockTime < LockTimeThreshold {
+		return int64(ctx.Height) >= int64(tx.lockTime)
+	}
+	return ctx.MedianTimePast.Unix() >= int64(tx.lockTime)
+}
+
+// sequenceLockPassed reports whether the relative lock of txIn, which spends utxo, has passed in the block at ctx.
+func (txIn *TxIn) sequenceLockPassed(utxo UnspentTxOut, ctx BlockContext) bool {
+	if txIn.sequence&SequenceDisableFlag != 0 {
+		return true
+	}
+	value := int64(txIn.sequence & SequenceMask)
+	if txIn.sequence&SequenceTypeFlag == 0 {
+		return int64(ctx.Height) >= int64(utxo.blockHeight)+value
+	}
+	wait := time.Duration(value<<SequenceGranularityBits) * time.Second
+	return !ctx.MedianTimePast.Before(utxo.medianTime.Add(wait))
+}
@@ -0,0 +1,116 @@
+package basicblock
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+)
+
+// atHeight is the context of the block at height, for tests that do not care about time.
+func atHeight(height int32) BlockContext {
+	return BlockContext{Height: height}
+}
+
+func TestLockTime(t *testing.T) {
+	setParams(t, ChainParams{InitialSubsidy: 50, CoinbaseMaturity: 1})
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	coinbase := NewCoinbaseTransaction(priv.PublicKey, 1)
+	utxos := updateUnspentTxOuts([]Transaction{coinbase}, nil, atHeight(1))
+	locked := func(lockTime uint32) Transaction {
+		tx := NewTransactionWithLockTime([]TxIn{NewTxIn(coinbase.id, 0)}, []TxOut{NewTxOut(priv.PublicKey, 49)}, lockTime)
+		checkFatal(tx.Sign(priv, utxos))
+		return tx
+	}
+
+	start := time.Unix(int64(LockTimeThreshold)+1000, 0)
+	for _, tt := range []struct {
+		name     string
+		lockTime uint32
+		ctx      BlockContext
+		valid    bool
+	}{
+		{"no lock", 0, atHeight(2), true},
+		{"height not reached", 5, atHeight(4), false},
+		{"height reached", 5, atHeight(5), true},
+		{"time not reached", uint32(start.Unix()), BlockContext{10, start.Add(-time.Second)}, false},
+		{"time reached", uint32(start.Unix()), BlockContext{10, start}, true},
+		{"time ignores height", uint32(start.Unix()), BlockContext{1 << 30, start.Add(-time.Second)}, false},
+	} {
+		if got := validateTransaction(locked(tt.lockTime), utxos, tt.ctx); got != tt.valid {
+			t.Errorf("%s: validateTransaction = %t, want %t", tt.name, got, tt.valid)
+		}
+	}
+
+	// The lock time is signed: changing it breaks the id.
+	tx := locked(5)
+	tx.lockTime = 0
+	if validateTransaction(tx, utxos, atHeight(2)) {
+		t.Errorf("transaction with a changed lock time was valid")
+	}
+}
+
+func TestSequenceLocks(t *testing.T) {
+	setParams(t, ChainParams{InitialSubsidy: 50, CoinbaseMaturity: 1})
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	coinbase := NewCoinbaseTransaction(priv.PublicKey, 1)
+	created := time.Unix(1600000000, 0)
+	utxos := updateUnspentTxOuts([]Transaction{coinbase}, nil, BlockContext{1, created})
+	spend := func(sequence uint32) Transaction {
+		tx := NewTransaction([]TxIn{NewTxInWithSequence(coinbase.id, 0, sequence)}, []TxOut{NewTxOut(priv.PublicKey, 49)})
+		checkFatal(tx.Sign(priv, utxos))
+		return tx
+	}
+
+	for _, tt := range []struct {
+		name     string
+		sequence uint32
+		ctx      BlockContext
+		valid    bool
+	}{
+		{"blocks not passed", RelativeHeightLock(3), BlockContext{3, created}, false},
+		{"blocks passed", RelativeHeightLock(3), BlockContext{4, created}, true},
+		{"time not passed", RelativeTimeLock(time.Hour), BlockContext{100, created.Add(time.Hour)}, false}, // rounded up to 8 units of 512s
+		{"time passed", RelativeTimeLock(time.Hour), BlockContext{100, created.Add(8 * 512 * time.Second)}, true},
+		{"disabled", SequenceDisableFlag | RelativeHeightLock(3), BlockContext{2, created}, true},
+	} {
+		if got := validateTransaction(spend(tt.sequence), utxos, tt.ctx); got != tt.valid {
+			t.Errorf("%s: validateTransaction = %t, want %t", tt.name, got, tt.valid)
+		}
+	}
+}
+
+func TestSequenceLockInChain(t *testing.T) {
+	setParams(t, ChainParams{InitialSubsidy: 50, CoinbaseMaturity: 1})
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	h := newHarness(t)
+	coinbase := NewCoinbaseTransaction(priv.PublicKey, 1)
+	blockChain := BlockChain{GenesisBlock, h.next(&GenesisBlock, "", coinbase)}
+	utxos, err := blockChain.UnspentTxOuts()
+	checkFatal(err)
+	tx := NewTransaction([]TxIn{NewTxInWithSequence(coinbase.id, 0, RelativeHeightLock(3))}, []TxOut{NewTxOut(priv.PublicKey, 49)})
+	checkFatal(tx.Sign(priv, utxos))
+
+	if _, _, err := (TransactionPool{}).Add(tx, utxos, blockChain.NextContext()); err == nil {
+		t.Errorf("pool accepted a transaction whose sequence lock has not passed")
+	}
+	blockChain = h.extend(blockChain, 1, "")
+	if early := append(blockChain, h.next(&blockChain[2], "", coinbaseWithFees(t, priv.PublicKey, 3, 1), tx)); early.IsValid() {
+		t.Errorf("block including a transaction before its sequence lock passed was valid")
+	}
+	blockChain = h.extend(blockChain, 1, "")
+	pool, _, err := TransactionPool{}.Add(tx, utxos, blockChain.NextContext())
+	if err != nil {
+		t.Fatalf("pool rejected a transaction whose sequence lock has passed: %v", err)
+	}
+	blockChain = append(blockChain, h.next(&blockChain[3], "", coinbaseWithFees(t, priv.PublicKey, 4, 1), tx))
+	if !blockChain.IsValid() {
+		t.Errorf("block including a transaction after its sequence lock passed was invalid")
+	}
+
+	// A reorg that moves the spent output up locks the transaction again.
+	if len(pool.Update(utxos, BlockContext{Height: 3})) != 0 {
+		t.Errorf("pool kept a transaction whose sequence lock no longer passed")
+	}
+}
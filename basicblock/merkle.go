@@ -0,0 +1,99 @@
+package basicblock
+
+import "crypto/sha256"
+
+// Prefixes of the hashes in a payload tree, so that no hash of one kind can be passed off as another: the leaf of the block's Data, the leaf of a transaction and an interior node.
+const (
+	merkleDataPrefix byte = iota
+	merkleTxPrefix
+	merkleNodePrefix
+)
+
+// merkleLeaf hashes the preimage of a leaf under the prefix of its kind.
+func merkleLeaf(prefix byte, preimage []byte) [32]byte {
+	return sha256.Sum256(append([]byte{prefix}, preimage...))
+}
+
+// merkleParent hashes two child nodes into their parent.
+func merkleParent(left, right [32]byte) [32]byte {
+	var buf [65]byte
+	buf[0] = merkleNodePrefix
+	copy(buf[1:33], left[:])
+	copy(buf[33:], right[:])
+	return sha256.Sum256(buf[:])
+}
+
+// MerkleRoot folds leaves pairwise into a single hash. A level with an odd number of nodes pairs its last node with itself (as in Bitcoin). The root of no leaves is all zeroes.
+func MerkleRoot(leaves [][32]byte) [32]byte {
+	if len(leaves) == 0 {
+		return [32]byte{}
+	}
+	level := append([][32]byte{}, leaves...)
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		next := make([][32]byte, 0, len(level)/2)
+		for i := 0; i < len(level); i += 2 {
+			next = append(next, merkleParent(level[i], level[i+1]))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// MerkleStep is one level of a MerkleProof: the sibling hash and whether it sits to the left of the running hash.
+type MerkleStep struct {
+	Hash [32]byte
+	Left bool
+}
+
+// MerkleProof is the path of siblings from a leaf up to the root.
+type MerkleProof []MerkleStep
+
+// NewMerkleProof returns the proof that leaves[index] is part of MerkleRoot(leaves).
+func NewMerkleProof(leaves [][32]byte, index int) MerkleProof {
+	var proof MerkleProof
+	level := append([][32]byte{}, leaves...)
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		if index%2 == 0 {
+			proof = append(proof, MerkleStep{level[index+1], false})
+		} else {
+			proof = append(proof, MerkleStep{level[index-1], true})
+		}
+		next := make([][32]byte, 0, len(level)/2)
+		for i := 0; i < len(level); i += 2 {
+			next = append(next, merkleParent(level[i], level[i+1]))
+		}
+		level = next
+		index /= 2
+	}
+	return proof
+}
+
+// Index is the position of the leaf the proof starts from, which the sides of its siblings spell out bit by bit.
+func (proof MerkleProof) Index() int {
+	index := 0
+	for i, step := range proof {
+		if step.Left {
+			index |= 1 << i
+		}
+	}
+	return index
+}
+
+// Verify checks that leaf hashes up to root along the proof.
+func (proof MerkleProof) Verify(leaf, root [32]byte) bool {
+	h := leaf
+	for _, step := range proof {
+		if step.Left {
+			h = merkleParent(step.Hash, h)
+		} else {
+			h = merkleParent(h, step.Hash)
+		}
+	}
+	return h == root
+}
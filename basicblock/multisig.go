@@ -0,0 +1,63 @@
+package basicblock
+
+import (
+	"crypto"
+	"fmt"
+)
+
+// Multisig outputs are locked to N public keys of which any M have to sign: M <pubkey1> .. <pubkeyN> N CHECKMULTISIG. They are spent with <sig1> .. <sigM>, the signatures in the order of their keys in the lock.
+
+// PayToMultiSig returns the lock requiring m signatures by distinct keys of pubs.
+func PayToMultiSig(m int, pubs []crypto.PublicKey) (Script, error) {
+	if len(pubs) == 0 || len(pubs) > MaxMultiSigKeys || m < 1 || m > len(pubs) {
+		return nil, TxError{fmt.Sprintf("invalid multisig: %d of %d keys, at most %d keys", m, len(pubs), MaxMultiSigKeys), Generic}
+	}
+	b := new(ScriptBuilder).AddInt(int64(m))
+	for _, pub := range pubs {
+		key, err := MarshalPublicKey(pub)
+		if err != nil {
+			return nil, TxError{fmt.Sprintf("invalid multisig key: %v", err), Generic}
+		}
+		b.AddData(key)
+	}
+	return b.AddInt(int64(len(pubs))).AddOp(OpCheckMultiSig).Script(), nil
+}
+
+// MultiSig returns the number of signatures and the keys of a lock created by PayToMultiSig.
+func (s Script) MultiSig() (int, []crypto.PublicKey, bool) {
+	ops, err := s.parse()
+	if err != nil || len(ops) < 4 || ops[len(ops)-1].op != OpCheckMultiSig {
+		return 0, nil, false
+	}
+	m, n := smallInt(ops[0].op), smallInt(ops[len(ops)-2].op)
+	keys := ops[1 : len(ops)-2]
+	if m < 1 || n != len(keys) || m > n {
+		return 0, nil, false
+	}
+	pubs := make([]crypto.PublicKey, n)
+	for i, k := range keys {
+		pub, err := ParsePublicKey(k.data)
+		if err != nil {
+			return 0, nil, false
+		}
+		pubs[i] = pub
+	}
+	return m, pubs, true
+}
+
+// smallInt is the number pushed by Op1 to Op16, or 0.
+func smallInt(op Opcode) int {
+	if op >= Op1 && op <= Op16 {
+		return int(op-Op1) + 1
+	}
+	return 0
+}
+
+// MultiSigUnlockingScript returns the unlocking script for a multisig output from the signatures, which have to be in the order of their keys in the lock.
+func MultiSigUnlockingScript(sigs [][]byte) Script {
+	b := new(ScriptBuilder)
+	for _, sig := range sigs {
+		b.AddData(sig)
+	}
+	return b.Script()
+}
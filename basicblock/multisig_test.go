@@ -0,0 +1,59 @@
+package basicblock
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+)
+
+func TestMultiSig(t *testing.T) {
+	setParams(t, ChainParams{InitialSubsidy: 50, CoinbaseMaturity: 1})
+	var keys []*ecdsa.PrivateKey
+	var pubs []crypto.PublicKey
+	for i := 0; i < 3; i++ {
+		k, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		keys, pubs = append(keys, k), append(pubs, k.PublicKey)
+	}
+	lock, err := PayToMultiSig(2, pubs)
+	checkFatal(err)
+	if m, got, ok := lock.MultiSig(); !ok || m != 2 || len(got) != 3 || !got[2].(*ecdsa.PublicKey).Equal(&keys[2].PublicKey) {
+		t.Errorf("MultiSig() = %d, %d keys, %t; want 2 of the 3 keys", m, len(got), ok)
+	}
+	for _, m := range []int{0, 4} {
+		if _, err := PayToMultiSig(m, pubs); err == nil {
+			t.Errorf("PayToMultiSig(%d) of 3 keys succeeded", m)
+		}
+	}
+
+	coinbase := NewCoinbaseTransaction(keys[0].PublicKey, 1)
+	utxos := updateUnspentTxOuts([]Transaction{coinbase}, nil, atHeight(1))
+	fund := NewTransaction([]TxIn{NewTxIn(coinbase.id, 0)}, []TxOut{NewTxOutWithScript(lock, 48)})
+	checkFatal(fund.Sign(keys[0], utxos))
+	utxos = updateUnspentTxOuts([]Transaction{fund}, utxos, atHeight(2))
+
+	tx := NewTransaction([]TxIn{NewTxIn(fund.id, 0)}, []TxOut{NewTxOut(keys[1].PublicKey, 47)})
+	sigs := make([][]byte, 3)
+	for i, k := range keys {
+		sigs[i], err = tx.Signature(k, 0, NewTxOutWithScript(lock, 48), SigHashAll)
+		checkFatal(err)
+	}
+	for _, tt := range []struct {
+		name  string
+		sigs  [][]byte
+		valid bool
+	}{
+		{"first and second", [][]byte{sigs[0], sigs[1]}, true},
+		{"first and third", [][]byte{sigs[0], sigs[2]}, true},
+		{"second and third", [][]byte{sigs[1], sigs[2]}, true},
+		{"out of order", [][]byte{sigs[2], sigs[0]}, false},
+		{"same key twice", [][]byte{sigs[1], sigs[1]}, false},
+		{"one", [][]byte{sigs[0]}, false},
+	} {
+		tx.SetUnlockingScript(0, MultiSigUnlockingScript(tt.sigs))
+		if got := validateTransaction(tx, utxos, atHeight(3)); got != tt.valid {
+			t.Errorf("%s: validateTransaction = %t, want %t", tt.name, got, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package basicblock
+
+// ChainParams are the consensus rules for the coinbase and how addresses of the network are written. Every node of a network has to use the same ones.
+type ChainParams struct {
+	Name                   string
+	AddressVersion         byte  // first byte of encoded addresses, so that coins are not sent to an address of another network
+	InitialSubsidy         int32 // coins a coinbase pays before the first halving
+	SubsidyHalvingInterval int32 // blocks between halvings of the subsidy, 0 never halves (in Bitcoin this is 210000 blocks)
+	CoinbaseMaturity       int32 // blocks a coinbase output has to be deep before it can be spent (in Bitcoin this is 100 blocks)
+}
+
+// MainParams halve the subsidy roughly daily, given BlockGenerationInterval.
+var MainParams = ChainParams{
+	Name:                   "main",
+	AddressVersion:         0x0a, // addresses start with 2
+	InitialSubsidy:         CoinbaseAmount,
+	SubsidyHalvingInterval: 10000,
+	CoinbaseMaturity:       10,
+}
+
+// TestParams are the rules of test networks, which only differ from MainParams in their addresses: test coins are worthless, and a test address must not be mistaken for a real one.
+var TestParams = ChainParams{
+	Name:                   "test",
+	AddressVersion:         0x18, // addresses start with 4
+	InitialSubsidy:         MainParams.InitialSubsidy,
+	SubsidyHalvingInterval: MainParams.SubsidyHalvingInterval,
+	CoinbaseMaturity:       MainParams.CoinbaseMaturity,
+}
+
+// Params are the chain parameters in use.
+var Params = MainParams
+
+// BlockSubsidy is the amount the coinbase of the block at blockHeight pays. It halves every SubsidyHalvingInterval blocks until nothing is left.
+func (p ChainParams) BlockSubsidy(blockHeight int32) int32 {
+	if p.SubsidyHalvingInterval <= 0 {
+		return p.InitialSubsidy
+	}
+	halvings := blockHeight / p.SubsidyHalvingInterval
+	if halvings >= 31 {
+		return 0
+	}
+	return p.InitialSubsidy >> halvings
+}
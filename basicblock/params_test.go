@@ -0,0 +1,50 @@
+package basicblock
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+)
+
+// setParams installs p for the duration of the test.
+func setParams(t *testing.T, p ChainParams) {
+	orig := Params
+	Params = p
+	t.Cleanup(func() { Params = orig })
+}
+
+func TestBlockSubsidy(t *testing.T) {
+	p := ChainParams{InitialSubsidy: 50, SubsidyHalvingInterval: 10}
+	for _, tt := range []struct {
+		height, subsidy int32
+	}{{0, 50}, {9, 50}, {10, 25}, {19, 25}, {20, 12}, {50, 1}, {60, 0}, {10 * 40, 0}} {
+		if got := p.BlockSubsidy(tt.height); got != tt.subsidy {
+			t.Errorf("BlockSubsidy(%d) = %d, want %d", tt.height, got, tt.subsidy)
+		}
+	}
+	if got := (ChainParams{InitialSubsidy: 50}).BlockSubsidy(1 << 30); got != 50 {
+		t.Errorf("subsidy without halvings = %d, want 50", got)
+	}
+}
+
+func TestCoinbaseFollowsHalvingSchedule(t *testing.T) {
+	setParams(t, ChainParams{InitialSubsidy: 50, SubsidyHalvingInterval: 2})
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	h := newHarness(t)
+	blockChain := BlockChain{GenesisBlock}
+	for height := int32(1); height <= 4; height++ {
+		blockChain = append(blockChain, h.next(&blockChain[len(blockChain)-1], "", NewCoinbaseTransaction(priv.PublicKey, height)))
+	}
+	if !blockChain.IsValid() {
+		t.Fatalf("chain following the halving schedule was invalid")
+	}
+	if amount := blockChain[4].Transactions[0].txOuts[0].amount; amount != 12 {
+		t.Errorf("coinbase at height 4 pays %d, want 12", amount)
+	}
+
+	stale := NewTransaction([]TxIn{{txOutIndex: 5}}, []TxOut{NewTxOut(priv.PublicKey, 50)})
+	if append(blockChain, h.next(&blockChain[4], "", stale)).IsValid() {
+		t.Errorf("coinbase ignoring the halving was valid")
+	}
+}
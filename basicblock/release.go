@@ -1,5 +0,0 @@
-// +build !debug
-
-package basicblock
-
-func debug(fmt string, args ...interface{}) {}
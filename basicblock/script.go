@@ -0,0 +1,729 @@
+package basicblock
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Outputs are locked by a script and spent by an input whose unlocking script makes it succeed. Scripts are programs for a small stack machine modelled on Bitcoin's: the unlocking script may only push data, then the locking script runs on the stack it leaves, and the input is valid if the top of the stack is true at the end. There are no loops and every script is bounded by the limits below, so evaluation always terminates quickly. The usual lock is PayToPubKeyHash, which NewTxOut creates.
+
+// Script is a sequence of opcodes and pushed data.
+type Script []byte
+
+// Opcode is a single instruction of a Script. The values are Bitcoin's.
+type Opcode byte
+
+// Push opcodes. Opcodes 0x01 to 0x4b push that many following bytes.
+const (
+	Op0         Opcode = 0x00 // pushes an empty element, which is false and 0
+	OpPushData1 Opcode = 0x4c // pushes up to 255 bytes, preceded by one length byte
+	OpPushData2 Opcode = 0x4d // pushes up to MaxScriptElementSize bytes, preceded by two little endian length bytes
+	Op1Negate   Opcode = 0x4f // pushes -1
+	Op1         Opcode = 0x51 // Op1 to Op16 push the numbers 1 to 16
+	Op16        Opcode = 0x60
+)
+
+// Flow control.
+const (
+	OpNop    Opcode = 0x61
+	OpIf     Opcode = 0x63 // runs the following statements if the top element is true
+	OpNotIf  Opcode = 0x64 // runs the following statements if the top element is false
+	OpElse   Opcode = 0x67
+	OpEndIf  Opcode = 0x68
+	OpVerify Opcode = 0x69 // fails unless the top element is true, which it removes
+	OpReturn Opcode = 0x6a // fails, so outputs locked with it can never be spent
+)
+
+// Stack operations.
+const (
+	OpDrop Opcode = 0x75
+	OpDup  Opcode = 0x76
+	OpOver Opcode = 0x78
+	OpSwap Opcode = 0x7c
+	OpSize Opcode = 0x82 // pushes the length of the top element
+)
+
+// Comparison and arithmetic. Numbers are little endian with a sign bit, at most 4 bytes long.
+const (
+	OpEqual            Opcode = 0x87
+	OpEqualVerify      Opcode = 0x88
+	Op1Add             Opcode = 0x8b
+	Op1Sub             Opcode = 0x8c
+	OpNegate           Opcode = 0x8f
+	OpNot              Opcode = 0x91
+	OpAdd              Opcode = 0x93
+	OpSub              Opcode = 0x94
+	OpBoolAnd          Opcode = 0x9a
+	OpBoolOr           Opcode = 0x9b
+	OpNumEqual         Opcode = 0x9c
+	OpNumEqualVerify   Opcode = 0x9d
+	OpNumNotEqual      Opcode = 0x9e
+	OpLessThan         Opcode = 0x9f
+	OpGreaterThan      Opcode = 0xa0
+	OpLessThanOrEqual  Opcode = 0xa1
+	OpGreaterThanEqual Opcode = 0xa2
+	OpMin              Opcode = 0xa3
+	OpMax              Opcode = 0xa4
+	OpWithin           Opcode = 0xa5 // x min max: whether min <= x < max
+)
+
+// Crypto.
+const (
+	OpSHA256         Opcode = 0xa8
+	OpCheckSig       Opcode = 0xac // sig pubkey: whether sig is a valid signature by pubkey, in the SignatureScheme the key names, of the SignatureHash of the input, followed by its SigHashType
+	OpCheckSigVerify Opcode = 0xad
+	// sig1 .. sigM M pubkey1 .. pubkeyN N: whether each sig is a valid signature by one of the keys, in the same order as the keys, see PayToMultiSig
+	OpCheckMultiSig       Opcode = 0xae
+	OpCheckMultiSigVerify Opcode = 0xaf
+	// scheme: fails unless the element below scheme is a public key of that SignatureScheme, and removes scheme; see PayToAddress
+	OpCheckSchemeVerify Opcode = 0xb3
+)
+
+// Script limits. An input breaking any of them is invalid.
+const (
+	MaxScriptSize        int = 1000 // bytes, of each of the locking and unlocking script
+	MaxScriptElementSize int = 520  // bytes of a single pushed element
+	MaxScriptOps         int = 200  // opcodes other than pushes a script may execute
+	MaxStackSize         int = 100  // elements on the stack
+	maxScriptNumSize     int = 4    // bytes of a number taken from the stack
+	MaxMultiSigKeys      int = 15   // keys of a single OpCheckMultiSig, each counts against MaxScriptOps
+)
+
+var opcodeNames = map[Opcode]string{
+	Op0: "0", OpPushData1: "PUSHDATA1", OpPushData2: "PUSHDATA2", Op1Negate: "-1",
+	OpNop: "NOP", OpIf: "IF", OpNotIf: "NOTIF", OpElse: "ELSE", OpEndIf: "ENDIF", OpVerify: "VERIFY", OpReturn: "RETURN",
+	OpDrop: "DROP", OpDup: "DUP", OpOver: "OVER", OpSwap: "SWAP", OpSize: "SIZE",
+	OpEqual: "EQUAL", OpEqualVerify: "EQUALVERIFY", Op1Add: "1ADD", Op1Sub: "1SUB", OpNegate: "NEGATE", OpNot: "NOT",
+	OpAdd: "ADD", OpSub: "SUB", OpBoolAnd: "BOOLAND", OpBoolOr: "BOOLOR", OpNumEqual: "NUMEQUAL", OpNumEqualVerify: "NUMEQUALVERIFY",
+	OpNumNotEqual: "NUMNOTEQUAL", OpLessThan: "LESSTHAN", OpGreaterThan: "GREATERTHAN", OpLessThanOrEqual: "LESSTHANOREQUAL",
+	OpGreaterThanEqual: "GREATERTHANOREQUAL", OpMin: "MIN", OpMax: "MAX", OpWithin: "WITHIN",
+	OpSHA256: "SHA256", OpCheckSig: "CHECKSIG", OpCheckSigVerify: "CHECKSIGVERIFY",
+	OpCheckMultiSig: "CHECKMULTISIG", OpCheckMultiSigVerify: "CHECKMULTISIGVERIFY", OpCheckSchemeVerify: "CHECKSCHEMEVERIFY",
+}
+
+func (op Opcode) String() string {
+	if n := smallInt(op); n > 0 {
+		return fmt.Sprint(n)
+	}
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN_%#02x", byte(op))
+}
+
+// ScriptBuilder appends opcodes and data to a Script.
+type ScriptBuilder struct {
+	script Script
+}
+
+// AddOp appends op.
+func (b *ScriptBuilder) AddOp(op Opcode) *ScriptBuilder {
+	b.script = append(b.script, byte(op))
+	return b
+}
+
+// AddData appends the shortest push of data.
+func (b *ScriptBuilder) AddData(data []byte) *ScriptBuilder {
+	switch n := len(data); {
+	case n == 0:
+		b.script = append(b.script, byte(Op0))
+	case n < int(OpPushData1):
+		b.script = append(b.script, byte(n))
+	case n <= 0xff:
+		b.script = append(b.script, byte(OpPushData1), byte(n))
+	default:
+		b.script = append(b.script, byte(OpPushData2))
+		b.script = binary.LittleEndian.AppendUint16(b.script, uint16(n))
+	}
+	b.script = append(b.script, data...)
+	return b
+}
+
+// AddInt appends the shortest push of n.
+func (b *ScriptBuilder) AddInt(n int64) *ScriptBuilder {
+	switch {
+	case n == 0:
+		return b.AddOp(Op0)
+	case n == -1:
+		return b.AddOp(Op1Negate)
+	case n >= 1 && n <= 16:
+		return b.AddOp(Op1 + Opcode(n-1))
+	}
+	return b.AddData(encodeScriptNum(n))
+}
+
+// Script returns what was built so far.
+func (b *ScriptBuilder) Script() Script {
+	return b.script
+}
+
+// PubKeyHash is the hash that PayToPubKeyHash locks to: SHA-256 of the public key encoded by MarshalPublicKey, which names its SignatureScheme.
+func PubKeyHash(pub crypto.PublicKey) [32]byte {
+	b, _ := MarshalPublicKey(pub) // invalid keys hash like an empty one, which no key matches
+	return sha256.Sum256(b)
+}
+
+// PayToPubKeyHash returns the standard lock to pub, PayToAddress of its address.
+func PayToPubKeyHash(pub crypto.PublicKey) Script {
+	return PayToAddress(NewAddress(pub))
+}
+
+// PayToHashPreimage returns a lock that anyone knowing a preimage of hash can spend, by pushing it.
+func PayToHashPreimage(hash [32]byte) Script {
+	return new(ScriptBuilder).AddOp(OpSHA256).AddData(hash[:]).AddOp(OpEqual).Script()
+}
+
+// Equal reports whether s and other are the same script.
+func (s Script) Equal(other Script) bool {
+	return bytes.Equal(s, other)
+}
+
+// scriptOp is an opcode together with the data it pushes, if any.
+type scriptOp struct {
+	op   Opcode
+	data []byte
+}
+
+// parse splits s into its opcodes, failing if a push runs past the end.
+func (s Script) parse() ([]scriptOp, error) {
+	var ops []scriptOp
+	for i := 0; i < len(s); {
+		op := Opcode(s[i])
+		i++
+		n := -1
+		switch {
+		case op > Op0 && op < OpPushData1:
+			n = int(op)
+		case op == OpPushData1:
+			if i+1 > len(s) {
+				return nil, scriptError("truncated PUSHDATA1")
+			}
+			n, i = int(s[i]), i+1
+		case op == OpPushData2:
+			if i+2 > len(s) {
+				return nil, scriptError("truncated PUSHDATA2")
+			}
+			n, i = int(binary.LittleEndian.Uint16(s[i:])), i+2
+		}
+		if n < 0 {
+			ops = append(ops, scriptOp{op: op})
+			continue
+		}
+		if i+n > len(s) {
+			return nil, scriptError("push of %d bytes past the end of the script", n)
+		}
+		ops = append(ops, scriptOp{op, s[i : i+n]})
+		i += n
+	}
+	return ops, nil
+}
+
+// String disassembles s, with pushed data in hex. Scripts that do not parse are returned as hex.
+func (s Script) String() string {
+	ops, err := s.parse()
+	if err != nil {
+		return hex.EncodeToString(s)
+	}
+	var parts []string
+	for _, o := range ops {
+		if o.data != nil || (o.op > Op0 && o.op <= OpPushData2) {
+			parts = append(parts, hex.EncodeToString(o.data))
+		} else {
+			parts = append(parts, o.op.String())
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// isPushOnly reports whether s only pushes data, as unlocking scripts have to.
+func (s Script) isPushOnly() bool {
+	ops, err := s.parse()
+	if err != nil {
+		return false
+	}
+	for _, o := range ops {
+		if o.op > Op16 {
+			return false
+		}
+	}
+	return true
+}
+
+func scriptError(format string, args ...any) error {
+	return TxError{"script failed: " + fmt.Sprintf(format, args...), InvalidTx}
+}
+
+// encodeScriptNum encodes n little endian, with the sign in the highest bit of the last byte, in as few bytes as possible. 0 is empty.
+func encodeScriptNum(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append(b, byte(n))
+	}
+	if b[len(b)-1]&0x80 != 0 {
+		b = append(b, 0)
+	}
+	if negative {
+		b[len(b)-1] |= 0x80
+	}
+	return b
+}
+
+// decodeScriptNum is the inverse of encodeScriptNum for numbers of at most maxScriptNumSize bytes. Results of arithmetic may be longer, but can not be used as inputs again.
+func decodeScriptNum(b []byte) (int64, error) {
+	if len(b) > maxScriptNumSize {
+		return 0, scriptError("number of %d bytes is too long", len(b))
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	var n int64
+	for i, c := range b {
+		n |= int64(c) << (8 * i)
+	}
+	if last := b[len(b)-1]; last&0x80 != 0 {
+		return -(n &^ (int64(0x80) << (8 * (len(b) - 1)))), nil
+	}
+	return n, nil
+}
+
+// asBool is the truth value of a stack element: false if it is all zeros, possibly with a sign bit.
+func asBool(b []byte) bool {
+	for i, c := range b {
+		if c != 0 {
+			return i != len(b)-1 || c != 0x80
+		}
+	}
+	return false
+}
+
+func fromBool(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return nil
+}
+
+// scriptStack is the stack of the machine, top last.
+type scriptStack [][]byte
+
+func (st *scriptStack) push(b []byte) {
+	*st = append(*st, b)
+}
+
+func (st *scriptStack) pop() ([]byte, error) {
+	if len(*st) == 0 {
+		return nil, scriptError("stack underflow")
+	}
+	b := (*st)[len(*st)-1]
+	*st = (*st)[:len(*st)-1]
+	return b, nil
+}
+
+// peek returns the element depth below the top.
+func (st *scriptStack) peek(depth int) ([]byte, error) {
+	if depth >= len(*st) {
+		return nil, scriptError("stack underflow")
+	}
+	return (*st)[len(*st)-1-depth], nil
+}
+
+func (st *scriptStack) peekNum(depth int) (int64, error) {
+	b, err := st.peek(depth)
+	if err != nil {
+		return 0, err
+	}
+	return decodeScriptNum(b)
+}
+
+func (st *scriptStack) popNum() (int64, error) {
+	b, err := st.pop()
+	if err != nil {
+		return 0, err
+	}
+	return decodeScriptNum(b)
+}
+
+func (st *scriptStack) popBool() (bool, error) {
+	b, err := st.pop()
+	return asBool(b), err
+}
+
+// scriptEngine runs the scripts of input txInIndex of tx, which spends spent.
+type scriptEngine struct {
+	tx        *Transaction
+	txInIndex int
+	spent     TxOut
+	stack     scriptStack
+}
+
+// run executes s on the stack of e.
+func (e *scriptEngine) run(s Script) error {
+	if len(s) > MaxScriptSize {
+		return scriptError("script of %d bytes is too large", len(s))
+	}
+	ops, err := s.parse()
+	if err != nil {
+		return err
+	}
+	var conds []bool // one entry per enclosing IF: whether its current branch runs
+	executing := func() bool {
+		for _, c := range conds {
+			if !c {
+				return false
+			}
+		}
+		return true
+	}
+	var opCount int
+	for _, o := range ops {
+		if o.op > Op16 {
+			opCount++
+			if (o.op == OpCheckMultiSig || o.op == OpCheckMultiSigVerify) && executing() { // every key counts as an op
+				if n, err := e.stack.peekNum(0); err == nil && n > 0 {
+					opCount += int(min(n, int64(MaxMultiSigKeys)))
+				}
+			}
+			if opCount > MaxScriptOps {
+				return scriptError("more than %d opcodes", MaxScriptOps)
+			}
+		}
+		if len(o.data) > MaxScriptElementSize {
+			return scriptError("push of %d bytes is too large", len(o.data))
+		}
+		switch o.op {
+		case OpIf, OpNotIf:
+			cond := false
+			if executing() {
+				v, err := e.stack.popBool()
+				if err != nil {
+					return err
+				}
+				cond = v == (o.op == OpIf)
+			}
+			conds = append(conds, cond)
+			continue
+		case OpElse:
+			if len(conds) == 0 {
+				return scriptError("ELSE without IF")
+			}
+			conds[len(conds)-1] = !conds[len(conds)-1]
+			continue
+		case OpEndIf:
+			if len(conds) == 0 {
+				return scriptError("ENDIF without IF")
+			}
+			conds = conds[:len(conds)-1]
+			continue
+		}
+		if !executing() {
+			continue
+		}
+		if err := e.step(o); err != nil {
+			return err
+		}
+		if len(e.stack) > MaxStackSize {
+			return scriptError("more than %d stack elements", MaxStackSize)
+		}
+	}
+	if len(conds) != 0 {
+		return scriptError("IF without ENDIF")
+	}
+	return nil
+}
+
+// step executes a single opcode other than flow control.
+func (e *scriptEngine) step(o scriptOp) error {
+	st := &e.stack
+	switch {
+	case o.op == Op0 || o.data != nil:
+		st.push(o.data)
+		return nil
+	case o.op == Op1Negate || (o.op >= Op1 && o.op <= Op16):
+		st.push(encodeScriptNum(int64(o.op) - int64(Op1) + 1))
+		return nil
+	}
+
+	switch o.op {
+	case OpNop:
+	case OpVerify:
+		v, err := st.popBool()
+		if err != nil {
+			return err
+		}
+		if !v {
+			return scriptError("VERIFY failed")
+		}
+	case OpReturn:
+		return scriptError("RETURN")
+
+	case OpDrop:
+		_, err := st.pop()
+		return err
+	case OpDup, OpOver:
+		depth := 0
+		if o.op == OpOver {
+			depth = 1
+		}
+		b, err := st.peek(depth)
+		if err != nil {
+			return err
+		}
+		st.push(b)
+	case OpSwap:
+		a, err := st.pop()
+		if err != nil {
+			return err
+		}
+		b, err := st.pop()
+		if err != nil {
+			return err
+		}
+		st.push(a)
+		st.push(b)
+	case OpSize:
+		b, err := st.peek(0)
+		if err != nil {
+			return err
+		}
+		st.push(encodeScriptNum(int64(len(b))))
+
+	case OpEqual, OpEqualVerify:
+		a, err := st.pop()
+		if err != nil {
+			return err
+		}
+		b, err := st.pop()
+		if err != nil {
+			return err
+		}
+		if o.op == OpEqualVerify {
+			if !bytes.Equal(a, b) {
+				return scriptError("EQUALVERIFY failed")
+			}
+			return nil
+		}
+		st.push(fromBool(bytes.Equal(a, b)))
+
+	case Op1Add, Op1Sub, OpNegate, OpNot:
+		n, err := st.popNum()
+		if err != nil {
+			return err
+		}
+		switch o.op {
+		case Op1Add:
+			n++
+		case Op1Sub:
+			n--
+		case OpNegate:
+			n = -n
+		case OpNot:
+			if n == 0 {
+				n = 1
+			} else {
+				n = 0
+			}
+		}
+		st.push(encodeScriptNum(n))
+	case OpAdd, OpSub, OpBoolAnd, OpBoolOr, OpNumEqual, OpNumEqualVerify, OpNumNotEqual,
+		OpLessThan, OpGreaterThan, OpLessThanOrEqual, OpGreaterThanEqual, OpMin, OpMax:
+		b, err := st.popNum()
+		if err != nil {
+			return err
+		}
+		a, err := st.popNum()
+		if err != nil {
+			return err
+		}
+		var res []byte
+		switch o.op {
+		case OpAdd:
+			res = encodeScriptNum(a + b)
+		case OpSub:
+			res = encodeScriptNum(a - b)
+		case OpBoolAnd:
+			res = fromBool(a != 0 && b != 0)
+		case OpBoolOr:
+			res = fromBool(a != 0 || b != 0)
+		case OpNumEqual:
+			res = fromBool(a == b)
+		case OpNumEqualVerify:
+			if a != b {
+				return scriptError("NUMEQUALVERIFY failed")
+			}
+			return nil
+		case OpNumNotEqual:
+			res = fromBool(a != b)
+		case OpLessThan:
+			res = fromBool(a < b)
+		case OpGreaterThan:
+			res = fromBool(a > b)
+		case OpLessThanOrEqual:
+			res = fromBool(a <= b)
+		case OpGreaterThanEqual:
+			res = fromBool(a >= b)
+		case OpMin:
+			res = encodeScriptNum(min(a, b))
+		case OpMax:
+			res = encodeScriptNum(max(a, b))
+		}
+		st.push(res)
+	case OpWithin:
+		hi, err := st.popNum()
+		if err != nil {
+			return err
+		}
+		lo, err := st.popNum()
+		if err != nil {
+			return err
+		}
+		x, err := st.popNum()
+		if err != nil {
+			return err
+		}
+		st.push(fromBool(lo <= x && x < hi))
+
+	case OpSHA256:
+		b, err := st.pop()
+		if err != nil {
+			return err
+		}
+		h := sha256.Sum256(b)
+		st.push(h[:])
+	case OpCheckSchemeVerify:
+		scheme, err := st.popNum()
+		if err != nil {
+			return err
+		}
+		pub, err := st.peek(0)
+		if err != nil {
+			return err
+		}
+		key, err := ParsePublicKey(pub)
+		if err != nil {
+			return scriptError("CHECKSCHEMEVERIFY on an invalid key")
+		}
+		if s, _ := SchemeOf(key); int64(s) != scheme {
+			return scriptError("CHECKSCHEMEVERIFY failed: %s key, want %s", s, SignatureScheme(scheme))
+		}
+	case OpCheckSig, OpCheckSigVerify:
+		pub, err := st.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := st.pop()
+		if err != nil {
+			return err
+		}
+		valid := e.checkSig(sig, pub)
+		if o.op == OpCheckSigVerify {
+			if !valid {
+				return scriptError("CHECKSIGVERIFY failed")
+			}
+			return nil
+		}
+		st.push(fromBool(valid))
+	case OpCheckMultiSig, OpCheckMultiSigVerify:
+		valid, err := e.checkMultiSig()
+		if err != nil {
+			return err
+		}
+		if o.op == OpCheckMultiSigVerify {
+			if !valid {
+				return scriptError("CHECKMULTISIGVERIFY failed")
+			}
+			return nil
+		}
+		st.push(fromBool(valid))
+
+	default:
+		return scriptError("unknown opcode %s", o.op)
+	}
+	return nil
+}
+
+// checkSig reports whether sig, followed by its SigHashType, is a signature of the input by the encoded public key pub. Malformed keys and signatures are just invalid.
+func (e *scriptEngine) checkSig(sig, pub []byte) bool {
+	if len(sig) == 0 {
+		return false
+	}
+	key, err := ParsePublicKey(pub)
+	if err != nil {
+		return false
+	}
+	hash, err := e.tx.SignatureHash(e.txInIndex, e.spent, SigHashType(sig[len(sig)-1]))
+	if err != nil {
+		return false
+	}
+	return verifySignature(key, hash[:], sig[:len(sig)-1])
+}
+
+// checkMultiSig pops the operands of OpCheckMultiSig and reports whether the signatures are valid. Keys are tried in order and each one can match at most one signature, so the signatures have to be in the order of their keys.
+func (e *scriptEngine) checkMultiSig() (bool, error) {
+	st := &e.stack
+	n, err := st.popNum()
+	if err != nil {
+		return false, err
+	}
+	if n < 0 || n > int64(MaxMultiSigKeys) {
+		return false, scriptError("CHECKMULTISIG with %d keys", n)
+	}
+	pubs := make([][]byte, n)
+	for i := len(pubs) - 1; i >= 0; i-- {
+		if pubs[i], err = st.pop(); err != nil {
+			return false, err
+		}
+	}
+	m, err := st.popNum()
+	if err != nil {
+		return false, err
+	}
+	if m < 0 || m > n {
+		return false, scriptError("CHECKMULTISIG with %d of %d signatures", m, n)
+	}
+	sigs := make([][]byte, m)
+	for i := len(sigs) - 1; i >= 0; i-- {
+		if sigs[i], err = st.pop(); err != nil {
+			return false, err
+		}
+	}
+	for _, sig := range sigs {
+		for len(pubs) > 0 && !e.checkSig(sig, pubs[0]) {
+			pubs = pubs[1:]
+		}
+		if len(pubs) == 0 {
+			return false, nil
+		}
+		pubs = pubs[1:]
+	}
+	return true, nil
+}
+
+// verifyScript checks that unlocking, the script of input txInIndex of tx, unlocks spent.
+func verifyScript(unlocking Script, spent TxOut, tx *Transaction, txInIndex int) error {
+	if !unlocking.isPushOnly() {
+		return scriptError("unlocking script does not only push data")
+	}
+	e := &scriptEngine{tx: tx, txInIndex: txInIndex, spent: spent}
+	if err := e.run(unlocking); err != nil {
+		return err
+	}
+	if err := e.run(spent.lockingScript); err != nil {
+		return err
+	}
+	if len(e.stack) == 0 || !asBool(e.stack[len(e.stack)-1]) {
+		return scriptError("false on top of the stack")
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package basicblock
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestScriptNum(t *testing.T) {
+	for _, n := range []int64{0, 1, -1, 127, 128, -128, 255, 256, 1<<31 - 1, -(1<<31 - 1)} {
+		b := encodeScriptNum(n)
+		if got, err := decodeScriptNum(b); err != nil || got != n {
+			t.Errorf("decodeScriptNum(encodeScriptNum(%d)) = %d, %v", n, got, err)
+		}
+	}
+	if _, err := decodeScriptNum(encodeScriptNum(1 << 31)); err == nil {
+		t.Errorf("5 byte number was decoded")
+	}
+	if asBool([]byte{0, 0x80}) || asBool(nil) || !asBool([]byte{0, 1}) {
+		t.Errorf("asBool treats negative zero, empty or non-zero wrongly")
+	}
+}
+
+func TestScripts(t *testing.T) {
+	preimage := []byte("open sesame")
+	hash := sha256.Sum256(preimage)
+	build := func() *ScriptBuilder { return new(ScriptBuilder) }
+	for _, tt := range []struct {
+		name            string
+		unlocking, lock Script
+		valid           bool
+	}{
+		{"hash preimage", build().AddData(preimage).Script(), PayToHashPreimage(hash), true},
+		{"wrong preimage", build().AddData([]byte("open barley")).Script(), PayToHashPreimage(hash), false},
+		{"arithmetic", build().AddInt(1000).AddInt(-7).Script(), build().AddOp(OpAdd).AddInt(993).AddOp(OpNumEqual).Script(), true},
+		{"within", build().AddInt(5).Script(), build().AddInt(5).AddInt(6).AddOp(OpWithin).Script(), true},
+		{"size", build().AddData(preimage).Script(), build().AddOp(OpSize).AddInt(int64(len(preimage))).AddOp(OpEqualVerify).AddOp(OpSHA256).AddData(hash[:]).AddOp(OpEqual).Script(), true},
+		{"if", build().AddInt(1).Script(), build().AddOp(OpIf).AddInt(1).AddOp(OpElse).AddInt(0).AddOp(OpEndIf).Script(), true},
+		{"else", build().AddInt(0).Script(), build().AddOp(OpIf).AddInt(1).AddOp(OpElse).AddInt(0).AddOp(OpEndIf).Script(), false},
+		{"nested if skips", build().AddInt(0).Script(), build().AddOp(OpNotIf).AddInt(1).AddOp(OpElse).AddOp(OpIf).AddOp(OpReturn).AddOp(OpEndIf).AddOp(OpEndIf).Script(), true},
+		{"unbalanced if", build().AddInt(1).Script(), build().AddOp(OpIf).AddInt(1).Script(), false},
+		{"return", nil, build().AddOp(OpReturn).AddInt(1).Script(), false},
+		{"empty stack", nil, nil, false},
+		{"underflow", nil, build().AddOp(OpDup).Script(), false},
+		{"unknown opcode", nil, Script{0xff}, false},
+		{"truncated push", nil, Script{5, 1, 2}, false},
+		{"unlocking not push only", build().AddInt(1).AddOp(OpDup).Script(), build().AddOp(OpDrop).Script(), false},
+		{"number too long", build().AddData([]byte{1, 2, 3, 4, 5}).Script(), build().AddOp(Op1Add).Script(), false},
+		{"element too large", build().AddData(make([]byte, MaxScriptElementSize+1)).Script(), build().AddOp(OpDrop).AddInt(1).Script(), false},
+		{"too many ops", nil, append(build().AddInt(1).Script(), bytes.Repeat([]byte{byte(OpNop)}, MaxScriptOps+1)...), false},
+		{"script too large", nil, append(build().AddInt(1).Script(), bytes.Repeat([]byte{byte(Op1)}, MaxScriptSize)...), false},
+		{"stack too large", nil, bytes.Repeat([]byte{byte(Op1)}, MaxStackSize+1), false},
+	} {
+		if err := verifyScript(tt.unlocking, TxOut{lockingScript: tt.lock}, &Transaction{}, 0); (err == nil) != tt.valid {
+			t.Errorf("%s: verifyScript = %v, want valid %t", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestScriptString(t *testing.T) {
+	want := "DUP SHA256 0102 EQUALVERIFY CHECKSIG 16 -1 0"
+	s := new(ScriptBuilder).AddOp(OpDup).AddOp(OpSHA256).AddData([]byte{1, 2}).AddOp(OpEqualVerify).AddOp(OpCheckSig).AddInt(16).AddInt(-1).AddInt(0).Script()
+	if s.String() != want {
+		t.Errorf("String() = %q, want %q", s.String(), want)
+	}
+}
+
+func TestSpendScriptOutputs(t *testing.T) {
+	setParams(t, ChainParams{InitialSubsidy: 50, CoinbaseMaturity: 1})
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	coinbase := NewCoinbaseTransaction(priv.PublicKey, 1)
+	utxos := updateUnspentTxOuts([]Transaction{coinbase}, nil, atHeight(1))
+
+	// Lock 20 coins to a hash, payable only together with a signature by other.
+	preimage := []byte("invoice 42")
+	hash := sha256.Sum256(preimage)
+	otherPub, _ := MarshalPublicKey(other.PublicKey)
+	lock := new(ScriptBuilder).AddOp(OpSHA256).AddData(hash[:]).AddOp(OpEqualVerify).AddData(otherPub).AddOp(OpCheckSig).Script()
+	fund := NewTransaction([]TxIn{NewTxIn(coinbase.id, 0)}, []TxOut{NewTxOutWithScript(lock, 20), NewTxOut(priv.PublicKey, 29)})
+	checkFatal(fund.Sign(priv, utxos))
+	if !validateTransaction(fund, utxos, atHeight(2)) {
+		t.Fatalf("transaction paying to a script was invalid")
+	}
+	utxos = updateUnspentTxOuts([]Transaction{fund}, utxos, atHeight(2))
+
+	claim := NewTransaction([]TxIn{NewTxIn(fund.id, 0)}, []TxOut{NewTxOut(other.PublicKey, 19)})
+	if err := claim.Sign(other, utxos); err == nil {
+		t.Errorf("Sign signed an input that is not locked with PayToPubKeyHash")
+	}
+	sig, err := claim.Signature(other, 0, NewTxOutWithScript(lock, 20), SigHashAll)
+	checkFatal(err)
+	claim.SetUnlockingScript(0, new(ScriptBuilder).AddData(sig).AddData([]byte("invoice 41")).Script())
+	if validateTransaction(claim, utxos, atHeight(3)) {
+		t.Errorf("claim with the wrong preimage was valid")
+	}
+	claim.SetUnlockingScript(0, new(ScriptBuilder).AddData(sig).AddData(preimage).Script())
+	if !validateTransaction(claim, utxos, atHeight(3)) {
+		t.Errorf("claim with the preimage and signature was invalid")
+	}
+	wrongSig, err := claim.Signature(priv, 0, NewTxOutWithScript(lock, 20), SigHashAll)
+	checkFatal(err)
+	claim.SetUnlockingScript(0, new(ScriptBuilder).AddData(wrongSig).AddData(preimage).Script())
+	if validateTransaction(claim, utxos, atHeight(3)) {
+		t.Errorf("claim signed by the wrong key was valid")
+	}
+
+	// A PayToPubKeyHash output can not be spent by revealing another key.
+	steal := NewTransaction([]TxIn{NewTxIn(fund.id, 1)}, []TxOut{NewTxOut(other.PublicKey, 28)})
+	stealSig, err := steal.Signature(other, 0, NewTxOut(priv.PublicKey, 29), SigHashAll)
+	checkFatal(err)
+	steal.SetUnlockingScript(0, new(ScriptBuilder).AddData(stealSig).AddData(otherPub).Script())
+	if validateTransaction(steal, utxos, atHeight(3)) {
+		t.Errorf("PayToPubKeyHash output was spent with another key")
+	}
+}
@@ -0,0 +1,118 @@
+package basicblock
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// Every input is signed separately: the signature is over a digest of the transaction that commits to the input, its index and the amount and lock of the output it spends, so a signature can not be replayed on another input and a wallet can not be tricked into paying a larger fee than it thinks it spends. With SigHashAnyoneCanPay the index is left out, so that others can add their inputs in front of the signed one. Which other inputs and outputs the digest covers is chosen by the signer with a SigHashType, appended to the signature as in Bitcoin, so that several parties can build a transaction together.
+
+// SigHashType selects the parts of a transaction a signature commits to.
+type SigHashType byte
+
+const (
+	SigHashAll    SigHashType = 0x01 // every input and output; the usual
+	SigHashNone   SigHashType = 0x02 // every input and no output, whoever completes the transaction decides where the coins go
+	SigHashSingle SigHashType = 0x03 // every input and the output with the index of the signed input, the others may change
+
+	// SigHashAnyoneCanPay is combined with one of the above and only commits to the signed input, so others can add inputs of their own.
+	SigHashAnyoneCanPay SigHashType = 0x80
+)
+
+func (t SigHashType) base() SigHashType {
+	return t &^ SigHashAnyoneCanPay
+}
+
+func (t SigHashType) valid() bool {
+	return t.base() >= SigHashAll && t.base() <= SigHashSingle
+}
+
+func (t SigHashType) String() string {
+	var s string
+	switch t.base() {
+	case SigHashAll:
+		s = "ALL"
+	case SigHashNone:
+		s = "NONE"
+	case SigHashSingle:
+		s = "SINGLE"
+	default:
+		return fmt.Sprintf("SigHashType(%#x)", byte(t))
+	}
+	if t&SigHashAnyoneCanPay != 0 {
+		s += "|ANYONECANPAY"
+	}
+	return s
+}
+
+// SignatureHash returns the digest that a signature of input txInIndex with hashType signs. spent is the output the input spends. With SigHashSingle there has to be an output with the index of the input.
+func (tx *Transaction) SignatureHash(txInIndex int, spent TxOut, hashType SigHashType) ([32]byte, error) {
+	if txInIndex < 0 || txInIndex >= len(tx.txIns) {
+		return [32]byte{}, TxError{fmt.Sprintf("no input %d in a transaction with %d inputs", txInIndex, len(tx.txIns)), SigningError}
+	}
+	if !hashType.valid() {
+		return [32]byte{}, TxError{fmt.Sprintf("invalid signature hash type %#x", byte(hashType)), SigningError}
+	}
+	if hashType.base() == SigHashSingle && txInIndex >= len(tx.txOuts) {
+		return [32]byte{}, TxError{fmt.Sprintf("SIGHASH_SINGLE for input %d without a matching output", txInIndex), SigningError}
+	}
+
+	b := []byte{byte(hashType)}
+	b = binary.LittleEndian.AppendUint32(b, tx.lockTime)
+
+	ins := tx.txIns
+	if hashType&SigHashAnyoneCanPay != 0 {
+		ins = tx.txIns[txInIndex : txInIndex+1]
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(ins)))
+	for j, txIn := range ins {
+		b = append(b, txIn.txOutID[:]...)
+		b = binary.LittleEndian.AppendUint32(b, uint32(txIn.txOutIndex))
+		// With NONE and SINGLE the other inputs' sequences are left out, so their owners can still update them.
+		sequence := txIn.sequence
+		if hashType.base() != SigHashAll && len(ins) > 1 && j != txInIndex {
+			sequence = 0
+		}
+		b = binary.LittleEndian.AppendUint32(b, sequence)
+	}
+
+	// Under ANYONECANPAY the signed input is the only one committed to, which already ties the signature to it, and its index may change as inputs are added.
+	if hashType&SigHashAnyoneCanPay == 0 {
+		b = binary.LittleEndian.AppendUint32(b, uint32(txInIndex))
+	}
+	b = appendTxOut(b, spent)
+
+	var outs []TxOut
+	switch hashType.base() {
+	case SigHashAll:
+		outs = tx.txOuts
+	case SigHashSingle:
+		outs = tx.txOuts[txInIndex : txInIndex+1]
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(outs)))
+	for _, txOut := range outs {
+		b = appendTxOut(b, txOut)
+	}
+	return sha256.Sum256(b), nil
+}
+
+func appendTxOut(b []byte, txOut TxOut) []byte {
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(txOut.lockingScript)))
+	b = append(b, txOut.lockingScript...)
+	return binary.LittleEndian.AppendUint32(b, uint32(txOut.amount))
+}
+
+// Signature returns privateKey's signature of input txInIndex of tx, spending spent, with hashType appended, as OpCheckSig expects it in an unlocking script. privateKey is a P-256 ECDSA or an Ed25519 key.
+func (tx *Transaction) Signature(privateKey crypto.Signer, txInIndex int, spent TxOut, hashType SigHashType) ([]byte, error) {
+	hash, err := tx.SignatureHash(txInIndex, spent, hashType)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signDigest(privateKey, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	return append(sig, byte(hashType)), nil
+}
@@ -0,0 +1,138 @@
+package basicblock
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+)
+
+func TestSigHashTypes(t *testing.T) {
+	setParams(t, ChainParams{InitialSubsidy: 50, CoinbaseMaturity: 1})
+	alice, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	bob, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	coinbases := []Transaction{NewCoinbaseTransaction(alice.PublicKey, 1), NewCoinbaseTransaction(bob.PublicKey, 2), NewCoinbaseTransaction(alice.PublicKey, 3)}
+	var utxos []UnspentTxOut
+	for i, cb := range coinbases {
+		utxos = updateUnspentTxOuts([]Transaction{cb}, utxos, atHeight(int32(i+1)))
+	}
+	aliceIn, bobIn, aliceIn2 := NewTxIn(coinbases[0].id, 0), NewTxIn(coinbases[1].id, 0), NewTxIn(coinbases[2].id, 0)
+
+	// sign signs input i of a transaction with ins and outs, and returns that input so it can be moved into other transactions.
+	sign := func(ins []TxIn, outs []TxOut, i int, key *ecdsa.PrivateKey, hashType SigHashType) TxIn {
+		tx := NewTransaction(append([]TxIn{}, ins...), outs)
+		if err := tx.SignTxInWithHashType(i, key, utxos, hashType); err != nil {
+			t.Fatalf("signing with %s: %v", hashType, err)
+		}
+		return tx.txIns[i]
+	}
+	valid := func(ins []TxIn, outs []TxOut) bool {
+		return validateTransaction(NewTransaction(ins, outs), utxos, atHeight(4))
+	}
+
+	// ALL commits to every output.
+	outs := []TxOut{NewTxOut(bob.PublicKey, 30), NewTxOut(alice.PublicKey, 20)}
+	in := sign([]TxIn{aliceIn}, outs, 0, alice, SigHashAll)
+	if !valid([]TxIn{in}, outs) {
+		t.Errorf("ALL: signed transaction was invalid")
+	}
+	if valid([]TxIn{in}, []TxOut{NewTxOut(bob.PublicKey, 31), NewTxOut(alice.PublicKey, 19)}) {
+		t.Errorf("ALL: transaction with changed outputs was valid")
+	}
+
+	// NONE lets whoever completes the transaction choose the outputs.
+	in = sign([]TxIn{aliceIn}, nil, 0, alice, SigHashNone)
+	if !valid([]TxIn{in}, []TxOut{NewTxOut(bob.PublicKey, 50)}) {
+		t.Errorf("NONE: transaction with outputs added after signing was invalid")
+	}
+
+	// SINGLE commits to the output with the index of the input only: alice pays bob 30 from her input, bob adds his own input and output.
+	in = sign([]TxIn{aliceIn, bobIn}, []TxOut{NewTxOut(bob.PublicKey, 30)}, 0, alice, SigHashSingle|SigHashAnyoneCanPay)
+	complete := []TxOut{NewTxOut(bob.PublicKey, 30), NewTxOut(bob.PublicKey, 70)}
+	bobSigned := sign([]TxIn{in, bobIn}, complete, 1, bob, SigHashAll)
+	if !valid([]TxIn{in, bobSigned}, complete) {
+		t.Errorf("SINGLE|ANYONECANPAY: completed transaction was invalid")
+	}
+	changed := []TxOut{NewTxOut(bob.PublicKey, 40), NewTxOut(bob.PublicKey, 60)}
+	bobSigned = sign([]TxIn{in, bobIn}, changed, 1, bob, SigHashAll)
+	if valid([]TxIn{in, bobSigned}, changed) {
+		t.Errorf("SINGLE: transaction with a changed matching output was valid")
+	}
+	tx := NewTransaction([]TxIn{aliceIn, aliceIn2}, []TxOut{NewTxOut(bob.PublicKey, 90)})
+	if err := tx.SignTxInWithHashType(1, alice, utxos, SigHashSingle); err == nil {
+		t.Errorf("SINGLE signed an input without a matching output")
+	}
+
+	// ANYONECANPAY does not commit to the index of the input either: bob can put his input in front of alice's.
+	for _, hashType := range []SigHashType{SigHashAll | SigHashAnyoneCanPay, SigHashNone | SigHashAnyoneCanPay} {
+		outs := []TxOut{NewTxOut(bob.PublicKey, 80)}
+		in := sign([]TxIn{aliceIn}, outs, 0, alice, hashType)
+		bobSigned := sign([]TxIn{bobIn, in}, outs, 0, bob, SigHashAll)
+		if !valid([]TxIn{bobSigned, in}, outs) {
+			t.Errorf("%s: transaction with an input prepended after signing was invalid", hashType)
+		}
+	}
+
+	// Without ANYONECANPAY no inputs can be added.
+	in = sign([]TxIn{aliceIn}, []TxOut{NewTxOut(bob.PublicKey, 50)}, 0, alice, SigHashNone)
+	bobSigned = sign([]TxIn{in, bobIn}, []TxOut{NewTxOut(bob.PublicKey, 100)}, 1, bob, SigHashAll)
+	if valid([]TxIn{in, bobSigned}, []TxOut{NewTxOut(bob.PublicKey, 100)}) {
+		t.Errorf("NONE: transaction with an input added after signing was valid")
+	}
+
+	// A signature only unlocks the input it was made for, even where the same key locks both.
+	outs = []TxOut{NewTxOut(bob.PublicKey, 100)}
+	tx = NewTransaction([]TxIn{aliceIn, aliceIn2}, outs)
+	checkFatal(tx.Sign(alice, utxos))
+	if !validateTransaction(tx, utxos, atHeight(4)) {
+		t.Errorf("transaction spending two outputs of one key was invalid")
+	}
+	tx.txIns[0].unlockingScript, tx.txIns[1].unlockingScript = tx.txIns[1].unlockingScript, tx.txIns[0].unlockingScript
+	if validateTransaction(tx, utxos, atHeight(4)) {
+		t.Errorf("transaction with swapped signatures was valid")
+	}
+
+	// An invalid hash type makes the signature invalid.
+	tx = NewTransaction([]TxIn{aliceIn}, []TxOut{NewTxOut(bob.PublicKey, 50)})
+	checkFatal(tx.Sign(alice, utxos))
+	if !validateTransaction(tx, utxos, atHeight(4)) {
+		t.Errorf("signed transaction was invalid")
+	}
+	unlocking, _ := tx.txIns[0].unlockingScript.parse()
+	sig := append([]byte{}, unlocking[0].data...)
+	sig[len(sig)-1] = 0x04
+	tx.SetUnlockingScript(0, new(ScriptBuilder).AddData(sig).AddData(unlocking[1].data).Script())
+	if validateTransaction(tx, utxos, atHeight(4)) {
+		t.Errorf("signature with hash type 0x04 was valid")
+	}
+}
+
+func TestSignatureHashCommitsToSpentOutput(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tx := NewTransaction([]TxIn{NewTxIn([32]byte{1}, 0), NewTxIn([32]byte{2}, 0)}, []TxOut{NewTxOut(priv.PublicKey, 10)})
+	spent := NewTxOut(priv.PublicKey, 50)
+	h, err := tx.SignatureHash(0, spent, SigHashAll)
+	checkFatal(err)
+	for _, tt := range []struct {
+		name     string
+		index    int
+		spent    TxOut
+		hashType SigHashType
+	}{
+		{"input index", 1, spent, SigHashAll},
+		{"spent amount", 0, NewTxOut(priv.PublicKey, 51), SigHashAll},
+		{"spent lock", 0, NewTxOutWithScript(Script{byte(Op1)}, 50), SigHashAll},
+		{"hash type", 0, spent, SigHashAll | SigHashAnyoneCanPay},
+	} {
+		other, err := tx.SignatureHash(tt.index, tt.spent, tt.hashType)
+		if err != nil || other == h {
+			t.Errorf("%s: signature hash does not depend on it (err %v)", tt.name, err)
+		}
+	}
+	if _, err := tx.SignatureHash(2, spent, SigHashAll); err == nil {
+		t.Errorf("SignatureHash of a missing input succeeded")
+	}
+	if _, err := tx.SignatureHash(0, spent, 0); err == nil {
+		t.Errorf("SignatureHash with hash type 0 succeeded")
+	}
+}
@@ -0,0 +1,180 @@
+package basicblock
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Keys in scripts name their SignatureScheme in their first byte, and OpCheckSig dispatches on it. A lock that only holds the hash of a key declares the scheme that has to sign for it with OpCheckSchemeVerify, as does the address it was made from. ECDSA keys are P-256, carried compressed. ECDSA signatures are malleable: for a valid (r, s), (r, n - s) is valid too, and an ASN.1 encoding can be padded without changing its values. Anyone relaying a transaction could change its unlocking scripts that way, so only strictly DER encoded signatures with s at most half the curve order are valid, as in Bitcoin's BIP 62 and 66, and signing always produces those. Nonces come from crypto/rand, which crypto/ecdsa mixes with the private key and the digest, so a broken random source does not leak the key. Ed25519 signatures are deterministic, not malleable, and faster to verify.
+
+// SignatureScheme is a signature algorithm keys can be of.
+type SignatureScheme byte
+
+const (
+	ECDSA   SignatureScheme = iota + 1 // ECDSA on P-256, keys encoded as 0x02 or 0x03 and their x coordinate, DER signatures
+	Ed25519                            // Ed25519, keys encoded as ed25519KeyPrefix and the 32 byte key, 64 byte signatures
+)
+
+// ed25519KeyPrefix starts encoded Ed25519 keys. Unlike 0x02 and 0x03, it is no valid prefix of a compressed P-256 key.
+const ed25519KeyPrefix = 0xed
+
+func (s SignatureScheme) String() string {
+	switch s {
+	case ECDSA:
+		return "ecdsa"
+	case Ed25519:
+		return "ed25519"
+	}
+	return fmt.Sprintf("SignatureScheme(%d)", byte(s))
+}
+
+func (s SignatureScheme) valid() bool {
+	return s == ECDSA || s == Ed25519
+}
+
+// ParseSignatureScheme parses the name String returns.
+func ParseSignatureScheme(name string) (SignatureScheme, error) {
+	for _, s := range []SignatureScheme{ECDSA, Ed25519} {
+		if strings.EqualFold(name, s.String()) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown signature scheme %q, want ecdsa or ed25519", name)
+}
+
+// GenerateKey returns a new random private key of scheme s.
+func (s SignatureScheme) GenerateKey() (crypto.Signer, error) {
+	switch s {
+	case ECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case Ed25519:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	}
+	return nil, fmt.Errorf("cannot generate keys of %s", s)
+}
+
+// SchemeOf returns the SignatureScheme of pub, or false if it is of none.
+func SchemeOf(pub crypto.PublicKey) (SignatureScheme, bool) {
+	switch k := pub.(type) {
+	case ecdsa.PublicKey:
+		return ECDSA, k.Curve == elliptic.P256()
+	case *ecdsa.PublicKey:
+		return ECDSA, k.Curve == elliptic.P256()
+	case ed25519.PublicKey:
+		return Ed25519, len(k) == ed25519.PublicKeySize
+	}
+	return 0, false
+}
+
+// MarshalPublicKey encodes pub as scripts carry it: a P-256 ECDSA key, as value or pointer, compressed, that is its x coordinate prefixed by 2 or 3 for the parity of y, and an Ed25519 key prefixed by ed25519KeyPrefix.
+func MarshalPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	if k, ok := pub.(*ecdsa.PublicKey); ok && k != nil {
+		pub = *k
+	}
+	if _, ok := SchemeOf(pub); !ok {
+		return nil, TxError{fmt.Sprintf("invalid public key: %T is no P-256 ECDSA or Ed25519 key", pub), Generic}
+	}
+	if k, ok := pub.(ed25519.PublicKey); ok {
+		return append([]byte{ed25519KeyPrefix}, k...), nil
+	}
+	k := pub.(ecdsa.PublicKey)
+	b, err := k.Bytes()
+	if err != nil {
+		return nil, TxError{fmt.Sprintf("invalid public key: %v", err), Generic}
+	}
+	return append([]byte{2 | b[64]&1}, b[1:33]...), nil
+}
+
+// ParsePublicKey decodes a key produced by MarshalPublicKey, returning an *ecdsa.PublicKey or an ed25519.PublicKey.
+func ParsePublicKey(b []byte) (crypto.PublicKey, error) {
+	if len(b) == 1+ed25519.PublicKeySize && b[0] == ed25519KeyPrefix {
+		return ed25519.PublicKey(append([]byte{}, b[1:]...)), nil
+	}
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), b)
+	if x == nil {
+		return nil, TxError{"invalid public key: neither a compressed P-256 point nor an Ed25519 key", Generic}
+	}
+	uncompressed := append([]byte{4}, x.FillBytes(make([]byte, 32))...)
+	pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(uncompressed, y.FillBytes(make([]byte, 32))...))
+	if err != nil {
+		return nil, TxError{fmt.Sprintf("invalid public key: %v", err), Generic}
+	}
+	return pub, nil
+}
+
+// ecdsaSignature is the ASN.1 structure of an ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// signDigest signs hash with privateKey, a P-256 ECDSA or an Ed25519 key. ECDSA signatures come out low-S and DER encoded.
+func signDigest(privateKey crypto.Signer, hash []byte) ([]byte, error) {
+	switch k := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, TxError{"only P-256 ECDSA keys can sign", SigningError}
+		}
+		der, err := ecdsa.SignASN1(rand.Reader, k, hash)
+		if err != nil {
+			return nil, TxError{fmt.Sprintf("signing failed: %v", err), SigningError}
+		}
+		var sig ecdsaSignature
+		if _, err := asn1.Unmarshal(der, &sig); err != nil {
+			return nil, TxError{fmt.Sprintf("signing failed: %v", err), SigningError}
+		}
+		if n := curveOrder(); sig.S.Cmp(halfOrder(n)) > 0 {
+			sig.S.Sub(n, sig.S)
+		}
+		der, err = asn1.Marshal(sig)
+		if err != nil {
+			return nil, TxError{fmt.Sprintf("signing failed: %v", err), SigningError}
+		}
+		return der, nil
+	case ed25519.PrivateKey:
+		if len(k) != ed25519.PrivateKeySize {
+			return nil, TxError{"invalid Ed25519 private key", SigningError}
+		}
+		return ed25519.Sign(k, hash), nil
+	}
+	return nil, TxError{fmt.Sprintf("cannot sign with a %T", privateKey), SigningError}
+}
+
+// verifySignature reports whether sig is a signature of hash by pub: a strict DER, low-S one for ECDSA keys.
+func verifySignature(pub crypto.PublicKey, hash, sig []byte) bool {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		var parsed ecdsaSignature
+		rest, err := asn1.Unmarshal(sig, &parsed)
+		if err != nil || len(rest) > 0 || parsed.R.Sign() <= 0 || parsed.S.Sign() <= 0 {
+			return false
+		}
+		if der, err := asn1.Marshal(parsed); err != nil || !bytes.Equal(der, sig) {
+			return false
+		}
+		if parsed.S.Cmp(halfOrder(curveOrder())) > 0 {
+			return false
+		}
+		return ecdsa.VerifyASN1(k, hash, sig)
+	case ed25519.PublicKey:
+		// ed25519.Verify rejects non-canonical encodings of S, so Ed25519 signatures are not malleable either.
+		return len(sig) == ed25519.SignatureSize && ed25519.Verify(k, hash, sig)
+	}
+	return false
+}
+
+func curveOrder() *big.Int {
+	return elliptic.P256().Params().N
+}
+
+func halfOrder(n *big.Int) *big.Int {
+	return new(big.Int).Rsh(n, 1)
+}
@@ -0,0 +1,168 @@
+package basicblock
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"testing"
+)
+
+// highS returns sig with s replaced by n - s, which plain ECDSA also accepts.
+func highS(t *testing.T, sig []byte) []byte {
+	var parsed ecdsaSignature
+	if _, err := asn1.Unmarshal(sig, &parsed); err != nil {
+		t.Fatalf("signature does not parse: %v", err)
+	}
+	parsed.S.Sub(curveOrder(), parsed.S)
+	res, err := asn1.Marshal(parsed)
+	checkFatal(err)
+	return res
+}
+
+func TestSignVerify(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for i := 0; i < 50; i++ {
+		hash := sha256.Sum256([]byte{byte(i)})
+		sig, err := signDigest(priv, hash[:])
+		if err != nil {
+			t.Fatalf("signDigest: %v", err)
+		}
+		if !verifySignature(&priv.PublicKey, hash[:], sig) {
+			t.Fatalf("signature %x did not verify", sig)
+		}
+		if verifySignature(&other.PublicKey, hash[:], sig) {
+			t.Errorf("signature verified with another key")
+		}
+		wrong := sha256.Sum256([]byte{byte(i), 1})
+		if verifySignature(&priv.PublicKey, wrong[:], sig) {
+			t.Errorf("signature verified for another digest")
+		}
+		malleated := highS(t, sig)
+		if !ecdsa.VerifyASN1(&priv.PublicKey, hash[:], malleated) {
+			t.Fatalf("high-S signature is not valid ECDSA")
+		}
+		if verifySignature(&priv.PublicKey, hash[:], malleated) {
+			t.Errorf("high-S signature verified")
+		}
+		if verifySignature(&priv.PublicKey, hash[:], append(sig, 0)) {
+			t.Errorf("signature with a trailing byte verified")
+		}
+		// The same signature with the length of the sequence in the long form, valid BER but not DER.
+		long := append([]byte{sig[0], 0x81}, sig[1:]...)
+		if verifySignature(&priv.PublicKey, hash[:], long) {
+			t.Errorf("BER encoded signature verified")
+		}
+	}
+
+	p224, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	hash := sha256.Sum256(nil)
+	if _, err := signDigest(p224, hash[:]); err == nil {
+		t.Errorf("signDigest signed with a P-224 key")
+	}
+}
+
+func TestMalleatedSignatureIsInvalid(t *testing.T) {
+	setParams(t, ChainParams{InitialSubsidy: 50, CoinbaseMaturity: 1})
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	coinbase := NewCoinbaseTransaction(priv.PublicKey, 1)
+	utxos := updateUnspentTxOuts([]Transaction{coinbase}, nil, atHeight(1))
+	tx := NewTransaction([]TxIn{NewTxIn(coinbase.id, 0)}, []TxOut{NewTxOut(priv.PublicKey, 50)})
+	checkFatal(tx.Sign(priv, utxos))
+	if !validateTransaction(tx, utxos, atHeight(2)) {
+		t.Fatalf("signed transaction was invalid")
+	}
+	unlocking, _ := tx.txIns[0].unlockingScript.parse()
+	sig := unlocking[0].data
+	malleated := append(highS(t, sig[:len(sig)-1]), sig[len(sig)-1])
+	tx.SetUnlockingScript(0, new(ScriptBuilder).AddData(malleated).AddData(unlocking[1].data).Script())
+	if validateTransaction(tx, utxos, atHeight(2)) {
+		t.Errorf("transaction with a high-S signature was valid")
+	}
+}
+
+func TestEd25519(t *testing.T) {
+	setParams(t, ChainParams{InitialSubsidy: 50, CoinbaseMaturity: 1})
+	signer, err := Ed25519.GenerateKey()
+	checkFatal(err)
+	pub := signer.Public()
+	b, err := MarshalPublicKey(pub)
+	if err != nil || len(b) != 33 || b[0] != ed25519KeyPrefix {
+		t.Fatalf("MarshalPublicKey = %x, %v, want 33 bytes starting with %#x", b, err, ed25519KeyPrefix)
+	}
+	if parsed, err := ParsePublicKey(b); err != nil || !pub.(ed25519.PublicKey).Equal(parsed) {
+		t.Errorf("ParsePublicKey(MarshalPublicKey(pub)) = %v, want the key back", err)
+	}
+	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if NewAddress(pub) == NewAddress(ecdsaKey.PublicKey) {
+		t.Errorf("keys of different schemes share an address")
+	}
+	if a := NewAddress(pub); a.Scheme != Ed25519 {
+		t.Errorf("address of an Ed25519 key has scheme %s", a.Scheme)
+	}
+	if a, ok := PayToPubKeyHash(pub).Address(); !ok || a.Scheme != Ed25519 {
+		t.Errorf("lock to an Ed25519 key declares scheme %s, %t", a.Scheme, ok)
+	}
+
+	// The lock checks the scheme it declares: an output locked to the hash of an ECDSA key but declaring Ed25519 can not be spent with that key.
+	mislabeled := NewTransaction([]TxIn{NewTxIn([32]byte{1}, 0)}, []TxOut{NewTxOutWithScript(PayToAddress(Address{Scheme: Ed25519, Hash: PubKeyHash(ecdsaKey.PublicKey)}), 50)})
+	mislabeledUtxos := updateUnspentTxOuts([]Transaction{mislabeled}, nil, atHeight(1))
+	spend := NewTransaction([]TxIn{NewTxIn(mislabeled.id, 0)}, []TxOut{NewTxOut(ecdsaKey.PublicKey, 50)})
+	sig, err := spend.Signature(ecdsaKey, 0, mislabeled.txOuts[0], SigHashAll)
+	checkFatal(err)
+	spend.SetUnlockingScript(0, new(ScriptBuilder).AddData(sig).AddData(mustMarshal(t, ecdsaKey.PublicKey)).Script())
+	if validateTransaction(spend, mislabeledUtxos, atHeight(2)) {
+		t.Errorf("ECDSA key unlocked an output whose lock declares Ed25519")
+	}
+
+	// Spend an output locked to the Ed25519 key into a 2-of-2 multisig of both schemes, and that on.
+	coinbase := NewCoinbaseTransaction(pub, 1)
+	utxos := updateUnspentTxOuts([]Transaction{coinbase}, nil, atHeight(1))
+	lock, err := PayToMultiSig(2, []crypto.PublicKey{pub, ecdsaKey.PublicKey})
+	checkFatal(err)
+	fund := NewTransaction([]TxIn{NewTxIn(coinbase.id, 0)}, []TxOut{NewTxOutWithScript(lock, 50)})
+	checkFatal(fund.Sign(signer, utxos))
+	if !validateTransaction(fund, utxos, atHeight(2)) {
+		t.Fatalf("transaction signed with an Ed25519 key was invalid")
+	}
+	unlocking, _ := fund.txIns[0].unlockingScript.parse()
+	ecdsaSig, err := fund.Signature(ecdsaKey, 0, coinbase.txOuts[0], SigHashAll)
+	checkFatal(err)
+	forged := fund
+	forged.txIns = []TxIn{fund.txIns[0]}
+	forged.SetUnlockingScript(0, new(ScriptBuilder).AddData(ecdsaSig).AddData(unlocking[1].data).Script())
+	if validateTransaction(forged, utxos, atHeight(2)) {
+		t.Errorf("ECDSA signature unlocked an output locked to an Ed25519 key")
+	}
+	utxos = updateUnspentTxOuts([]Transaction{fund}, utxos, atHeight(2))
+
+	tx := NewTransaction([]TxIn{NewTxIn(fund.id, 0)}, []TxOut{NewTxOut(ecdsaKey.PublicKey, 50)})
+	var sigs [][]byte
+	for _, k := range []crypto.Signer{signer, ecdsaKey} {
+		sig, err := tx.Signature(k, 0, fund.txOuts[0], SigHashAll)
+		checkFatal(err)
+		sigs = append(sigs, sig)
+	}
+	tx.SetUnlockingScript(0, MultiSigUnlockingScript(sigs))
+	if !validateTransaction(tx, utxos, atHeight(3)) {
+		t.Errorf("multisig of an Ed25519 and an ECDSA key was invalid")
+	}
+	sigs[0][0] ^= 1
+	tx.SetUnlockingScript(0, MultiSigUnlockingScript(sigs))
+	if validateTransaction(tx, utxos, atHeight(3)) {
+		t.Errorf("multisig with a corrupted Ed25519 signature was valid")
+	}
+}
+
+func mustMarshal(t *testing.T, pub crypto.PublicKey) []byte {
+	t.Helper()
+	b, err := MarshalPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
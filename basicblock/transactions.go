@@ -2,45 +2,51 @@ package basicblock
 
 import (
 	"bytes"
-	"crypto/ecdsa"
+	"crypto"
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
 	"log"
-	"math/big"
+	"time"
 )
 
-// Coinbase is a transaction that contains only an output, but no inputs. This means that a coinbase transaction adds new coins to circulation. The coinbase transaction is always the first transaction in the block and it is included by the miner of the block. The coinbase reward acts as an incentive for the miners: if you find the block, you are able to collect 50 coins.
+// Coinbase is a transaction that contains only an output, but no inputs. This means that a coinbase transaction adds new coins to circulation. The coinbase transaction is always the first transaction in the block and it is included by the miner of the block. The coinbase reward acts as an incentive for the miners: if you find the block, you are able to collect the block subsidy, which starts at 50 coins and halves on the schedule set by Params. Its output can only be spent once it is Params.CoinbaseMaturity blocks deep, so that coins do not vanish from the chain of spends when a fork is abandoned.
 
+// CoinbaseAmount is the block subsidy before the first halving.
 const CoinbaseAmount = 50
 
-// TxOut consists of an address and an amount of coins. The address is an ECDSA public-key. This means that the user having the private-key of the referenced public-key (=address) will be able to access the coins.
+// TxOut consists of a locking script and an amount of coins. Whoever can provide an unlocking script that makes the locking script succeed can spend the coins. Usually this is PayToPubKeyHash of a public key, ECDSA or Ed25519, so the user having the private-key of the address will be able to access the coins.
 type TxOut struct {
-	address ecdsa.PublicKey
-	amount  int32
+	lockingScript Script
+	amount        int32
 }
 
-// TxIn provides the information "where" the coins are coming from. Each TxIn refers to an earlier output, from which the coins are 'unlocked', with the signature. These unlocked coins are now 'available' for the TxOuts. The signature gives proof that only the user, that has the private-key of the referred public-key ( =address) could have created the transaction.
+// TxIn provides the information "where" the coins are coming from. Each TxIn refers to an earlier output, from which the coins are 'unlocked' by the unlocking script. These unlocked coins are now 'available' for the TxOuts. For a PayToPubKeyHash output the unlocking script holds a signature and the public key, which gives proof that only the user that has the private-key of the address could have created the transaction.
 type TxIn struct {
-	txOutID    [32]byte
-	txOutIndex int32
-	r          *big.Int
-	s          *big.Int
+	txOutID         [32]byte
+	txOutIndex      int32
+	sequence        uint32 // relative lock, see SequenceDisableFlag
+	unlockingScript Script
 }
 
 // Transaction consists of two components: inputs and outputs. Outputs specify where the coins are sent and inputs give a proof that the coins that are actually sent exists in the first place and are owned by the sender.
 type Transaction struct {
-	// id is hash of txIns and txOuts
-	id     [32]byte
-	txIns  []TxIn
-	txOuts []TxOut
+	// id is hash of txIns, txOuts and lockTime
+	id       [32]byte
+	txIns    []TxIn
+	txOuts   []TxOut
+	lockTime uint32 // absolute lock, see LockTimeThreshold
 }
 
+// UnspentTxOut is a TxOut that has not been referenced by any TxIn yet. The set of all of them is derived from the blockchain and is all that is needed to validate new transactions.
 type UnspentTxOut struct {
-	txOutId    [32]byte // Transaction id
-	txOutIndex int32    // index of txOut in Transaction.txOuts
-	address    ecdsa.PublicKey
-	amount     int32
+	txOutId       [32]byte // Transaction id
+	txOutIndex    int32    // index of txOut in Transaction.txOuts
+	lockingScript Script
+	amount        int32
+	blockHeight   int32     // height of the block that created it
+	medianTime    time.Time // median time past of the blocks before that one
+	coinbase      bool
 }
 
 type TxErrorClass int
@@ -49,6 +55,7 @@ const (
 	Generic TxErrorClass = iota
 	TxNotFound
 	SigningError
+	InvalidTx
 )
 
 type TxError struct {
@@ -60,6 +67,128 @@ func (txerror TxError) Error() string {
 	return txerror.msg
 }
 
+// NewTxIn creates an unsigned TxIn spending output txOutIndex of transaction txOutID.
+func NewTxIn(txOutID [32]byte, txOutIndex int32) TxIn {
+	return TxIn{txOutID: txOutID, txOutIndex: txOutIndex}
+}
+
+// NewTxOut creates a TxOut sending amount coins to address, locked with PayToPubKeyHash.
+func NewTxOut(address crypto.PublicKey, amount int32) TxOut {
+	return TxOut{PayToPubKeyHash(address), amount}
+}
+
+// NewTxOutWithScript creates a TxOut sending amount coins to whoever can unlock lockingScript.
+func NewTxOutWithScript(lockingScript Script, amount int32) TxOut {
+	return TxOut{lockingScript, amount}
+}
+
+// NewTransaction creates an unsigned transaction and computes its id.
+func NewTransaction(txIns []TxIn, txOuts []TxOut) Transaction {
+	tx := Transaction{txIns: txIns, txOuts: txOuts}
+	tx.id = tx.getID()
+	return tx
+}
+
+// NewCoinbaseTransaction creates the coinbase transaction for the block at blockHeight, paying the block subsidy to address.
+func NewCoinbaseTransaction(address crypto.PublicKey, blockHeight int32) Transaction {
+	return NewTransaction([]TxIn{TxIn{txOutIndex: blockHeight}}, []TxOut{NewTxOut(address, Params.BlockSubsidy(blockHeight))})
+}
+
+// ID returns the hash of the transaction's inputs, outputs and lock time.
+func (tx *Transaction) ID() [32]byte {
+	return tx.id
+}
+
+// IsCoinbase reports whether tx is shaped like a coinbase: a single input that spends no output.
+func (tx *Transaction) IsCoinbase() bool {
+	return len(tx.txIns) == 1 && tx.txIns[0].txOutID == [32]byte{}
+}
+
+// TxIns returns the inputs of the transaction.
+func (tx *Transaction) TxIns() []TxIn {
+	return tx.txIns
+}
+
+// TxOuts returns the outputs of the transaction.
+func (tx *Transaction) TxOuts() []TxOut {
+	return tx.txOuts
+}
+
+// TxOutID returns the id of the transaction holding the output spent by txIn.
+func (txIn *TxIn) TxOutID() [32]byte {
+	return txIn.txOutID
+}
+
+// TxOutIndex returns the index of the output spent by txIn. For coinbase transactions this is the block height.
+func (txIn *TxIn) TxOutIndex() int32 {
+	return txIn.txOutIndex
+}
+
+// Sequence returns the relative lock of txIn.
+func (txIn *TxIn) Sequence() uint32 {
+	return txIn.sequence
+}
+
+// UnlockingScript returns the script that unlocks the output spent by txIn.
+func (txIn *TxIn) UnlockingScript() Script {
+	return txIn.unlockingScript
+}
+
+// LockingScript returns the script that the coins of txOut are locked with.
+func (txOut *TxOut) LockingScript() Script {
+	return txOut.lockingScript
+}
+
+// Amount returns the number of coins in txOut.
+func (txOut *TxOut) Amount() int32 {
+	return txOut.amount
+}
+
+// TxOutID returns the id of the transaction that created the output.
+func (utxo *UnspentTxOut) TxOutID() [32]byte {
+	return utxo.txOutId
+}
+
+// TxOutIndex returns the index of the output in its transaction.
+func (utxo *UnspentTxOut) TxOutIndex() int32 {
+	return utxo.txOutIndex
+}
+
+// LockingScript returns the script that the unspent coins are locked with.
+func (utxo *UnspentTxOut) LockingScript() Script {
+	return utxo.lockingScript
+}
+
+// Amount returns the number of unspent coins.
+func (utxo *UnspentTxOut) Amount() int32 {
+	return utxo.amount
+}
+
+// txOut returns the output as it was created, which is what signatures of inputs spending it commit to.
+func (utxo *UnspentTxOut) txOut() TxOut {
+	return TxOut{utxo.lockingScript, utxo.amount}
+}
+
+// BlockHeight returns the height of the block that created the output.
+func (utxo *UnspentTxOut) BlockHeight() int32 {
+	return utxo.blockHeight
+}
+
+// MedianTime returns the median time past of the blocks before the one that created the output. Relative time locks count from it.
+func (utxo *UnspentTxOut) MedianTime() time.Time {
+	return utxo.medianTime
+}
+
+// IsCoinbase reports whether the output was created by a coinbase transaction.
+func (utxo *UnspentTxOut) IsCoinbase() bool {
+	return utxo.coinbase
+}
+
+// IsMature reports whether the output may be spent by a transaction in the block at blockHeight. Only coinbase outputs have to wait.
+func (utxo *UnspentTxOut) IsMature(blockHeight int32) bool {
+	return !utxo.coinbase || blockHeight-utxo.blockHeight >= Params.CoinbaseMaturity
+}
+
 func findUnspentTxOut(txOutId [32]byte, txOutIndex int32, aUnspentTxOuts []UnspentTxOut) (UnspentTxOut, error) {
 	for _, aUnspentTxOut := range aUnspentTxOuts {
 		if aUnspentTxOut.txOutId == txOutId && aUnspentTxOut.txOutIndex == txOutIndex {
@@ -75,12 +204,6 @@ func checkBinaryWrite(err error) {
 	}
 }
 
-func checkGobEncode(err error) {
-	if err != nil {
-		log.Fatalf("GobEncode failed with error %v", err)
-	}
-}
-
 func (tx Transaction) getID() [32]byte {
 	h := sha256.New()
 	var hashInput bytes.Buffer
@@ -89,24 +212,22 @@ func (tx Transaction) getID() [32]byte {
 		var idx bytes.Buffer
 		err := binary.Write(&idx, binary.LittleEndian, txIn.txOutIndex)
 		checkBinaryWrite(err)
+		err = binary.Write(&idx, binary.LittleEndian, txIn.sequence)
+		checkBinaryWrite(err)
 		hashInput.Write(idx.Bytes())
 	}
 	for _, txOut := range tx.txOuts {
 		var b bytes.Buffer
-		xMarshalled, err := txOut.address.X.GobEncode()
-		checkGobEncode(err)
-		err = binary.Write(&b, binary.LittleEndian, xMarshalled)
-		checkBinaryWrite(err)
-		yMarshalled, err := txOut.address.Y.GobEncode()
-		checkGobEncode(err)
-		err = binary.Write(&b, binary.LittleEndian, yMarshalled)
+		err := binary.Write(&b, binary.LittleEndian, uint16(len(txOut.lockingScript)))
 		checkBinaryWrite(err)
-
+		b.Write(txOut.lockingScript)
 		err = binary.Write(&b, binary.LittleEndian, txOut.amount)
 		checkBinaryWrite(err)
 		hashInput.Write(b.Bytes())
 	}
-	_, err := h.Write(hashInput.Bytes())
+	err := binary.Write(&hashInput, binary.LittleEndian, tx.lockTime)
+	checkBinaryWrite(err)
+	_, err = h.Write(hashInput.Bytes())
 	if err != nil {
 		log.Fatalln("sha256 failed")
 	}
@@ -121,40 +242,97 @@ func (tx Transaction) getID() [32]byte {
 	return res
 }
 
-func (tx Transaction) signTxIn(txInIndex int32, privateKey ecdsa.PrivateKey, aUnspentTxOuts []UnspentTxOut) (*big.Int, *big.Int, error) {
+// witnessHash commits to the whole transaction: its id, which leaves the unlocking scripts out so that signatures can cover it, followed by every unlocking script. Blocks commit to transactions through it, so nobody relaying a block can swap the scripts that decide whether its transactions are valid.
+func (tx Transaction) witnessHash() [32]byte {
+	b := append([]byte{}, tx.id[:]...)
+	for _, txIn := range tx.txIns {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(txIn.unlockingScript)))
+		b = append(b, txIn.unlockingScript...)
+	}
+	return sha256.Sum256(b)
+}
+
+// merkleLeaf is the transaction's leaf in the payload tree of its block.
+func (tx Transaction) merkleLeaf() [32]byte {
+	h := tx.witnessHash()
+	return merkleLeaf(merkleTxPrefix, h[:])
+}
+
+// Clone returns a copy of tx whose unlocking scripts can be set without changing tx.
+func (tx *Transaction) Clone() Transaction {
+	res := *tx
+	res.txIns = append([]TxIn{}, tx.txIns...)
+	res.txOuts = append([]TxOut{}, tx.txOuts...)
+	return res
+}
+
+// SetUnlockingScript sets the unlocking script of input txInIndex, for outputs with locks other than PayToPubKeyHash. It does not change the id of tx.
+func (tx *Transaction) SetUnlockingScript(txInIndex int, unlockingScript Script) {
+	tx.txIns[txInIndex].unlockingScript = unlockingScript
+}
+
+func (tx Transaction) signTxIn(txInIndex int, privateKey crypto.Signer, aUnspentTxOuts []UnspentTxOut, hashType SigHashType) (Script, error) {
 	txIn := tx.txIns[txInIndex]
-	dataToSign := tx.id
 	referencedUnspentTxOut, err := findUnspentTxOut(txIn.txOutID, txIn.txOutIndex, aUnspentTxOuts)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if a, ok := referencedUnspentTxOut.lockingScript.Address(); !ok || a != NewAddress(privateKey.Public()) {
+		return nil, TxError{"trying to sign an input with private key that does not match the address that is referenced in txIn", SigningError}
+	}
+	sig, err := tx.Signature(privateKey, txInIndex, referencedUnspentTxOut.txOut(), hashType)
+	if err != nil {
+		return nil, err
 	}
-	referencedAddress := referencedUnspentTxOut.address
-	if privateKey.Public() != referencedAddress {
-		return nil, nil, TxError{"trying to sign an input with private key that does not match the address that is referenced in txIn", SigningError}
+	pub, err := MarshalPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, TxError{fmt.Sprintf("invalid public key: %v", err), SigningError}
 	}
-	r, s, err := ecdsa.Sign(nil, &privateKey, dataToSign[:])
+	return new(ScriptBuilder).AddData(sig).AddData(pub).Script(), nil
+}
+
+// SignTxIn signs input txInIndex of tx with privateKey and SigHashAll, for transactions spending outputs of several keys. aUnspentTxOuts must contain the output it spends, which has to be locked with PayToPubKeyHash of privateKey.
+func (tx *Transaction) SignTxIn(txInIndex int, privateKey crypto.Signer, aUnspentTxOuts []UnspentTxOut) error {
+	return tx.SignTxInWithHashType(txInIndex, privateKey, aUnspentTxOuts, SigHashAll)
+}
+
+// SignTxInWithHashType is SignTxIn committing only to the parts of tx selected by hashType, for transactions that others complete.
+func (tx *Transaction) SignTxInWithHashType(txInIndex int, privateKey crypto.Signer, aUnspentTxOuts []UnspentTxOut, hashType SigHashType) error {
+	unlockingScript, err := tx.signTxIn(txInIndex, privateKey, aUnspentTxOuts, hashType)
 	if err != nil {
-		return nil, nil, err
+		return err
+	}
+	tx.txIns[txInIndex].unlockingScript = unlockingScript
+	return nil
+}
+
+// Sign signs every input of tx with privateKey. aUnspentTxOuts must contain the outputs that are being spent, which have to be locked with PayToPubKeyHash of privateKey.
+func (tx *Transaction) Sign(privateKey crypto.Signer, aUnspentTxOuts []UnspentTxOut) error {
+	for i := range tx.txIns {
+		if err := tx.SignTxIn(i, privateKey, aUnspentTxOuts); err != nil {
+			return err
+		}
 	}
-	return r, s, nil
+	return nil
 }
 
-func updateUnspentTxOuts(txs []Transaction, aUnspentTxOuts []UnspentTxOut) []UnspentTxOut {
+// updateUnspentTxOuts applies the transactions of the block at ctx to aUnspentTxOuts.
+func updateUnspentTxOuts(txs []Transaction, aUnspentTxOuts []UnspentTxOut, ctx BlockContext) []UnspentTxOut {
 	var newUnspentTxOuts []UnspentTxOut
 	for _, tx := range txs {
 		for idx, txOut := range tx.txOuts {
-			newUnspentTxOuts = append(newUnspentTxOuts, UnspentTxOut{tx.id, int32(idx), txOut.address, txOut.amount})
+			newUnspentTxOuts = append(newUnspentTxOuts, UnspentTxOut{txOutId: tx.id, txOutIndex: int32(idx), lockingScript: txOut.lockingScript, amount: txOut.amount, blockHeight: ctx.Height, medianTime: ctx.MedianTimePast, coinbase: tx.IsCoinbase()})
 		}
 	}
 	var consumedTxOuts []UnspentTxOut
 	for _, tx := range txs {
 		for _, txIn := range tx.txIns {
-			consumedTxOuts = append(consumedTxOuts, UnspentTxOut{txIn.txOutID, txIn.txOutIndex, ecdsa.PublicKey{}, 0})
+			consumedTxOuts = append(consumedTxOuts, UnspentTxOut{txOutId: txIn.txOutID, txOutIndex: txIn.txOutIndex})
 		}
 	}
 	var resultingUnspentTxOuts []UnspentTxOut
 	for _, utxo := range aUnspentTxOuts {
-		utxo, err := findUnspentTxOut(utxo.txOutId, utxo.txOutIndex, consumedTxOuts)
+		_, err := findUnspentTxOut(utxo.txOutId, utxo.txOutIndex, consumedTxOuts)
 		txerr, ok := err.(TxError)
 		if ok && txerr.kind == TxNotFound {
 			resultingUnspentTxOuts = append(resultingUnspentTxOuts, utxo)
@@ -164,11 +342,126 @@ func updateUnspentTxOuts(txs []Transaction, aUnspentTxOuts []UnspentTxOut) []Uns
 	return resultingUnspentTxOuts
 }
 
-// blockHeight is the number of blocks in the chain between it and the genesis block. (So the genesis block has height 0.)
-func validateCoinbaseTx(tx Transaction, blockHeight int32) bool {
-	if tx.getID() != tx.id || tx.txIns[0].txOutIndex != blockHeight || len(tx.txIns) != 1 || len(tx.txOuts) != 1 || tx.txOuts[0].amount != CoinbaseAmount {
-		fmt.Printf("validateCoinbaseTx failed \n id not equal = %t, txOutIndex not equal blockHeight = %t, length txIns not equal 1 = %t, length txOuts not equal 1 = %t, amount not equal CoinbaseAmount = %t \n", tx.getID() != tx.id, tx.txIns[0].txOutIndex != blockHeight, len(tx.txIns) != 1, len(tx.txOuts) != 1, tx.txOuts[0].amount != CoinbaseAmount)
+// validateCoinbaseTx checks the coinbase of a block whose other transactions pay fees. Its input may not name an output, or its own outputs would not be recognized as a coinbase's and could be spent before they mature. It may claim at most the subsidy plus the fees. blockHeight is the number of blocks in the chain between it and the genesis block. (So the genesis block has height 0.)
+func validateCoinbaseTx(tx Transaction, blockHeight int32, fees int64) bool {
+	if !tx.IsCoinbase() || len(tx.txOuts) != 1 {
+		chainLog.Debug("invalid coinbase: must have exactly one txIn spending no output and one txOut", "txIns", len(tx.txIns), "txOuts", len(tx.txOuts))
+		return false
+	}
+	if tx.getID() != tx.id || tx.txIns[0].txOutIndex != blockHeight || tx.txOuts[0].amount < 0 || int64(tx.txOuts[0].amount) > int64(Params.BlockSubsidy(blockHeight))+fees {
+		chainLog.Debug("invalid coinbase", "idMismatch", tx.getID() != tx.id, "txOutIndex", tx.txIns[0].txOutIndex, "blockHeight", blockHeight, "amount", tx.txOuts[0].amount, "fees", fees)
+		return false
+	}
+	return true
+}
+
+func hasDuplicateTxIns(txIns []TxIn) bool {
+	seen := make(map[[32]byte]map[int32]bool)
+	for _, txIn := range txIns {
+		if seen[txIn.txOutID] == nil {
+			seen[txIn.txOutID] = make(map[int32]bool)
+		}
+		if seen[txIn.txOutID][txIn.txOutIndex] {
+			return true
+		}
+		seen[txIn.txOutID][txIn.txOutIndex] = true
+	}
+	return false
+}
+
+func validateTxIn(txInIndex int, tx Transaction, aUnspentTxOuts []UnspentTxOut, ctx BlockContext) bool {
+	txIn := tx.txIns[txInIndex]
+	referencedUnspentTxOut, err := findUnspentTxOut(txIn.txOutID, txIn.txOutIndex, aUnspentTxOuts)
+	if err != nil {
+		chainLog.Debug("invalid txIn: referenced txOut not found", "txOutId", fmt.Sprintf("%x", txIn.txOutID), "txOutIndex", txIn.txOutIndex)
+		return false
+	}
+	if !referencedUnspentTxOut.IsMature(ctx.Height) {
+		chainLog.Debug("invalid txIn: spends an immature coinbase", "txOutId", fmt.Sprintf("%x", txIn.txOutID), "createdAt", referencedUnspentTxOut.blockHeight, "blockHeight", ctx.Height)
+		return false
+	}
+	if !txIn.sequenceLockPassed(referencedUnspentTxOut, ctx) {
+		chainLog.Debug("invalid txIn: relative lock not passed", "txOutId", fmt.Sprintf("%x", txIn.txOutID), "sequence", txIn.sequence, "createdAt", referencedUnspentTxOut.blockHeight, "blockHeight", ctx.Height)
+		return false
+	}
+	if err := verifyScript(txIn.unlockingScript, referencedUnspentTxOut.txOut(), &tx, txInIndex); err != nil {
+		chainLog.Debug("invalid txIn: does not unlock the referenced txOut", "txOutId", fmt.Sprintf("%x", txIn.txOutID), "err", err)
+		return false
+	}
+	return true
+}
+
+// validateTransaction checks that a regular (non-coinbase) transaction for the block at ctx has the correct id, is not locked, only spends unspent, mature outputs that its unlocking scripts unlock, and does not create coins. What it spends beyond its outputs is its fee.
+func validateTransaction(tx Transaction, aUnspentTxOuts []UnspentTxOut, ctx BlockContext) bool {
+	if tx.getID() != tx.id {
+		chainLog.Debug("invalid tx: wrong id", "tx", fmt.Sprintf("%x", tx.id))
+		return false
+	}
+	if !tx.isFinal(ctx) {
+		chainLog.Debug("invalid tx: lock time not reached", "tx", fmt.Sprintf("%x", tx.id), "lockTime", tx.lockTime, "blockHeight", ctx.Height, "medianTimePast", ctx.MedianTimePast)
+		return false
+	}
+	if tx.Size() > MaxTxSize {
+		chainLog.Debug("invalid tx: too large", "tx", fmt.Sprintf("%x", tx.id), "size", tx.Size())
+		return false
+	}
+	if len(tx.txIns) == 0 || hasDuplicateTxIns(tx.txIns) {
+		chainLog.Debug("invalid tx: missing or duplicate txIns", "tx", fmt.Sprintf("%x", tx.id))
+		return false
+	}
+	var totalTxInValues int64
+	for i, txIn := range tx.txIns {
+		if !validateTxIn(i, tx, aUnspentTxOuts, ctx) {
+			chainLog.Debug("invalid tx: invalid txIn", "tx", fmt.Sprintf("%x", tx.id))
+			return false
+		}
+		utxo, _ := findUnspentTxOut(txIn.txOutID, txIn.txOutIndex, aUnspentTxOuts)
+		totalTxInValues += int64(utxo.amount)
+	}
+	var totalTxOutValues int64
+	for _, txOut := range tx.txOuts {
+		if txOut.amount <= 0 {
+			chainLog.Debug("invalid tx: non-positive txOut amount", "tx", fmt.Sprintf("%x", tx.id))
+			return false
+		}
+		totalTxOutValues += int64(txOut.amount)
+	}
+	if totalTxInValues < totalTxOutValues {
+		chainLog.Debug("invalid tx: txOut values exceed txIn values", "tx", fmt.Sprintf("%x", tx.id), "txIns", totalTxInValues, "txOuts", totalTxOutValues)
 		return false
 	}
 	return true
 }
+
+// validateBlockTransactions checks the transactions of the block at ctx. A block may carry no transactions at all; otherwise the first one must be the coinbase.
+func validateBlockTransactions(txs []Transaction, aUnspentTxOuts []UnspentTxOut, ctx BlockContext) bool {
+	if len(txs) == 0 {
+		return true
+	}
+	var txIns []TxIn
+	for _, tx := range txs[1:] {
+		txIns = append(txIns, tx.txIns...)
+	}
+	if hasDuplicateTxIns(txIns) {
+		chainLog.Debug("invalid block: spends the same txOut twice", "blockHeight", ctx.Height)
+		return false
+	}
+	var fees int64
+	view := aUnspentTxOuts
+	for _, tx := range txs[1:] { // a transaction may spend the outputs of those before it
+		if !validateTransaction(tx, view, ctx) {
+			return false
+		}
+		fees += tx.Fee(view)
+		view = updateUnspentTxOuts([]Transaction{tx}, view, ctx)
+	}
+	return validateCoinbaseTx(txs[0], ctx.Height, fees)
+}
+
+// processTransactions validates the transactions of the block at ctx and returns the resulting set of unspent outputs.
+func processTransactions(txs []Transaction, aUnspentTxOuts []UnspentTxOut, ctx BlockContext) ([]UnspentTxOut, error) {
+	if !validateBlockTransactions(txs, aUnspentTxOuts, ctx) {
+		return nil, TxError{fmt.Sprintf("invalid transactions in block at height %d", ctx.Height), InvalidTx}
+	}
+	return updateUnspentTxOuts(txs, aUnspentTxOuts, ctx), nil
+}
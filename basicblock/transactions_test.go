@@ -1,9 +1,12 @@
 package basicblock
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"log"
 	"testing"
@@ -15,14 +18,14 @@ func checkFatal(err error) {
 	}
 }
 func TestValidateCoinbaseTx(t *testing.T) {
-	// privateKeyFrom, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	// privateKeyFrom, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	// checkFatal(err)
 	// publicKeyFrom := privateKeyFrom.PublicKey
 
-	privateKeyTo, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	privateKeyTo, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	checkFatal(err)
 	publicKeyTo := privateKeyTo.PublicKey
-	txOut := TxOut{publicKeyTo, CoinbaseAmount}
+	txOut := NewTxOut(publicKeyTo, CoinbaseAmount)
 	tx := Transaction{
 		txIns: []TxIn{
 			TxIn{txOutIndex: int32(12)},
@@ -30,8 +33,150 @@ func TestValidateCoinbaseTx(t *testing.T) {
 		txOuts: []TxOut{txOut},
 	}
 	tx.id = tx.getID()
-	if !validateCoinbaseTx(tx, 12) {
+	if !validateCoinbaseTx(tx, 12, 0) {
 		fmt.Printf("validateCoinbaseTx failed\n")
 		t.Fail()
 	}
+	withFees := coinbaseWithFees(t, publicKeyTo, 12, 3)
+	if !validateCoinbaseTx(withFees, 12, 3) || !validateCoinbaseTx(withFees, 12, 5) {
+		t.Errorf("coinbase claiming the fees was invalid")
+	}
+	if validateCoinbaseTx(withFees, 12, 2) {
+		t.Errorf("coinbase claiming more than the fees was valid")
+	}
+
+	// An input naming an output hides the coinbase from the maturity rule.
+	disguised := NewTransaction([]TxIn{NewTxIn([32]byte{1}, 1)}, []TxOut{NewTxOut(publicKeyTo, CoinbaseAmount)})
+	if validateCoinbaseTx(disguised, 1, 0) {
+		t.Errorf("coinbase whose input names an output was valid")
+	}
+	blk := newHarness(t).next(&GenesisBlock, "", disguised)
+	if (BlockChain{GenesisBlock, blk}).IsValid() {
+		t.Errorf("chain with a coinbase whose input names an output was valid")
+	}
+}
+
+func TestSignAndValidateTransaction(t *testing.T) {
+	privateKeyFrom, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	checkFatal(err)
+	privateKeyTo, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	checkFatal(err)
+
+	coinbase := NewCoinbaseTransaction(privateKeyFrom.PublicKey, 1)
+	utxos := updateUnspentTxOuts([]Transaction{coinbase}, nil, atHeight(1))
+	height := 1 + Params.CoinbaseMaturity
+
+	tx := NewTransaction([]TxIn{NewTxIn(coinbase.id, 0)}, []TxOut{NewTxOut(privateKeyTo.PublicKey, 20), NewTxOut(privateKeyFrom.PublicKey, 29)})
+	if validateTransaction(tx, utxos, atHeight(height)) {
+		t.Errorf("unsigned transaction was valid")
+	}
+	if err := tx.Sign(privateKeyTo, utxos); err == nil {
+		t.Errorf("signing with the wrong key succeeded")
+	}
+	if err := tx.Sign(privateKeyFrom, utxos); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if !validateTransaction(tx, utxos, atHeight(height)) {
+		t.Errorf("signed transaction was invalid")
+	}
+	if validateTransaction(tx, utxos, atHeight(height-1)) {
+		t.Errorf("transaction spending an immature coinbase was valid")
+	}
+
+	tooMuch := NewTransaction([]TxIn{NewTxIn(coinbase.id, 0)}, []TxOut{NewTxOut(privateKeyTo.PublicKey, CoinbaseAmount+1)})
+	checkFatal(tooMuch.Sign(privateKeyFrom, utxos))
+	if validateTransaction(tooMuch, utxos, atHeight(height)) {
+		t.Errorf("transaction creating coins was valid")
+	}
+
+	if tx.Fee(utxos) != 1 {
+		t.Errorf("fee = %d, want 1", tx.Fee(utxos))
+	}
+	free := NewTransaction([]TxIn{NewTxIn(coinbase.id, 0)}, []TxOut{NewTxOut(privateKeyTo.PublicKey, CoinbaseAmount)})
+	checkFatal(free.Sign(privateKeyFrom, utxos))
+	if !validateTransaction(free, utxos, atHeight(height)) {
+		t.Errorf("transaction without a fee was invalid")
+	}
+	if _, _, err := (TransactionPool{}).Add(free, utxos, atHeight(height)); err == nil {
+		t.Errorf("pool accepted a transaction below the minimum relay fee")
+	}
+	if _, _, err := (TransactionPool{}).Add(tx, utxos, atHeight(height-1)); err == nil {
+		t.Errorf("pool accepted a transaction spending an immature coinbase")
+	}
+	pool, _, err := TransactionPool{}.Add(tx, utxos, atHeight(height))
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, _, err := pool.Add(tx, utxos, atHeight(height)); err == nil {
+		t.Errorf("pool accepted a double spend")
+	}
+	if len(pool.Update(utxos, atHeight(height-1))) != 0 {
+		t.Errorf("pool kept a transaction whose inputs became immature")
+	}
+	if len(pool.Update(updateUnspentTxOuts([]Transaction{tx}, utxos, atHeight(height)), atHeight(height+1))) != 0 {
+		t.Errorf("pool kept a transaction whose inputs were spent")
+	}
+}
+
+func TestBlockChainWithTransactions(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	checkFatal(err)
+
+	h := newHarness(t)
+	blockChain := BlockChain{GenesisBlock}
+	coinbase := NewCoinbaseTransaction(privateKey.PublicKey, 1)
+	blockChain = append(blockChain, h.next(&GenesisBlock, "", coinbase))
+	utxos, err := blockChain.UnspentTxOuts()
+	if err != nil || len(utxos) != 1 {
+		t.Fatalf("UnspentTxOuts = %v, %v; want one coinbase output", utxos, err)
+	}
+
+	tx := NewTransaction([]TxIn{NewTxIn(coinbase.id, 0)}, []TxOut{NewTxOut(privateKey.PublicKey, CoinbaseAmount)})
+	checkFatal(tx.Sign(privateKey, utxos))
+	if immature := append(blockChain, h.next(&blockChain[1], "", NewCoinbaseTransaction(privateKey.PublicKey, 2), tx)); immature.IsValid() {
+		t.Errorf("blockchain spending an immature coinbase was valid")
+	}
+	blockChain = h.extend(blockChain, int(Params.CoinbaseMaturity)-1, "")
+	spendHeight := blockChain.NextHeight()
+	tip := &blockChain[len(blockChain)-1]
+	blockChain = append(blockChain, h.next(tip, "", NewCoinbaseTransaction(privateKey.PublicKey, spendHeight), tx))
+	if !blockChain.IsValid() {
+		t.Errorf("blockchain spending a mature coinbase was invalid")
+	}
+	if _, loc, ok := blockChain.FindTransaction(tx.id); !ok || loc.BlockIndex != blockChain[spendHeight].Index || loc.Position != 1 {
+		t.Errorf("FindTransaction = %+v, %t; want block %d at position 1", loc, ok, spendHeight)
+	}
+
+	wrongHeight := append(BlockChain{}, blockChain[:2]...)
+	wrongHeight = append(wrongHeight, h.next(&blockChain[1], "", NewCoinbaseTransaction(privateKey.PublicKey, 5)))
+	if wrongHeight.IsValid() {
+		t.Errorf("coinbase with wrong height was valid")
+	}
+}
+
+func TestTransactionEncoding(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	checkFatal(err)
+	coinbase := NewCoinbaseTransaction(privateKey.PublicKey, 1)
+	utxos := updateUnspentTxOuts([]Transaction{coinbase}, nil, atHeight(1))
+	tx := NewTransaction([]TxIn{NewTxIn(coinbase.id, 0)}, []TxOut{NewTxOut(privateKey.PublicKey, CoinbaseAmount)})
+	checkFatal(tx.Sign(privateKey, utxos))
+
+	b, err := json.Marshal(tx)
+	checkFatal(err)
+	var decoded Transaction
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !validateTransaction(decoded, utxos, atHeight(1+Params.CoinbaseMaturity)) {
+		t.Errorf("transaction was invalid after a JSON round trip")
+	}
+
+	var buf bytes.Buffer
+	checkFatal(gob.NewEncoder(&buf).Encode(BlockChain{GenesisBlock.FindBlockWithTransactions([]byte{}, []Transaction{coinbase})}))
+	var bc BlockChain
+	checkFatal(gob.NewDecoder(&buf).Decode(&bc))
+	if bc[0].Transactions[0].getID() != coinbase.id {
+		t.Errorf("transaction changed after a gob round trip")
+	}
 }
@@ -0,0 +1,60 @@
+package basicblock
+
+// TxLocation is where a confirmed transaction is: the hash and index of its block and its position among the block's transactions.
+type TxLocation struct {
+	BlockHash  [32]byte
+	BlockIndex int32
+	Position   int
+}
+
+// TxIndex maps transaction ids to their TxLocation, so a transaction is found without scanning the chain as FindTransaction does. It follows the chain as blocks are connected and disconnected; it is optional, as it grows with every transaction ever confirmed.
+type TxIndex map[[32]byte]TxLocation
+
+// NewTxIndex returns an index of the transactions in bc.
+func NewTxIndex(bc BlockChain) TxIndex {
+	idx := make(TxIndex)
+	for i := range bc {
+		idx.ConnectBlock(&bc[i])
+	}
+	return idx
+}
+
+// ConnectBlock adds the transactions of blk, which became part of the chain.
+func (idx TxIndex) ConnectBlock(blk *BasicBlock) {
+	for i, tx := range blk.Transactions {
+		idx[tx.id] = TxLocation{BlockHash: blk.Hash, BlockIndex: blk.Index, Position: i}
+	}
+}
+
+// DisconnectBlock removes the transactions of blk, which a reorganization took out of the chain. Transactions that the index has in another block are kept.
+func (idx TxIndex) DisconnectBlock(blk *BasicBlock) {
+	for _, tx := range blk.Transactions {
+		if loc, ok := idx[tx.id]; ok && loc.BlockHash == blk.Hash {
+			delete(idx, tx.id)
+		}
+	}
+}
+
+// Update moves the index from orig to next: the blocks of orig after their fork point are disconnected, tip first, then those of next are connected.
+func (idx TxIndex) Update(orig, next BlockChain) {
+	fork := ForkPoint(orig, next)
+	for i := len(orig) - 1; i >= fork; i-- {
+		idx.DisconnectBlock(&orig[i])
+	}
+	for i := fork; i < len(next); i++ {
+		idx.ConnectBlock(&next[i])
+	}
+}
+
+// Find is FindTransaction through the index: it returns the transaction with id and its location, which is in bc, the chain the index has to follow.
+func (idx TxIndex) Find(bc BlockChain, id [32]byte) (Transaction, TxLocation, bool) {
+	loc, ok := idx[id]
+	if !ok || len(bc) == 0 {
+		return Transaction{}, TxLocation{}, false
+	}
+	i := int(loc.BlockIndex - bc[0].Index)
+	if i < 0 || i >= len(bc) || bc[i].Hash != loc.BlockHash || loc.Position >= len(bc[i].Transactions) {
+		return Transaction{}, TxLocation{}, false
+	}
+	return bc[i].Transactions[loc.Position], loc, true
+}
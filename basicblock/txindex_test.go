@@ -0,0 +1,54 @@
+package basicblock
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"maps"
+	"testing"
+)
+
+func TestTxIndex(t *testing.T) {
+	h := newHarness(t)
+	alice, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	bob, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	base := BlockChain{GenesisBlock}
+	a := append(base, h.next(&base[0], "a", NewCoinbaseTransaction(alice.PublicKey, 1)))
+	a = append(a, h.next(&a[1], "a", NewCoinbaseTransaction(alice.PublicKey, 2)))
+	b := append(BlockChain{GenesisBlock}, h.next(&base[0], "b", NewCoinbaseTransaction(bob.PublicKey, 1)))
+	b = h.extend(b, 2, "b")
+
+	idx := NewTxIndex(a)
+	for i := 1; i < len(a); i++ {
+		id := a[i].Transactions[0].id
+		tx, loc, ok := idx.Find(a, id)
+		if !ok || tx.id != id || loc.BlockHash != a[i].Hash || loc.BlockIndex != a[i].Index || loc.Position != 0 {
+			t.Errorf("Find(%x) = %+v, %t, want block %d at position 0", id, loc, ok, i)
+		}
+		if _, want, _ := a.FindTransaction(id); loc != want {
+			t.Errorf("Find(%x) = %+v, FindTransaction found it at %+v", id, loc, want)
+		}
+	}
+
+	idx.Update(a, b)
+	if !maps.Equal(idx, NewTxIndex(b)) {
+		t.Errorf("index after a reorganization differs from one built from the new chain")
+	}
+	for _, id := range [][32]byte{a[1].Transactions[0].id, a[2].Transactions[0].id} {
+		if _, _, ok := idx.Find(b, id); ok {
+			t.Errorf("disconnected transaction %x still found", id)
+		}
+	}
+	if _, loc, ok := idx.Find(b, b[1].Transactions[0].id); !ok || loc.BlockHash != b[1].Hash {
+		t.Errorf("connected transaction found at %+v, %t, want block 1", loc, ok)
+	}
+	// Against a chain it does not follow, the index finds nothing rather than the wrong block.
+	if _, _, ok := idx.Find(a, b[1].Transactions[0].id); ok {
+		t.Errorf("Find returned a transaction of another chain")
+	}
+
+	idx.Update(b, a)
+	if !maps.Equal(idx, NewTxIndex(a)) {
+		t.Errorf("index after reorganizing back differs from one built from the original chain")
+	}
+}
@@ -0,0 +1,199 @@
+package basicblock
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/chronologos/naivecoin/logging"
+)
+
+var mempoolLog = logging.Logger(logging.Mempool)
+
+// TransactionPool holds the transactions that are valid but not yet in a block (in Bitcoin this is called the mempool). Miners take transactions from it when they look for the next block. A pooled transaction may spend the outputs of those before it, so the pool is kept in an order that a block can include it in.
+type TransactionPool []Transaction
+
+// Reasons for a Replacement.
+const (
+	ReplacedConflict   = "conflict"   // it spent a txOut that the replacing transaction spends
+	ReplacedDescendant = "descendant" // it spent an output of a replaced transaction
+)
+
+// Replacement is a pooled transaction that Add evicted in favour of a transaction paying a higher fee (replace-by-fee).
+type Replacement struct {
+	Transaction Transaction
+	By          [32]byte // id of the transaction that replaced it
+	Reason      string
+}
+
+// conflicts returns the ids of the pooled transactions spending any txOut that tx spends.
+func (pool TransactionPool) conflicts(tx Transaction) map[[32]byte]bool {
+	res := make(map[[32]byte]bool)
+	for _, poolTx := range pool {
+		for _, poolTxIn := range poolTx.txIns {
+			for _, txIn := range tx.txIns {
+				if poolTxIn.txOutID == txIn.txOutID && poolTxIn.txOutIndex == txIn.txOutIndex {
+					res[poolTx.id] = true
+				}
+			}
+		}
+	}
+	return res
+}
+
+// descendants returns the ids of the pooled transactions that spend outputs of the transactions in ids, of those, and so on.
+func (pool TransactionPool) descendants(ids map[[32]byte]bool) map[[32]byte]bool {
+	res := make(map[[32]byte]bool)
+	for _, tx := range pool { // parents come before their children
+		for _, txIn := range tx.txIns {
+			if ids[txIn.txOutID] || res[txIn.txOutID] {
+				res[tx.id] = true
+				break
+			}
+		}
+	}
+	return res
+}
+
+func (pool TransactionPool) without(ids map[[32]byte]bool) TransactionPool {
+	var res TransactionPool
+	for _, tx := range pool {
+		if !ids[tx.id] {
+			res = append(res, tx)
+		}
+	}
+	return res
+}
+
+// outputs returns aUnspentTxOuts together with every output of the pool, spent or not, as if the pool were in the block at ctx. Fees of pooled transactions are computed against them.
+func (pool TransactionPool) outputs(aUnspentTxOuts []UnspentTxOut, ctx BlockContext) []UnspentTxOut {
+	res := append([]UnspentTxOut{}, aUnspentTxOuts...)
+	for _, tx := range pool {
+		for idx, txOut := range tx.txOuts {
+			res = append(res, UnspentTxOut{txOutId: tx.id, txOutIndex: int32(idx), lockingScript: txOut.lockingScript, amount: txOut.amount, blockHeight: ctx.Height, medianTime: ctx.MedianTimePast})
+		}
+	}
+	return res
+}
+
+// Add returns the pool with tx appended, if tx is valid in the block at ctx against aUnspentTxOuts and the outputs of the pool, and pays at least MinRelayFeeRate.
+//
+// If tx spends txOuts that pooled transactions already spend, it replaces them and their descendants, but only if it pays a higher fee than all of them together and a higher fee rate than each one it directly conflicts with. Otherwise the first-seen transactions stay. The evicted transactions are returned.
+func (pool TransactionPool) Add(tx Transaction, aUnspentTxOuts []UnspentTxOut, ctx BlockContext) (TransactionPool, []Replacement, error) {
+	if _, ok := pool.Find(tx.id); ok {
+		return pool, nil, TxError{fmt.Sprintf("tx %x is already in the pool", tx.id), InvalidTx}
+	}
+	conflicts := pool.conflicts(tx)
+	evicted := pool.descendants(conflicts)
+	for id := range conflicts {
+		evicted[id] = true
+	}
+	kept := pool.without(evicted)
+	view := updateUnspentTxOuts(kept, aUnspentTxOuts, ctx)
+	if !validateTransaction(tx, view, ctx) {
+		return pool, nil, TxError{fmt.Sprintf("trying to add invalid tx %x to pool", tx.id), InvalidTx}
+	}
+	fee := tx.Fee(view)
+	if minFee := FeeForSize(tx.Size(), MinRelayFeeRate); fee < minFee {
+		return pool, nil, TxError{fmt.Sprintf("tx %x pays a fee of %d, below the minimum relay fee of %d", tx.id, fee, minFee), InvalidTx}
+	}
+
+	outputs := pool.outputs(aUnspentTxOuts, ctx)
+	var replacements []Replacement
+	var evictedFees int64
+	for _, poolTx := range pool {
+		if !evicted[poolTx.id] {
+			continue
+		}
+		poolFee := poolTx.Fee(outputs)
+		evictedFees += poolFee
+		reason := ReplacedDescendant
+		if conflicts[poolTx.id] {
+			reason = ReplacedConflict
+			if fee*int64(poolTx.Size()) <= poolFee*int64(tx.Size()) {
+				return pool, nil, TxError{fmt.Sprintf("tx %x conflicts with pooled tx %x and does not pay a higher fee rate", tx.id, poolTx.id), InvalidTx}
+			}
+		}
+		replacements = append(replacements, Replacement{poolTx, tx.id, reason})
+	}
+	if len(replacements) > 0 && fee <= evictedFees {
+		return pool, nil, TxError{fmt.Sprintf("tx %x pays a fee of %d, not more than the %d paid by the %d pooled txs it would replace", tx.id, fee, evictedFees, len(replacements)), InvalidTx}
+	}
+	for _, r := range replacements {
+		mempoolLog.Debug("replaced tx", "tx", fmt.Sprintf("%x", r.Transaction.id), "by", fmt.Sprintf("%x", tx.id), "reason", r.Reason)
+	}
+	mempoolLog.Debug("added tx", "tx", fmt.Sprintf("%x", tx.id), "fee", fee, "poolSize", len(kept)+1)
+	return append(kept, tx), replacements, nil
+}
+
+// ForBlock picks pooled transactions for a block carrying data, highest fee rate first, as many as fit next to the coinbase. A transaction spending the outputs of another pooled one has to wait until that one is picked. It returns them together with the fees they pay, which the coinbase may claim.
+func (pool TransactionPool) ForBlock(aUnspentTxOuts []UnspentTxOut, data []byte) ([]Transaction, int64) {
+	outputs := pool.outputs(aUnspentTxOuts, BlockContext{})
+	fees := make(map[[32]byte]int64, len(pool))
+	for i := range pool {
+		fees[pool[i].id] = pool[i].Fee(outputs)
+	}
+	sorted := slices.Clone(pool)
+	slices.SortStableFunc(sorted, func(a, b Transaction) int { // by fee rate, descending
+		return cmp.Compare(fees[b.id]*int64(a.Size()), fees[a.id]*int64(b.Size()))
+	})
+	picked := make(map[[32]byte]bool)
+	var txs []Transaction
+	var total int64
+	size := headerSize + 4 + len(data) + coinbaseSize // the nonce is 4 bytes
+	for len(txs)+1 < MaxBlockTransactions {
+		i := slices.IndexFunc(sorted, func(tx Transaction) bool {
+			if size+tx.Size() > MaxBlockSize {
+				return false
+			}
+			for _, txIn := range tx.txIns {
+				if _, pooled := fees[txIn.txOutID]; pooled && !picked[txIn.txOutID] {
+					return false
+				}
+			}
+			return true
+		})
+		if i < 0 {
+			break
+		}
+		tx := sorted[i]
+		sorted = slices.Delete(sorted, i, i+1)
+		picked[tx.id] = true
+		size += tx.Size()
+		total += fees[tx.id]
+		txs = append(txs, tx)
+	}
+	return txs, total
+}
+
+// Update drops every pooled transaction that can not go into the block at ctx because it spends an output which is no longer unspent, e.g. because a new block included it, or because a reorg moved the output up so that it is immature or still locked. Transactions spending the outputs of dropped ones are dropped too.
+func (pool TransactionPool) Update(aUnspentTxOuts []UnspentTxOut, ctx BlockContext) TransactionPool {
+	var res TransactionPool
+	view := aUnspentTxOuts
+	for _, tx := range pool {
+		valid := tx.isFinal(ctx)
+		for _, txIn := range tx.txIns {
+			if utxo, err := findUnspentTxOut(txIn.txOutID, txIn.txOutIndex, view); err != nil || !utxo.IsMature(ctx.Height) || !txIn.sequenceLockPassed(utxo, ctx) {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			res = append(res, tx)
+			view = updateUnspentTxOuts([]Transaction{tx}, view, ctx)
+		} else {
+			mempoolLog.Debug("dropping tx whose txIns are spent, immature or locked", "tx", fmt.Sprintf("%x", tx.id))
+		}
+	}
+	return res
+}
+
+// Find returns the pooled transaction with the given id.
+func (pool TransactionPool) Find(id [32]byte) (Transaction, bool) {
+	for _, tx := range pool {
+		if tx.id == id {
+			return tx, true
+		}
+	}
+	return Transaction{}, false
+}
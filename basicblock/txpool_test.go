@@ -0,0 +1,159 @@
+package basicblock
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+)
+
+// poolFixture is a chain whose first two coinbases are mature, and a key to spend them with.
+type poolFixture struct {
+	priv      *ecdsa.PrivateKey
+	coinbases []Transaction
+	utxos     []UnspentTxOut
+	height    int32
+}
+
+func newPoolFixture(t *testing.T) poolFixture {
+	setParams(t, ChainParams{InitialSubsidy: 50, CoinbaseMaturity: 1})
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	coinbases := []Transaction{NewCoinbaseTransaction(priv.PublicKey, 1), NewCoinbaseTransaction(priv.PublicKey, 2)}
+	utxos := updateUnspentTxOuts(coinbases[:1], nil, atHeight(1))
+	utxos = updateUnspentTxOuts(coinbases[1:], utxos, atHeight(2))
+	return poolFixture{priv, coinbases, utxos, 3}
+}
+
+// spendAll returns a signed transaction spending output 0 of every transaction in from, paying fee and sending the rest back.
+func (f poolFixture) spendAll(t *testing.T, fee int32, from ...Transaction) Transaction {
+	t.Helper()
+	var txIns []TxIn
+	var amount int32
+	for _, tx := range from {
+		txIns = append(txIns, NewTxIn(tx.id, 0))
+		amount += tx.txOuts[0].amount
+	}
+	tx := NewTransaction(txIns, []TxOut{NewTxOut(f.priv.PublicKey, amount-fee)})
+	spent := TransactionPool(from).outputs(f.utxos, atHeight(f.height))
+	if err := tx.Sign(f.priv, spent); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func (f poolFixture) add(t *testing.T, pool TransactionPool, tx Transaction) (TransactionPool, []Replacement) {
+	t.Helper()
+	pool, replaced, err := pool.Add(tx, f.utxos, atHeight(f.height))
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	return pool, replaced
+}
+
+func TestReplaceByFee(t *testing.T) {
+	f := newPoolFixture(t)
+	original := f.spendAll(t, 4, f.coinbases[0])
+	pool, _ := f.add(t, nil, original)
+
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	sameFee := NewTransaction([]TxIn{NewTxIn(f.coinbases[0].id, 0)}, []TxOut{NewTxOut(other.PublicKey, 46)})
+	checkFatal(sameFee.Sign(f.priv, f.utxos))
+	for name, tx := range map[string]Transaction{
+		"same fee":  sameFee,
+		"lower fee": f.spendAll(t, 3, f.coinbases[0]),
+		// pays more in total, but spends a second output and so is half again as large
+		"lower fee rate": f.spendAll(t, 5, f.coinbases[0], f.coinbases[1]),
+	} {
+		if tx.id == original.id {
+			t.Fatalf("%s: replacement is the original transaction", name)
+		}
+		if _, _, err := pool.Add(tx, f.utxos, atHeight(f.height)); err == nil {
+			t.Errorf("%s: replacement was accepted", name)
+		}
+	}
+
+	replacement := f.spendAll(t, 6, f.coinbases[0])
+	pool, replaced := f.add(t, pool, replacement)
+	if len(pool) != 1 || pool[0].id != replacement.id {
+		t.Errorf("pool holds %d transactions, want only the replacement", len(pool))
+	}
+	if len(replaced) != 1 || replaced[0].Transaction.id != original.id || replaced[0].By != replacement.id || replaced[0].Reason != ReplacedConflict {
+		t.Errorf("replacements = %+v, want the original as a conflict", replaced)
+	}
+}
+
+func TestReplaceByFeeEvictsDescendants(t *testing.T) {
+	f := newPoolFixture(t)
+	parent := f.spendAll(t, 2, f.coinbases[0])
+	child := f.spendAll(t, 2, parent)
+	grandchild := f.spendAll(t, 2, child)
+	unrelated := f.spendAll(t, 2, f.coinbases[1])
+	var pool TransactionPool
+	for _, tx := range []Transaction{parent, child, unrelated, grandchild} {
+		pool, _ = f.add(t, pool, tx)
+	}
+
+	// Replacing the parent has to outbid the whole family, not just the parent.
+	if _, _, err := pool.Add(f.spendAll(t, 5, f.coinbases[0]), f.utxos, atHeight(f.height)); err == nil {
+		t.Errorf("replacement paying less than the evicted descendants was accepted")
+	}
+	replacement := f.spendAll(t, 7, f.coinbases[0])
+	pool, replaced := f.add(t, pool, replacement)
+	if len(pool) != 2 || pool[0].id != unrelated.id || pool[1].id != replacement.id {
+		t.Errorf("pool = %d transactions, want the unrelated one and the replacement", len(pool))
+	}
+	want := map[[32]byte]string{parent.id: ReplacedConflict, child.id: ReplacedDescendant, grandchild.id: ReplacedDescendant}
+	if len(replaced) != len(want) {
+		t.Fatalf("%d replacements, want %d", len(replaced), len(want))
+	}
+	for _, r := range replaced {
+		if want[r.Transaction.id] != r.Reason {
+			t.Errorf("tx %x replaced as %q, want %q", r.Transaction.id[:4], r.Reason, want[r.Transaction.id])
+		}
+	}
+}
+
+func TestPoolChainsIntoBlock(t *testing.T) {
+	f := newPoolFixture(t)
+	parent := f.spendAll(t, 1, f.coinbases[0])
+	child := f.spendAll(t, 20, parent) // pays a higher fee rate than its parent, but has to come after it
+	pool, _ := f.add(t, nil, parent)
+	pool, _ = f.add(t, pool, child)
+
+	txs, fees := pool.ForBlock(f.utxos, nil)
+	if len(txs) != 2 || txs[0].id != parent.id || fees != 21 {
+		t.Fatalf("ForBlock = %d transactions paying %d, want the parent first and 21", len(txs), fees)
+	}
+	if !validateBlockTransactions(append([]Transaction{coinbaseWithFees(t, f.priv.PublicKey, f.height, fees)}, txs...), f.utxos, atHeight(f.height)) {
+		t.Errorf("block with a transaction spending an earlier one was invalid")
+	}
+	if validateBlockTransactions([]Transaction{NewCoinbaseTransaction(f.priv.PublicKey, f.height), child, parent}, f.utxos, atHeight(f.height)) {
+		t.Errorf("block with a transaction spending a later one was valid")
+	}
+
+	// A block spending the parent's input elsewhere takes the child down with the parent.
+	other := f.spendAll(t, 1, f.coinbases[0], f.coinbases[1])
+	if len(pool.Update(updateUnspentTxOuts([]Transaction{other}, f.utxos, atHeight(f.height)), atHeight(f.height+1))) != 0 {
+		t.Errorf("pool kept a transaction whose parent was dropped")
+	}
+}
+
+func TestTransactionPoolForBlock(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	var pool TransactionPool
+	for i := 0; i < 20; i++ {
+		pool = append(pool, Transaction{txOuts: txOuts(priv, 150)})
+	}
+	txs, fees := pool.ForBlock(nil, []byte("data"))
+	if len(txs) == 0 || len(txs) == len(pool) || fees != 0 {
+		t.Fatalf("ForBlock picked %d transactions paying %d, want some but not all, paying nothing", len(txs), fees)
+	}
+	blk := BasicBlock{BlockHeader: BlockHeader{Nonce: make([]byte, 4)}, Data: []byte("data"), Transactions: append([]Transaction{NewCoinbaseTransaction(priv.PublicKey, 1)}, txs...)}
+	if blk.Size() > MaxBlockSize {
+		t.Errorf("block of size %d exceeds MaxBlockSize", blk.Size())
+	}
+	blk.Transactions = append(blk.Transactions, pool[0])
+	if blk.Size() <= MaxBlockSize {
+		t.Errorf("ForBlock left out a transaction that would have fit")
+	}
+}
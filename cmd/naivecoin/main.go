@@ -1,20 +1,548 @@
-package naivecoin
+package main
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
 
-	nc "github.com/chronologos/naivecoin"
+	bb "github.com/chronologos/naivecoin/basicblock"
+	"github.com/chronologos/naivecoin/wallet"
 )
 
-// BlockChain!
+const usage = `usage:
+  naivecoin wallet create [--wallet PATH] [--passphrase P]
+  naivecoin wallet mnemonic [--wallet PATH] [--password P]
+  naivecoin wallet encrypt --password P [--old-password P] [--wallet PATH]
+  naivecoin wallet restore --mnemonic "WORDS" [--passphrase P] [--wallet PATH] [--node URL]
+  naivecoin wallet address [--wallet PATH] [--pubkey]
+  naivecoin wallet keygen --key PATH [--scheme ecdsa|ed25519]
+  naivecoin wallet balance [--wallet PATH | --key PATH] [--node URL]
+  naivecoin wallet send --to ADDR|SCRIPT --amount N [--wallet PATH [--password P] | --key PATH] [--node URL] [--feerate N] [--confirmations N]
+  naivecoin wallet multisig address --m M --keys PUBKEY,PUBKEY,...
+  naivecoin wallet multisig create --lock SCRIPT --to ADDR|SCRIPT --amount N --out FILE [--node URL] [--feerate N]
+  naivecoin wallet multisig sign --tx FILE [--wallet PATH [--password P] | --key PATH]
+  naivecoin wallet multisig send --tx FILE [--node URL]
+naivecoin --testnet wallet ... uses the addresses of test networks.
+`
 
 func main() {
+	testnet := flag.Bool("testnet", false, "use the addresses of test networks")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 2 || args[0] != "wallet" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *testnet {
+		bb.Params = bb.TestParams
+	}
+	var err error
+	switch args[1] {
+	case "create":
+		err = walletCreate(args[2:])
+	case "mnemonic":
+		err = walletMnemonic(args[2:])
+	case "encrypt":
+		err = walletEncrypt(args[2:])
+	case "restore":
+		err = walletRestore(args[2:])
+	case "address":
+		err = walletAddress(args[2:])
+	case "keygen":
+		err = walletKeygen(args[2:])
+	case "balance":
+		err = walletBalance(args[2:])
+	case "send":
+		err = walletSend(args[2:])
+	case "multisig":
+		err = walletMultiSig(args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func walletCreate(args []string) error {
+	fs := flag.NewFlagSet("wallet create", flag.ExitOnError)
+	walletPath := fs.String("wallet", wallet.DefaultWalletPath, "path to the wallet")
+	passphrase := fs.String("passphrase", "", "optional passphrase, needed together with the mnemonic to restore the wallet")
+	fs.Parse(args)
+
+	w, err := wallet.CreateHDWallet(*walletPath, *passphrase)
+	if err != nil {
+		return err
+	}
+	mnemonic, err := w.Mnemonic()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Write down these words, they restore the wallet:\n%s\n", mnemonic)
+	return nil
+}
+
+func walletMnemonic(args []string) error {
+	fs := flag.NewFlagSet("wallet mnemonic", flag.ExitOnError)
+	walletPath := fs.String("wallet", wallet.DefaultWalletPath, "path to the wallet")
+	password := fs.String("password", "", "password of an encrypted wallet")
+	fs.Parse(args)
+
+	w, err := loadUnlocked(*walletPath, *password)
+	if err != nil {
+		return err
+	}
+	mnemonic, err := w.Mnemonic()
+	if err != nil {
+		return err
+	}
+	fmt.Println(mnemonic)
+	return nil
+}
+
+func walletEncrypt(args []string) error {
+	fs := flag.NewFlagSet("wallet encrypt", flag.ExitOnError)
+	walletPath := fs.String("wallet", wallet.DefaultWalletPath, "path to the wallet")
+	password := fs.String("password", "", "new password to encrypt the wallet with")
+	oldPassword := fs.String("old-password", "", "current password, if the wallet is encrypted already")
+	fs.Parse(args)
+
+	w, err := loadUnlocked(*walletPath, *oldPassword)
+	if err != nil {
+		return err
+	}
+	if err := w.Encrypt(*password); err != nil {
+		return err
+	}
+	return w.Save(*walletPath)
+}
+
+// loadUnlocked loads the existing wallet at path and unlocks it with password if it is encrypted. Creating a wallet here would only hand out the secrets of a new, empty one.
+func loadUnlocked(path, password string) (*wallet.HDWallet, error) {
+	w, err := wallet.LoadHDWallet(path)
+	if err != nil {
+		return nil, err
+	}
+	return w, unlock(w, password)
+}
+
+// unlock unlocks w with password if it is encrypted.
+func unlock(w *wallet.HDWallet, password string) error {
+	if !w.Encrypted() {
+		return nil
+	}
+	if password == "" {
+		return fmt.Errorf("the wallet is encrypted, its --password is needed")
+	}
+	return w.Unlock(password, 0)
+}
+
+func walletRestore(args []string) error {
+	fs := flag.NewFlagSet("wallet restore", flag.ExitOnError)
+	walletPath := fs.String("wallet", wallet.DefaultWalletPath, "path to the wallet, which must not exist yet")
+	node := fs.String("node", "localhost:8000", "URL of the node to look for the wallet's addresses on")
+	mnemonic := fs.String("mnemonic", "", "the words the wallet was created with")
+	passphrase := fs.String("passphrase", "", "the passphrase the wallet was created with")
+	fs.Parse(args)
+
+	if _, err := os.Stat(*walletPath); err == nil {
+		return fmt.Errorf("%s already exists", *walletPath)
+	}
+	w, err := wallet.NewHDWalletFromMnemonic(*mnemonic, *passphrase, 0)
+	if err != nil {
+		return err
+	}
+	if err := w.Save(*walletPath); err != nil {
+		return err
+	}
+	w, utxos, err := loadWallet(*walletPath, wallet.NewClient(*node), false)
+	if err != nil {
+		return err
+	}
+	pubs, err := w.PublicKeys()
+	if err != nil {
+		return err
+	}
+	balance, err := w.Balance(utxos)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %d used address(es) holding %d coins.\n", len(pubs), balance)
+	return nil
+}
+
+func walletAddress(args []string) error {
+	fs := flag.NewFlagSet("wallet address", flag.ExitOnError)
+	walletPath := fs.String("wallet", wallet.DefaultWalletPath, "path to the wallet")
+	pubkey := fs.Bool("pubkey", false, "also print the public key of the address, which multisig locks are built from")
+	fs.Parse(args)
 
-	blockChain := []nc.BasicBlock{nc.GenesisBlock}
-	for i := 0; i < 5; i++ {
-		blockChain = append(blockChain, blockChain[len(blockChain)-1].GenerateNextBasicBlock([]byte{}))
+	w, err := wallet.LoadOrCreateHDWallet(*walletPath)
+	if err != nil {
+		return err
 	}
+	pub, err := w.NextPublicKey(wallet.ReceiveChain)
+	if err != nil {
+		return err
+	}
+	if err := w.Save(*walletPath); err != nil {
+		return err
+	}
+	fmt.Println(bb.NewAddress(pub))
+	if *pubkey {
+		fmt.Println(bb.EncodePublicKey(pub))
+	}
+	return nil
+}
 
-	fmt.Print(blockChain)
-	fmt.Print(nc.IsIsValidBasicBlockchain(blockChain))
+func walletKeygen(args []string) error {
+	fs := flag.NewFlagSet("wallet keygen", flag.ExitOnError)
+	keyPath := fs.String("key", "", "file to save the new key to, it must not exist yet")
+	schemeName := fs.String("scheme", "ed25519", "signature scheme of the key, ecdsa or ed25519")
+	fs.Parse(args)
+
+	if *keyPath == "" {
+		return fmt.Errorf("--key is required")
+	}
+	scheme, err := bb.ParseSignatureScheme(*schemeName)
+	if err != nil {
+		return err
+	}
+	key, err := wallet.CreateKey(*keyPath, scheme)
+	if err != nil {
+		return err
+	}
+	fmt.Println(bb.NewAddress(key.Public()))
+	fmt.Println(bb.EncodePublicKey(key.Public()))
+	return nil
+}
+
+func walletBalance(args []string) error {
+	fs := flag.NewFlagSet("wallet balance", flag.ExitOnError)
+	walletPath := fs.String("wallet", wallet.DefaultWalletPath, "path to the wallet")
+	keyPath := fs.String("key", "", "standalone key made by wallet keygen, used instead of the wallet")
+	node := fs.String("node", "localhost:8000", "URL of the node to talk to")
+	fs.Parse(args)
+
+	if *keyPath != "" {
+		key, err := wallet.LoadKey(*keyPath)
+		if err != nil {
+			return err
+		}
+		utxos, err := wallet.NewClient(*node).UnspentTxOuts(bb.NewAddress(key.Public()).String())
+		if err != nil {
+			return err
+		}
+		fmt.Println(wallet.Balance(key.Public(), utxos))
+		return nil
+	}
+	w, utxos, err := loadWallet(*walletPath, wallet.NewClient(*node), false)
+	if err != nil {
+		return err
+	}
+	balance, err := w.Balance(utxos)
+	if err != nil {
+		return err
+	}
+	fmt.Println(balance)
+	return nil
+}
+
+func walletSend(args []string) error {
+	fs := flag.NewFlagSet("wallet send", flag.ExitOnError)
+	walletPath := fs.String("wallet", wallet.DefaultWalletPath, "path to the wallet")
+	password := fs.String("password", "", "password of an encrypted wallet")
+	keyPath := fs.String("key", "", "standalone key made by wallet keygen to spend from instead of the wallet, change goes back to it")
+	node := fs.String("node", "localhost:8000", "URL of the node to talk to")
+	to := fs.String("to", "", "address or hex locking script to send coins to")
+	amount := fs.Int("amount", 0, "number of coins to send")
+	feeRate := fs.Int64("feerate", 0, "fee in coins per 1000 bytes, 0 asks the node for an estimate")
+	confirmations := fs.Int("confirmations", 1, "wait until the transaction is this many blocks deep")
+	poll := fs.Duration("poll", 2*time.Second, "how often to ask the node about the transaction")
+	fs.Parse(args)
+
+	receiver, err := parseReceiver(*to)
+	if err != nil {
+		return fmt.Errorf("--to: %v", err)
+	}
+	client := wallet.NewClient(*node)
+	if *feeRate, err = resolveFeeRate(client, *feeRate); err != nil {
+		return err
+	}
+	var w *wallet.HDWallet
+	var tx bb.Transaction
+	if *keyPath != "" {
+		key, err := wallet.LoadKey(*keyPath)
+		if err != nil {
+			return err
+		}
+		utxos, err := client.SpendableTxOuts(bb.NewAddress(key.Public()).String())
+		if err != nil {
+			return err
+		}
+		if tx, err = wallet.CreateTransaction(receiver, int32(*amount), *feeRate, key, utxos); err != nil {
+			return err
+		}
+	} else {
+		var utxos []bb.UnspentTxOut
+		if w, utxos, err = loadWallet(*walletPath, client, true); err != nil {
+			return err
+		}
+		if err := unlock(w, *password); err != nil {
+			return err
+		}
+		if tx, err = w.CreateTransaction(receiver, int32(*amount), *feeRate, utxos); err != nil {
+			return err
+		}
+	}
+	res, err := client.SendTransaction(tx)
+	if err != nil {
+		return err
+	}
+	// The change address is handed out now.
+	if w != nil {
+		if err := w.Save(*walletPath); err != nil {
+			return err
+		}
+	}
+	for _, r := range res.Replaced {
+		fmt.Printf("Replaced pooled transaction %s (%s).\n", r.ID, r.Reason)
+	}
+	id := tx.ID()
+	fmt.Printf("Sent transaction %x, waiting for %d confirmation(s)...\n", id, *confirmations)
+
+	for {
+		status, err := client.TransactionStatus(id)
+		if err != nil {
+			return err
+		}
+		if status.ReplacedBy != "" {
+			return fmt.Errorf("transaction %x was replaced by %s (%s)", id, status.ReplacedBy, status.ReplacedReason)
+		}
+		if status.Confirmations >= *confirmations {
+			fmt.Printf("Confirmed in block %s (index %d), %d confirmation(s).\n", status.BlockHash, status.BlockIndex, status.Confirmations)
+			return nil
+		}
+		time.Sleep(*poll)
+	}
+}
+
+// loadWallet loads the wallet at path, looks for the addresses of it that were used on the chain, and fetches the unspent outputs locked to any of its keys, only the spendable ones if spendable is set.
+func loadWallet(path string, client *wallet.Client, spendable bool) (*wallet.HDWallet, []bb.UnspentTxOut, error) {
+	w, err := wallet.LoadOrCreateHDWallet(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := w.Discover(func(pub ecdsa.PublicKey) (bool, error) { return client.Used(bb.NewAddress(pub).String()) }); err != nil {
+		return nil, nil, err
+	}
+	if err := w.Save(path); err != nil {
+		return nil, nil, err
+	}
+	pubs, err := w.PublicKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+	fetch := client.UnspentTxOuts
+	if spendable {
+		fetch = client.SpendableTxOuts
+	}
+	var utxos []bb.UnspentTxOut
+	for _, pub := range pubs {
+		res, err := fetch(bb.NewAddress(pub).String())
+		if err != nil {
+			return nil, nil, err
+		}
+		utxos = append(utxos, res...)
+	}
+	return w, utxos, nil
+}
+
+// parseReceiver takes an address, which means its PayToAddress lock, or a locking script in hex.
+func parseReceiver(s string) (bb.Script, error) {
+	address, addressErr := bb.ParseAddress(s)
+	if addressErr == nil {
+		return bb.PayToAddress(address), nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("%q is neither an address (%v) nor a hex locking script", s, addressErr)
+	}
+	return b, nil
+}
+
+// resolveFeeRate returns feeRate, or what the node suggests if it is 0.
+func resolveFeeRate(client *wallet.Client, feeRate int64) (int64, error) {
+	if feeRate != 0 {
+		return feeRate, nil
+	}
+	estimate, err := client.FeeEstimate()
+	if err != nil {
+		return 0, err
+	}
+	return estimate.FeeRate, nil
+}
+
+func walletMultiSig(args []string) error {
+	if len(args) < 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "address":
+		return multiSigAddress(args[1:])
+	case "create":
+		return multiSigCreate(args[1:])
+	case "sign":
+		return multiSigSign(args[1:])
+	case "send":
+		return multiSigSend(args[1:])
+	}
+	fmt.Fprint(os.Stderr, usage)
+	os.Exit(2)
+	return nil
+}
+
+func multiSigAddress(args []string) error {
+	fs := flag.NewFlagSet("wallet multisig address", flag.ExitOnError)
+	m := fs.Int("m", 1, "number of signatures required")
+	keys := fs.String("keys", "", "comma separated public keys of the co-signers, as printed by wallet address --pubkey")
+	fs.Parse(args)
+
+	var pubs []crypto.PublicKey
+	for _, k := range strings.Split(*keys, ",") {
+		pub, err := bb.DecodePublicKey(strings.TrimSpace(k))
+		if err != nil {
+			return fmt.Errorf("--keys: %v", err)
+		}
+		pubs = append(pubs, pub)
+	}
+	lock, err := bb.PayToMultiSig(*m, pubs)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hex.EncodeToString(lock))
+	return nil
+}
+
+func multiSigCreate(args []string) error {
+	fs := flag.NewFlagSet("wallet multisig create", flag.ExitOnError)
+	node := fs.String("node", "localhost:8000", "URL of the node to talk to")
+	lockHex := fs.String("lock", "", "hex multisig locking script of the coins to spend, see multisig address")
+	to := fs.String("to", "", "address or hex locking script to send coins to")
+	amount := fs.Int("amount", 0, "number of coins to send")
+	feeRate := fs.Int64("feerate", 0, "fee in coins per 1000 bytes, 0 asks the node for an estimate")
+	out := fs.String("out", "", "file to write the unsigned transaction to")
+	fs.Parse(args)
+
+	lock, err := hex.DecodeString(*lockHex)
+	if err != nil {
+		return fmt.Errorf("--lock: %v", err)
+	}
+	receiver, err := parseReceiver(*to)
+	if err != nil {
+		return fmt.Errorf("--to: %v", err)
+	}
+	client := wallet.NewClient(*node)
+	utxos, err := client.SpendableScriptTxOuts(lock)
+	if err != nil {
+		return err
+	}
+	if *feeRate, err = resolveFeeRate(client, *feeRate); err != nil {
+		return err
+	}
+	p, err := wallet.CreateMultiSigTransaction(lock, receiver, int32(*amount), *feeRate, utxos)
+	if err != nil {
+		return err
+	}
+	return writePartial(*out, p)
+}
+
+func multiSigSign(args []string) error {
+	fs := flag.NewFlagSet("wallet multisig sign", flag.ExitOnError)
+	walletPath := fs.String("wallet", wallet.DefaultWalletPath, "path to the wallet")
+	password := fs.String("password", "", "password of an encrypted wallet")
+	keyPath := fs.String("key", "", "standalone key made by wallet keygen to sign with instead of the wallet")
+	txPath := fs.String("tx", "", "file holding the partially signed transaction, it is updated in place")
+	fs.Parse(args)
+
+	p, err := readPartial(*txPath)
+	if err != nil {
+		return err
+	}
+	var keys []crypto.Signer
+	if *keyPath != "" {
+		key, err := wallet.LoadKey(*keyPath)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	} else {
+		w, err := loadUnlocked(*walletPath, *password)
+		if err != nil {
+			return err
+		}
+		if keys, err = w.Keys(); err != nil {
+			return err
+		}
+	}
+	if err := p.Sign(keys...); err != nil {
+		return err
+	}
+	missing, err := p.Missing()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Signed, signatures still missing per input: %v\n", missing)
+	return writePartial(*txPath, p)
+}
+
+func multiSigSend(args []string) error {
+	fs := flag.NewFlagSet("wallet multisig send", flag.ExitOnError)
+	node := fs.String("node", "localhost:8000", "URL of the node to talk to")
+	txPath := fs.String("tx", "", "file holding the signed transaction")
+	fs.Parse(args)
+
+	p, err := readPartial(*txPath)
+	if err != nil {
+		return err
+	}
+	tx, err := p.Finalize()
+	if err != nil {
+		return err
+	}
+	if _, err := wallet.NewClient(*node).SendTransaction(tx); err != nil {
+		return err
+	}
+	fmt.Printf("Sent transaction %x.\n", tx.ID())
+	return nil
+}
+
+func readPartial(path string) (*wallet.PartialTransaction, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p wallet.PartialTransaction
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &p, nil
+}
+
+func writePartial(path string, p *wallet.PartialTransaction) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0600)
 }
@@ -0,0 +1,139 @@
+// Package logging hands out structured loggers per subsystem. Every subsystem has its own level that can be changed while the node is running, so e.g. p2p tracing can be switched on without rebuilding or restarting.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Subsystems that have their own log level.
+const (
+	Chain   = "chain"
+	P2P     = "p2p"
+	Mining  = "mining"
+	Mempool = "mempool"
+	API     = "api"
+)
+
+// Subsystems lists every subsystem, which is what a bare level in SetLevels applies to.
+var Subsystems = []string{Chain, P2P, Mining, Mempool, API}
+
+var mu sync.Mutex
+var levels = map[string]*slog.LevelVar{}
+var base slog.Handler = newBaseHandler(os.Stderr)
+
+func newBaseHandler(w io.Writer) slog.Handler {
+	return slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+}
+
+func levelVar(subsystem string) *slog.LevelVar {
+	mu.Lock()
+	defer mu.Unlock()
+	lv, ok := levels[subsystem]
+	if !ok {
+		lv = new(slog.LevelVar) // defaults to info
+		levels[subsystem] = lv
+	}
+	return lv
+}
+
+// subsystemHandler drops records below its subsystem's current level and hands the rest to the shared base handler.
+type subsystemHandler struct {
+	level *slog.LevelVar
+	attrs []slog.Attr
+	group string
+}
+
+func (h *subsystemHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *subsystemHandler) Handle(ctx context.Context, r slog.Record) error {
+	mu.Lock()
+	handler := base
+	mu.Unlock()
+	if h.group != "" {
+		handler = handler.WithGroup(h.group)
+	}
+	return handler.WithAttrs(h.attrs).Handle(ctx, r)
+}
+
+func (h *subsystemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &subsystemHandler{h.level, append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...), h.group}
+}
+
+func (h *subsystemHandler) WithGroup(name string) slog.Handler {
+	if h.group != "" {
+		name = h.group + "." + name
+	}
+	return &subsystemHandler{h.level, h.attrs, name}
+}
+
+// Logger returns the logger for subsystem. Its records carry a subsystem attribute.
+func Logger(subsystem string) *slog.Logger {
+	return slog.New(&subsystemHandler{level: levelVar(subsystem), attrs: []slog.Attr{slog.String("subsystem", subsystem)}})
+}
+
+// SetOutput sends all log records to w, formatted as JSON if json is true and as logfmt style text otherwise.
+func SetOutput(w io.Writer, json bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if json {
+		base = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	} else {
+		base = newBaseHandler(w)
+	}
+}
+
+// SetLevel changes the level of one subsystem.
+func SetLevel(subsystem string, level slog.Level) {
+	levelVar(subsystem).Set(level)
+}
+
+// SetLevels parses a spec such as "info,p2p=debug,chain=warn". A bare level applies to every known subsystem, later entries override earlier ones.
+func SetLevels(spec string) error {
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		subsystem, levelName, found := strings.Cut(part, "=")
+		if !found {
+			subsystem, levelName = "", part
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(levelName)); err != nil {
+			return fmt.Errorf("invalid log level %q: %v", levelName, err)
+		}
+		if subsystem == "" {
+			for _, s := range Subsystems {
+				SetLevel(s, level)
+			}
+			continue
+		}
+		if !slices.Contains(Subsystems, subsystem) {
+			return fmt.Errorf("unknown log subsystem %q", subsystem)
+		}
+		SetLevel(subsystem, level)
+	}
+	return nil
+}
+
+// Levels returns the current level of every subsystem that has one, as a spec that SetLevels accepts.
+func Levels() string {
+	mu.Lock()
+	defer mu.Unlock()
+	var parts []string
+	for subsystem, lv := range levels {
+		parts = append(parts, subsystem+"="+strings.ToLower(lv.Level().String()))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
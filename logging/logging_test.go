@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSubsystemLevels(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf, false)
+	if err := SetLevels("warn,p2p=debug"); err != nil {
+		t.Fatalf("SetLevels failed: %v", err)
+	}
+
+	Logger(Chain).Info("hidden")
+	Logger(P2P).Debug("shown", "peer", "localhost:9000")
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Errorf("chain info record was logged at warn level: %s", out)
+	}
+	if !strings.Contains(out, "msg=shown") || !strings.Contains(out, "subsystem=p2p") || !strings.Contains(out, "peer=localhost:9000") {
+		t.Errorf("p2p debug record missing or incomplete: %s", out)
+	}
+
+	SetLevel(Chain, slog.LevelDebug)
+	if !strings.Contains(Levels(), "chain=debug") || !strings.Contains(Levels(), "p2p=debug") || !strings.Contains(Levels(), "mining=warn") {
+		t.Errorf("Levels() = %s", Levels())
+	}
+}
+
+func TestSetLevelsErrors(t *testing.T) {
+	if err := SetLevels("loud"); err == nil {
+		t.Errorf("accepted an unknown level")
+	}
+	if err := SetLevels("gossip=debug"); err == nil {
+		t.Errorf("accepted an unknown subsystem")
+	}
+}
@@ -0,0 +1,185 @@
+package node
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+	"github.com/chronologos/naivecoin/wallet"
+)
+
+// The handlers in this file make up the JSON API used by the wallet.
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		apiLog.Warn("writeJSON failed", "err", err)
+	}
+}
+
+// getUnspentTxOuts lists the unspent outputs, optionally only those locked to the address query parameter or with the hex locking script in the script parameter, and with spendable=true only those that may be spent in the next block.
+func (n *Node) getUnspentTxOuts(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	utxos := n.unspentTxOuts
+	nextHeight := n.blockChain.NextHeight()
+	n.mu.Unlock()
+
+	var lock bb.Script
+	if s := r.URL.Query().Get("address"); s != "" {
+		a, err := bb.ParseAddress(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lock = bb.PayToAddress(a)
+	} else if s := r.URL.Query().Get("script"); s != "" {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			http.Error(w, "script is not hex: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		lock = b
+	}
+	spendable := r.URL.Query().Get("spendable") == "true"
+	res := []bb.UnspentTxOut{}
+	for _, utxo := range utxos {
+		if lock != nil && !utxo.LockingScript().Equal(lock) {
+			continue
+		}
+		if spendable && !utxo.IsMature(nextHeight) {
+			continue
+		}
+		res = append(res, utxo)
+	}
+	writeJSON(w, res)
+}
+
+// postTransaction adds a signed transaction to the pool.
+func (n *Node) postTransaction(w http.ResponseWriter, r *http.Request) {
+	var tx bb.Transaction
+	r.Body = http.MaxBytesReader(w, r.Body, int64(4*bb.MaxTxSize)) // hex and JSON roughly double the size twice
+	if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
+		http.Error(w, fmt.Sprintf("could not decode transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+	replacements, err := n.AddTransaction(tx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := tx.ID()
+	res := wallet.SendResult{ID: hex.EncodeToString(id[:]), Replaced: []wallet.ReplacedTx{}}
+	for _, r := range replacements {
+		replacedID := r.Transaction.ID()
+		res.Replaced = append(res.Replaced, wallet.ReplacedTx{ID: hex.EncodeToString(replacedID[:]), Reason: r.Reason})
+	}
+	writeJSON(w, res)
+}
+
+// getTransaction reports whether a transaction is pooled or in a block, where in that block and how deep it is, or which transaction replaced it in the pool and why. With Config.TxIndex confirmed transactions are looked up in the index instead of scanning the chain.
+func (n *Node) getTransaction(w http.ResponseWriter, r *http.Request) {
+	b, err := hex.DecodeString(r.PathValue("id"))
+	if err != nil || len(b) != 32 {
+		http.Error(w, "transaction id must be 32 hex encoded bytes", http.StatusBadRequest)
+		return
+	}
+	var id [32]byte
+	copy(id[:], b)
+
+	n.mu.Lock()
+	tip := n.blockChain[len(n.blockChain)-1]
+	tx, loc, confirmed := n.findTransaction(id)
+	pool := n.txPool
+	replaced, isReplaced := n.replaced[id]
+	n.mu.Unlock()
+
+	if confirmed {
+		writeJSON(w, wallet.TxStatus{
+			Transaction:   tx,
+			BlockHash:     hex.EncodeToString(loc.BlockHash[:]),
+			BlockIndex:    loc.BlockIndex,
+			Position:      loc.Position,
+			Confirmations: int(tip.Index-loc.BlockIndex) + 1,
+		})
+		return
+	}
+	if tx, ok := pool.Find(id); ok {
+		writeJSON(w, wallet.TxStatus{Transaction: tx})
+		return
+	}
+	if isReplaced {
+		writeJSON(w, wallet.TxStatus{Transaction: replaced.Transaction, ReplacedBy: hex.EncodeToString(replaced.By[:]), ReplacedReason: replaced.Reason})
+		return
+	}
+	http.Error(w, "transaction not found", http.StatusNotFound)
+}
+
+// estimateFeeRate is EstimateFeeRate of the node's chain, through its transaction index if it keeps one.
+func (n *Node) estimateFeeRate() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.blockChain.EstimateFeeRate(n.txIndex)
+}
+
+// getFeeEstimate suggests a fee rate based on the fees paid in recent blocks.
+func (n *Node) getFeeEstimate(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, wallet.FeeEstimate{FeeRate: n.estimateFeeRate(), MinRelayFeeRate: bb.MinRelayFeeRate, Blocks: bb.FeeEstimateBlocks})
+}
+
+// currentHeaders is the header chain this node follows: the headers of its blocks, or only headers in light mode.
+func (n *Node) currentHeaders() bb.HeaderChain {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.cfg.Light {
+		return n.headerChain
+	}
+	return n.blockChain.Headers()
+}
+
+// getHeaders lists the headers from the block with index from (the genesis block by default) to the tip, for light clients.
+func (n *Node) getHeaders(w http.ResponseWriter, r *http.Request) {
+	from := bb.GenesisBlock.Index
+	if s := r.URL.Query().Get("from"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			http.Error(w, "from must be a block index", http.StatusBadRequest)
+			return
+		}
+		from = int32(n)
+	}
+	res := bb.HeaderChain{}
+	for _, h := range n.currentHeaders() {
+		if h.Index >= from {
+			res = append(res, h)
+		}
+	}
+	writeJSON(w, res)
+}
+
+// getProofs returns an inclusion proof for every transaction in the chain that pays to the address query parameter, with a PayToAddress output.
+func (n *Node) getProofs(w http.ResponseWriter, r *http.Request) {
+	address, err := bb.ParseAddress(r.URL.Query().Get("address"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lock := bb.PayToAddress(address)
+	bc := n.BlockChain()
+
+	res := []bb.TxInclusionProof{}
+	for i := range bc {
+		for _, tx := range bc[i].Transactions {
+			for _, txOut := range tx.TxOuts() {
+				if txOut.LockingScript().Equal(lock) {
+					proof, _ := bc[i].ProveTransaction(tx.ID())
+					res = append(res, proof)
+					break
+				}
+			}
+		}
+	}
+	writeJSON(w, res)
+}
@@ -0,0 +1,173 @@
+package node
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+)
+
+// Clients subscribe to GET /events to be told about chain and pool changes as Server-Sent Events instead of polling /blocks.
+
+const (
+	eventBlockConnected    = "block-connected"
+	eventBlockDisconnected = "block-disconnected"
+	eventMempoolAdded      = "mempool-added"
+	eventMempoolRemoved    = "mempool-removed"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before it is dropped.
+const subscriberBuffer = 64
+
+type blockEvent struct {
+	Hash         string   `json:"hash"`
+	PreviousHash string   `json:"previousHash"`
+	Index        int32    `json:"index"`
+	Timestamp    string   `json:"timestamp"`
+	Difficulty   int32    `json:"difficulty"`
+	Transactions []string `json:"transactions"`
+}
+
+type txEvent struct {
+	Transaction bb.Transaction `json:"transaction"`
+	Reason      string         `json:"reason,omitempty"`     // why it left the pool
+	ReplacedBy  string         `json:"replacedBy,omitempty"` // the transaction that replaced it, if it was
+}
+
+type event struct {
+	Type        string      `json:"type"`
+	Block       *blockEvent `json:"block,omitempty"`
+	Transaction *txEvent    `json:"transaction,omitempty"`
+}
+
+// eventHub fans events out to the /events subscribers of a node.
+type eventHub struct {
+	mu          sync.Mutex // guards subscribers
+	subscribers map[chan event]bool
+}
+
+func (h *eventHub) subscribe() chan event {
+	ch := make(chan event, subscriberBuffer)
+	h.mu.Lock()
+	h.subscribers[ch] = true
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan event) {
+	h.mu.Lock()
+	if h.subscribers[ch] {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+	h.mu.Unlock()
+}
+
+// publish never blocks, it is called with the node's mu held. Subscribers that cannot keep up are disconnected.
+func (h *eventHub) publish(e event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- e:
+		default:
+			apiLog.Info("dropping slow event subscriber")
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func newBlockEvent(blk *bb.BasicBlock) *blockEvent {
+	e := &blockEvent{
+		Hash:         hex.EncodeToString(blk.Hash[:]),
+		PreviousHash: hex.EncodeToString(blk.PreviousHash[:]),
+		Index:        blk.Index,
+		Timestamp:    blk.Timestamp.UTC().Format(time.RFC3339),
+		Difficulty:   blk.Difficulty,
+		Transactions: []string{},
+	}
+	for _, tx := range blk.Transactions {
+		id := tx.ID()
+		e.Transactions = append(e.Transactions, hex.EncodeToString(id[:]))
+	}
+	return e
+}
+
+// publishChainChanges announces the blocks that were disconnected (tip first) and connected when the chain went from orig to next.
+func (n *Node) publishChainChanges(orig, next bb.BlockChain) {
+	fork := bb.ForkPoint(orig, next)
+	for i := len(orig) - 1; i >= fork; i-- {
+		n.events.publish(event{Type: eventBlockDisconnected, Block: newBlockEvent(&orig[i])})
+	}
+	for i := fork; i < len(next); i++ {
+		n.events.publish(event{Type: eventBlockConnected, Block: newBlockEvent(&next[i])})
+	}
+}
+
+// publishPoolChanges announces transactions that left the pool, either because a block of n.blockChain confirmed them or because their inputs got spent elsewhere. mu must be held.
+func (n *Node) publishPoolChanges(orig, next bb.TransactionPool) {
+	for _, tx := range orig {
+		id := tx.ID()
+		if _, ok := next.Find(id); ok {
+			continue
+		}
+		reason := "conflict"
+		if _, _, ok := n.findTransaction(id); ok {
+			reason = "confirmed"
+		}
+		n.events.publish(event{Type: eventMempoolRemoved, Transaction: &txEvent{Transaction: tx, Reason: reason}})
+	}
+}
+
+// streamEvents sends events as they happen. The optional types query parameter is a comma separated list of event types to receive.
+func (n *Node) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	wanted := make(map[string]bool)
+	if types := r.URL.Query().Get("types"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			wanted[strings.TrimSpace(t)] = true
+		}
+	}
+
+	ch := n.events.subscribe()
+	defer n.events.unsubscribe(ch)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if len(wanted) > 0 && !wanted[e.Type] {
+				continue
+			}
+			b, err := json.Marshal(e)
+			if err != nil {
+				n.apiLog.Warn("could not encode event", "err", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, b)
+		}
+		flusher.Flush()
+	}
+}
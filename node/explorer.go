@@ -0,0 +1,335 @@
+package node
+
+import (
+	"embed"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+)
+
+// The block explorer is a handful of server-rendered pages, the templates are compiled into the binary so the node serves everything itself.
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"hash": func(h [32]byte) string { return hex.EncodeToString(h[:]) },
+	"time": func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+}).ParseFS(templateFS, "templates/*.html"))
+
+// explorerBlocks is how many blocks the index page lists.
+const explorerBlocks = 20
+
+type blockSummary struct {
+	Index        int32
+	Hash         [32]byte
+	Timestamp    time.Time
+	Difficulty   int32
+	Transactions int
+}
+
+type txInView struct {
+	TxOutID    string
+	TxOutIndex int32
+	Address    string // see lockView, empty if the spent output is unknown
+	Script     string
+	Amount     int32
+}
+
+type txOutView struct {
+	Index   int
+	Address string // see lockView
+	Script  string
+	Amount  int32
+	Spent   bool
+}
+
+type txView struct {
+	ID            string
+	Coinbase      bool
+	BlockHeight   int32 // coinbase only
+	Block         *blockSummary
+	Confirmations int
+	TxIns         []txInView
+	TxOuts        []txOutView
+}
+
+type blockView struct {
+	blockSummary
+	Version       int32
+	PreviousHash  [32]byte
+	PayloadRoot   [32]byte
+	Nonce         string
+	Data          string
+	Confirmations int
+	Next          *int32
+	Transactions  []txView
+}
+
+type historyEntry struct {
+	TxID          string
+	Block         *blockSummary
+	Confirmations int
+	Received      int32
+	Sent          int32
+}
+
+type addressView struct {
+	Address string // see lockView
+	Script  string
+	Balance int32
+	History []historyEntry
+}
+
+type indexView struct {
+	Height  int32
+	Blocks  []blockSummary
+	Pending []txView
+}
+
+type searchView struct {
+	Query string
+}
+
+// explorerState is a consistent snapshot of the node taken under mu for rendering a single page.
+type explorerState struct {
+	blockChain    bb.BlockChain
+	unspentTxOuts []bb.UnspentTxOut
+	txPool        bb.TransactionPool
+	txs           map[[32]byte]bb.Transaction
+}
+
+func (n *Node) snapshotExplorerState() *explorerState {
+	n.mu.Lock()
+	s := &explorerState{blockChain: n.blockChain, unspentTxOuts: n.unspentTxOuts, txPool: n.txPool}
+	n.mu.Unlock()
+	s.txs = make(map[[32]byte]bb.Transaction)
+	for _, blk := range s.blockChain {
+		for _, tx := range blk.Transactions {
+			s.txs[tx.ID()] = tx
+		}
+	}
+	return s
+}
+
+func summarize(blk *bb.BasicBlock) blockSummary {
+	return blockSummary{blk.Index, blk.Hash, blk.Timestamp, blk.Difficulty, len(blk.Transactions)}
+}
+
+func (s *explorerState) isUnspent(txOutID [32]byte, txOutIndex int32) bool {
+	for _, utxo := range s.unspentTxOuts {
+		if utxo.TxOutID() == txOutID && utxo.TxOutIndex() == txOutIndex {
+			return true
+		}
+	}
+	return false
+}
+
+// txView resolves the outputs spent by tx so the page can show where the coins came from. i is the position of the containing block in the chain, or -1 for pooled transactions.
+func (s *explorerState) txView(tx bb.Transaction, i int) txView {
+	id := tx.ID()
+	v := txView{ID: hex.EncodeToString(id[:])}
+	if i >= 0 {
+		sum := summarize(&s.blockChain[i])
+		v.Block = &sum
+		v.Confirmations = len(s.blockChain) - i
+		v.Coinbase = len(s.blockChain[i].Transactions) > 0 && s.blockChain[i].Transactions[0].ID() == id
+	}
+	for _, txIn := range tx.TxIns() {
+		if v.Coinbase {
+			v.BlockHeight = txIn.TxOutIndex()
+			break
+		}
+		txOutID := txIn.TxOutID()
+		in := txInView{TxOutID: hex.EncodeToString(txOutID[:]), TxOutIndex: txIn.TxOutIndex()}
+		if prev, ok := s.txs[txOutID]; ok && int(txIn.TxOutIndex()) < len(prev.TxOuts()) {
+			txOut := prev.TxOuts()[txIn.TxOutIndex()]
+			in.Address, in.Script = lockView(txOut.LockingScript())
+			in.Amount = txOut.Amount()
+		}
+		v.TxIns = append(v.TxIns, in)
+	}
+	for j, txOut := range tx.TxOuts() {
+		address, script := lockView(txOut.LockingScript())
+		v.TxOuts = append(v.TxOuts, txOutView{j, address, script, txOut.Amount(), i >= 0 && !s.isUnspent(id, int32(j))})
+	}
+	return v
+}
+
+// lockView returns how the explorer names a locking script: the address it pays to, or its hex for other locks, which the address pages are keyed by, and its disassembly.
+func lockView(lock bb.Script) (string, string) {
+	if address, ok := lock.Address(); ok {
+		return address.String(), lock.String()
+	}
+	return hex.EncodeToString(lock), lock.String()
+}
+
+// parseLock returns the locking script an address page is about. s is either an address, whose PayToAddress lock is meant, or a locking script in hex.
+func parseLock(s string) (bb.Script, bool) {
+	if address, err := bb.ParseAddress(s); err == nil {
+		return bb.PayToAddress(address), true
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil && len(b) > 0
+}
+
+// findBlock accepts a block hash or an index.
+func (s *explorerState) findBlock(q string) (int, bool) {
+	if index, err := strconv.ParseInt(q, 10, 32); err == nil {
+		for i := range s.blockChain {
+			if s.blockChain[i].Index == int32(index) {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	for i := range s.blockChain {
+		if hex.EncodeToString(s.blockChain[i].Hash[:]) == strings.ToLower(q) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (s *explorerState) findTransaction(q string) (bb.Transaction, int, bool) {
+	b, err := hex.DecodeString(q)
+	if err != nil || len(b) != 32 {
+		return bb.Transaction{}, 0, false
+	}
+	var id [32]byte
+	copy(id[:], b)
+	if tx, loc, ok := s.blockChain.FindTransaction(id); ok {
+		return tx, int(loc.BlockIndex - s.blockChain[0].Index), true
+	}
+	if tx, ok := s.txPool.Find(id); ok {
+		return tx, -1, true
+	}
+	return bb.Transaction{}, 0, false
+}
+
+func render(w http.ResponseWriter, name string, v interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates.ExecuteTemplate(w, name, v); err != nil {
+		apiLog.Warn("rendering failed", "template", name, "err", err)
+	}
+}
+
+func notFound(w http.ResponseWriter, query string) {
+	w.WriteHeader(http.StatusNotFound)
+	render(w, "notfound.html", searchView{query})
+}
+
+// displayIndex shows the latest blocks and the transaction pool.
+func (n *Node) displayIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	s := n.snapshotExplorerState()
+	v := indexView{Height: s.blockChain[len(s.blockChain)-1].Index}
+	for i := len(s.blockChain) - 1; i >= 0 && len(v.Blocks) < explorerBlocks; i-- {
+		v.Blocks = append(v.Blocks, summarize(&s.blockChain[i]))
+	}
+	for _, tx := range s.txPool {
+		v.Pending = append(v.Pending, s.txView(tx, -1))
+	}
+	render(w, "index.html", v)
+}
+
+func (n *Node) displayBlock(w http.ResponseWriter, r *http.Request) {
+	s := n.snapshotExplorerState()
+	i, ok := s.findBlock(r.PathValue("id"))
+	if !ok {
+		notFound(w, r.PathValue("id"))
+		return
+	}
+	blk := &s.blockChain[i]
+	v := blockView{
+		blockSummary:  summarize(blk),
+		Version:       blk.Version,
+		PreviousHash:  blk.PreviousHash,
+		PayloadRoot:   blk.PayloadRoot,
+		Nonce:         hex.EncodeToString(blk.Nonce),
+		Data:          string(blk.Data),
+		Confirmations: len(s.blockChain) - i,
+	}
+	if i+1 < len(s.blockChain) {
+		v.Next = &s.blockChain[i+1].Index
+	}
+	for _, tx := range blk.Transactions {
+		v.Transactions = append(v.Transactions, s.txView(tx, i))
+	}
+	render(w, "block.html", v)
+}
+
+func (n *Node) displayTransaction(w http.ResponseWriter, r *http.Request) {
+	s := n.snapshotExplorerState()
+	tx, i, ok := s.findTransaction(r.PathValue("id"))
+	if !ok {
+		notFound(w, r.PathValue("id"))
+		return
+	}
+	render(w, "transaction.html", s.txView(tx, i))
+}
+
+func (n *Node) displayAddress(w http.ResponseWriter, r *http.Request) {
+	lock, ok := parseLock(r.PathValue("address"))
+	if !ok {
+		notFound(w, r.PathValue("address"))
+		return
+	}
+	s := n.snapshotExplorerState()
+	var v addressView
+	v.Address, v.Script = lockView(lock)
+	for _, utxo := range s.unspentTxOuts {
+		if utxo.Amount() > 0 && utxo.LockingScript().Equal(lock) {
+			v.Balance += utxo.Amount()
+		}
+	}
+	for i := len(s.blockChain) - 1; i >= 0; i-- {
+		for _, tx := range s.blockChain[i].Transactions {
+			tv := s.txView(tx, i)
+			var e historyEntry
+			for _, in := range tv.TxIns {
+				if in.Address == v.Address {
+					e.Sent += in.Amount
+				}
+			}
+			for _, out := range tv.TxOuts {
+				if out.Address == v.Address {
+					e.Received += out.Amount
+				}
+			}
+			if e.Sent != 0 || e.Received != 0 {
+				e.TxID, e.Block, e.Confirmations = tv.ID, tv.Block, tv.Confirmations
+				v.History = append(v.History, e)
+			}
+		}
+	}
+	render(w, "address.html", v)
+}
+
+// search redirects to the page of whatever q names: a block index or hash, a transaction id, an address or a locking script.
+func (n *Node) search(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	s := n.snapshotExplorerState()
+	if _, ok := s.findBlock(q); ok {
+		http.Redirect(w, r, "/block/"+url.PathEscape(q), http.StatusFound)
+		return
+	}
+	if _, _, ok := s.findTransaction(q); ok {
+		http.Redirect(w, r, "/transaction/"+url.PathEscape(q), http.StatusFound)
+		return
+	}
+	if _, ok := parseLock(q); ok {
+		http.Redirect(w, r, "/address/"+url.PathEscape(q), http.StatusFound)
+		return
+	}
+	notFound(w, q)
+}
@@ -0,0 +1,213 @@
+package node
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+	"github.com/chronologos/naivecoin/wallet"
+)
+
+// A light node keeps only block headers. It follows the header chain with the most work among its full nodes, and checks payments to its wallet with Merkle proofs against those headers instead of trusting the full nodes.
+
+// payment is a transaction paying to the light client's wallet, proven to be in the block with hash BlockHash.
+type payment struct {
+	TxID          string `json:"txId"`
+	Amount        int32  `json:"amount"`
+	BlockHash     string `json:"blockHash"`
+	BlockIndex    int32  `json:"blockIndex"`
+	Confirmations int    `json:"confirmations"`
+	blockHash     [32]byte
+}
+
+func (n *Node) runLight() {
+	var clients []*wallet.Client
+	for _, node := range n.cfg.FullNodes {
+		clients = append(clients, wallet.NewClient(strings.TrimSpace(node)))
+	}
+	discovered := false
+	for {
+		for _, client := range clients {
+			n.syncHeaders(client)
+		}
+		if !discovered {
+			discovered = n.discoverWallet(clients)
+		}
+		addresses := n.walletAddresses()
+		for _, client := range clients {
+			n.verifyPayments(client, addresses)
+		}
+		select {
+		case <-n.done:
+			return
+		case <-n.ticker.C():
+		}
+	}
+}
+
+// syncHeaders asks client for the headers past our tip and switches to its chain if that has more work. If our tip is not in its chain, the whole chain is fetched.
+func (n *Node) syncHeaders(client *wallet.Client) {
+	n.mu.Lock()
+	local := n.headerChain
+	n.mu.Unlock()
+	tip := local[len(local)-1]
+
+	fetched, err := client.Headers(tip.Index)
+	if err != nil {
+		n.p2pLog.Warn("fetching headers failed", "node", client.Node, "err", err)
+		return
+	}
+	var candidate bb.HeaderChain
+	if len(fetched) > 0 && fetched[0].Hash == tip.Hash {
+		candidate = append(local[:len(local)-1:len(local)-1], fetched...)
+	} else {
+		if candidate, err = client.Headers(bb.GenesisBlock.Index); err != nil {
+			n.p2pLog.Warn("fetching headers failed", "node", client.Node, "err", err)
+			return
+		}
+	}
+	if !candidate.IsValidAt(n.networkTime.Now()) {
+		n.p2pLog.Warn("full node served invalid headers", "node", client.Node)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if candidate.CumulativeDifficulty().Cmp(n.headerChain.CumulativeDifficulty()) > 0 {
+		if !isPrefix(n.headerChain, candidate) {
+			n.metrics.reorgs.add(1)
+		}
+		n.headerChain = candidate
+		n.chainLog.Info("header chain updated", "node", client.Node, "height", candidate[len(candidate)-1].Index-bb.GenesisBlock.Index)
+	}
+}
+
+func isPrefix(short, long bb.HeaderChain) bool {
+	if len(short) > len(long) {
+		return false
+	}
+	for i := range short {
+		if short[i].Hash != long[i].Hash {
+			return false
+		}
+	}
+	return true
+}
+
+// discoverWallet moves the wallet past the addresses that the full nodes know payments to, so that a light node whose wallet was restored from its seed verifies those too. It reports whether discovery is done: it is tried again while no full node answers.
+func (n *Node) discoverWallet(clients []*wallet.Client) bool {
+	if n.cfg.Wallet == nil {
+		return true
+	}
+	n.walletMu.Lock()
+	defer n.walletMu.Unlock()
+	err := n.cfg.Wallet.Discover(func(pub ecdsa.PublicKey) (bool, error) {
+		var err error
+		for _, client := range clients {
+			var used bool
+			if used, err = client.Used(bb.NewAddress(pub).String()); err == nil {
+				return used, nil
+			}
+		}
+		return false, err
+	})
+	if err == nil && n.cfg.WalletPath != "" {
+		err = n.cfg.Wallet.Save(n.cfg.WalletPath)
+	}
+	if err != nil {
+		n.p2pLog.Warn("discovering wallet addresses failed", "err", err)
+		return false
+	}
+	return true
+}
+
+// walletAddresses are the addresses a light node verifies payments to: Config.Address and every address its wallet handed out or discovered.
+func (n *Node) walletAddresses() []bb.Address {
+	addresses := []bb.Address{bb.NewAddress(n.cfg.Address)}
+	if n.cfg.Wallet == nil {
+		return addresses
+	}
+	n.walletMu.Lock()
+	pubs, err := n.cfg.Wallet.PublicKeys()
+	n.walletMu.Unlock()
+	if err != nil {
+		n.chainLog.Error("deriving wallet addresses failed", "err", err)
+	}
+	for _, pub := range pubs {
+		if address := bb.NewAddress(pub); !slices.Contains(addresses, address) {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// verifyPayments fetches proofs for transactions paying to any of addresses and keeps those that check out against our headers.
+func (n *Node) verifyPayments(client *wallet.Client, addresses []bb.Address) {
+	var proofs []bb.TxInclusionProof
+	var locks []bb.Script
+	for _, address := range addresses {
+		fetched, err := client.Proofs(address.String())
+		if err != nil {
+			n.p2pLog.Warn("fetching proofs failed", "node", client.Node, "err", err)
+			return
+		}
+		proofs = append(proofs, fetched...)
+		locks = append(locks, bb.PayToAddress(address))
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	byHash := make(map[[32]byte]int)
+	for i, h := range n.headerChain {
+		byHash[h.Hash] = i
+	}
+	for _, proof := range proofs {
+		i, ok := byHash[proof.BlockHash]
+		if !ok || !proof.Verify(&n.headerChain[i]) {
+			n.chainLog.Warn("full node served a proof that does not match our headers", "node", client.Node, "block", hex.EncodeToString(proof.BlockHash[:]))
+			continue
+		}
+		var amount int64
+		for _, txOut := range proof.Transaction.TxOuts() {
+			if slices.ContainsFunc(locks, txOut.LockingScript().Equal) {
+				amount += int64(txOut.Amount())
+			}
+		}
+		if amount > math.MaxInt32 {
+			n.chainLog.Warn("full node served a payment larger than any amount", "node", client.Node, "block", hex.EncodeToString(proof.BlockHash[:]))
+			continue
+		}
+		if amount <= 0 {
+			continue
+		}
+		id := proof.Transaction.ID()
+		n.payments[id] = payment{TxID: hex.EncodeToString(id[:]), Amount: int32(amount), BlockHash: hex.EncodeToString(proof.BlockHash[:]), blockHash: proof.BlockHash}
+	}
+}
+
+// getPayments lists the verified payments to a light client's wallet that are in its current best header chain.
+func (n *Node) getPayments(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	byHash := make(map[[32]byte]int)
+	for i, h := range n.headerChain {
+		byHash[h.Hash] = i
+	}
+	res := []payment{}
+	for _, p := range n.payments {
+		i, ok := byHash[p.blockHash]
+		if !ok { // reorged away
+			continue
+		}
+		p.BlockIndex = n.headerChain[i].Index
+		p.Confirmations = len(n.headerChain) - i
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].BlockIndex < res[j].BlockIndex })
+	writeJSON(w, res)
+}
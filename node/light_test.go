@@ -0,0 +1,58 @@
+package node
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+	"github.com/chronologos/naivecoin/logging"
+	"github.com/chronologos/naivecoin/wallet"
+)
+
+func TestLightVerifiesWalletAddresses(t *testing.T) {
+	logging.SetOutput(io.Discard, false)
+	hd, err := wallet.NewHDWallet([]byte("light node test seed, 32 bytes.."), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The full node mines to the wallet's second receive address, which a wallet restored from the seed has not handed out yet.
+	paid, err := hd.PublicKey(wallet.ReceiveChain, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := New(Config{Address: paid})
+	full.Start()
+	t.Cleanup(full.Stop)
+	blk, err := full.MineBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(full.Handler())
+	t.Cleanup(srv.Close)
+
+	address, err := hd.NextPublicKey(wallet.ReceiveChain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	light := New(Config{Light: true, FullNodes: []string{srv.URL}, Address: address, Wallet: hd})
+	light.Start()
+	t.Cleanup(light.Stop)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		light.mu.Lock()
+		_, ok := light.payments[blk.Transactions[0].ID()]
+		light.mu.Unlock()
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("payment to a discovered address was not verified")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if addresses := light.walletAddresses(); len(addresses) != 2 || addresses[1] != bb.NewAddress(paid) {
+		t.Errorf("light node verifies %d addresses, want Address and the discovered one", len(addresses))
+	}
+}
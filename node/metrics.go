@@ -0,0 +1,191 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+)
+
+// GET /metrics exposes the node's state in the Prometheus text format. The few metric types needed are implemented here rather than pulling in a client library.
+
+type counterVec struct {
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec() *counterVec {
+	return &counterVec{values: make(map[string]float64)}
+}
+
+func (c *counterVec) add(label string, v float64) {
+	c.mu.Lock()
+	c.values[label] += v
+	c.mu.Unlock()
+}
+
+func (c *counterVec) get(label string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[label]
+}
+
+type counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (c *counter) add(v float64) {
+	c.mu.Lock()
+	c.value += v
+	c.mu.Unlock()
+}
+
+func (c *counter) get() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+type gauge struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (g *gauge) set(v float64) {
+	g.mu.Lock()
+	g.value = v
+	g.mu.Unlock()
+}
+
+func (g *gauge) get() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64 // upper bounds, ascending
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets ...float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// metrics are the counters a node keeps for /metrics, the rest is read from its state when scraped.
+type metrics struct {
+	messagesIn        *counterVec
+	messagesOut       *counterVec
+	reorgs            counter
+	misbehavior       *counterVec // by reason
+	bans              counter
+	minerHashes       counter
+	minerHashRate     gauge
+	validationLatency *histogram
+}
+
+func newMetrics() metrics {
+	return metrics{
+		messagesIn:        newCounterVec(),
+		messagesOut:       newCounterVec(),
+		misbehavior:       newCounterVec(),
+		validationLatency: newHistogram(0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5),
+	}
+}
+
+func (t messageType) String() string {
+	switch t {
+	case msgBlockChain:
+		return "blockchain"
+	case msgTransaction:
+		return "transaction"
+	case msgVersion:
+		return "version"
+	}
+	return fmt.Sprintf("unknown(%d)", int(t))
+}
+
+// validateBlockChain is bc.IsValid, timed for the validation latency histogram.
+func (n *Node) validateBlockChain(bc bb.BlockChain) bool {
+	start := time.Now()
+	valid := bc.IsValidAt(n.networkTime.Now())
+	n.metrics.validationLatency.observe(time.Since(start).Seconds())
+	return valid
+}
+
+func writeMetric(w http.ResponseWriter, name, kind, help string, v float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, v)
+}
+
+func writeCounterVec(w http.ResponseWriter, name, label, help string, c *counterVec) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []string
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s=%q} %g\n", name, label, k, c.values[k])
+	}
+}
+
+func writeHistogram(w http.ResponseWriter, name, help string, h *histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, upper, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %g\n%s_count %d\n", name, h.count, name, h.sum, name, h.count)
+}
+
+func (n *Node) displayMetrics(w http.ResponseWriter, r *http.Request) {
+	headers := n.currentHeaders()
+	height := headers[len(headers)-1].Index - bb.GenesisBlock.Index
+	work, _ := headers.CumulativeDifficulty().Float64()
+	n.mu.Lock()
+	difficulty := n.difficulty
+	poolSize := len(n.txPool)
+	n.mu.Unlock()
+	n.peersMu.Lock()
+	peers := len(n.peers)
+	n.peersMu.Unlock()
+	m := &n.metrics
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetric(w, "naivecoin_chain_height", "gauge", "Height of the best chain, the genesis block has height 0.", float64(height))
+	writeMetric(w, "naivecoin_difficulty", "gauge", "Difficulty the next block has to be mined at.", float64(difficulty))
+	writeMetric(w, "naivecoin_cumulative_work", "gauge", "Sum of 2^difficulty over the best chain.", work)
+	writeMetric(w, "naivecoin_peers", "gauge", "Number of connected websocket peers.", float64(peers))
+	writeCounterVec(w, "naivecoin_messages_received_total", "type", "Messages received from peers.", m.messagesIn)
+	writeCounterVec(w, "naivecoin_messages_sent_total", "type", "Messages sent to peers.", m.messagesOut)
+	writeCounterVec(w, "naivecoin_peer_misbehavior_total", "reason", "Misbehaviour counted toward peers' ban scores.", m.misbehavior)
+	writeMetric(w, "naivecoin_peer_bans_total", "counter", "Peers banned for reaching the ban threshold.", m.bans.get())
+	writeMetric(w, "naivecoin_mempool_transactions", "gauge", "Transactions waiting in the pool.", float64(poolSize))
+	writeMetric(w, "naivecoin_miner_hashes_total", "counter", "Block hashes computed by the miner.", m.minerHashes.get())
+	writeMetric(w, "naivecoin_miner_hash_rate", "gauge", "Hashes per second the miner achieved on its last block.", m.minerHashRate.get())
+	writeHistogram(w, "naivecoin_block_validation_seconds", "Time spent validating received or mined blockchains.", m.validationLatency)
+	writeMetric(w, "naivecoin_time_offset_seconds", "gauge", "Median offset of the peers' clocks from ours, applied to the network-adjusted time.", n.networkTime.Offset().Seconds())
+	writeMetric(w, "naivecoin_reorgs_total", "counter", "Times the best chain switched to a fork that disconnected blocks.", m.reorgs.get())
+}
@@ -0,0 +1,438 @@
+// Package node is a naivecoin node: it keeps a blockchain and transaction pool, mines, talks to its peers over websockets and serves the http API and block explorer. Several nodes can run in one process, which is what the simulation package does.
+package node
+
+import (
+	"crypto"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+	"github.com/chronologos/naivecoin/logging"
+	"github.com/chronologos/naivecoin/wallet"
+	"github.com/gorilla/websocket"
+)
+
+// Config says what a node does.
+type Config struct {
+	Name         string           // added to every log line when set, to tell nodes sharing a process apart
+	Mines        bool             // mine a block every MineInterval
+	Light        bool             // only follow headers from FullNodes and verify payments to Address and the addresses of Wallet
+	TxIndex      bool             // keep a bb.TxIndex, so GET /tx/{id} finds confirmed transactions without scanning the chain
+	FullNodes    []string         // full nodes a light node gets headers and proofs from
+	Address      crypto.PublicKey // of any bb.SignatureScheme, receives the coinbase of mined blocks, or whose payments a light node verifies
+	Wallet       *wallet.HDWallet // served by AdminHandler if set
+	WalletPath   string           // where Wallet is saved when it hands out change addresses, not saved if empty
+	Clock        bb.Clock         // drives mining, light client syncing, rate limits, bans and Wallet's unlock timeout, bb.LocalClock if nil
+	NetworkTime  *bb.NetworkTime  // Clock adjusted by the peers' clocks, validates and timestamps blocks; a new one on Clock if nil, so nodes sharing a process do not share it
+	MineInterval time.Duration    // 5s if zero
+	PeerLimits                    // zero fields take their DefaultPeerLimits value
+}
+
+// Node is a running node. Its state is only touched through its methods and http handlers.
+type Node struct {
+	cfg         Config
+	clock       bb.Clock
+	networkTime *bb.NetworkTime
+	mux         *http.ServeMux
+	adminMux    *http.ServeMux
+
+	peersMu sync.Mutex // guards peers and banned
+	peers   []*peer
+	banned  map[string]time.Time // host to end of ban
+
+	walletMu sync.Mutex // serializes handing out addresses of cfg.Wallet
+
+	mu            sync.Mutex // guards blockChain, txIndex, unspentTxOuts, txPool, replaced, difficulty, headerChain and payments
+	blockChain    bb.BlockChain
+	txIndex       bb.TxIndex // nil unless cfg.TxIndex
+	unspentTxOuts []bb.UnspentTxOut
+	txPool        bb.TransactionPool
+	replaced      map[[32]byte]bb.Replacement // recently evicted from txPool, by transaction id
+	difficulty    int32
+	headerChain   bb.HeaderChain
+	payments      map[[32]byte]payment // by transaction id
+
+	inCh   chan message
+	outCh  chan message
+	done   chan struct{}
+	ticker bb.Ticker
+
+	events  eventHub
+	metrics metrics
+
+	chainLog, p2pLog, miningLog, mempoolLog, apiLog *slog.Logger
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+type messageType int
+
+const (
+	msgBlockChain messageType = iota
+	msgTransaction
+	msgVersion // handshake, sent once when a connection is established
+)
+
+// message is what peers send each other over the websocket. Only the field matching Type is set.
+type message struct {
+	Type        messageType
+	BlockChain  bb.BlockChain
+	Transaction bb.Transaction
+	Time        time.Time // sender's clock, for msgVersion
+	from        *peer     // set on receipt, not sent
+}
+
+// apiLog is for the helpers that are not tied to one node.
+var apiLog = logging.Logger(logging.API)
+
+// fatal logs at error level and exits.
+func fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// New returns a node at the genesis block. Nothing happens until Start is called and Handler is served.
+func New(cfg Config) *Node {
+	if cfg.Clock == nil {
+		cfg.Clock = bb.LocalClock
+	}
+	if cfg.NetworkTime == nil {
+		cfg.NetworkTime = bb.NewNetworkTime(cfg.Clock)
+	}
+	if cfg.MineInterval == 0 {
+		cfg.MineInterval = 5 * time.Second // TODO(chronologos) remove eventually, when we have real mining.
+	}
+	cfg.PeerLimits = cfg.PeerLimits.withDefaults()
+	if cfg.Wallet != nil {
+		cfg.Wallet.SetClock(cfg.Clock)
+	}
+	n := &Node{
+		cfg:         cfg,
+		clock:       cfg.Clock,
+		networkTime: cfg.NetworkTime,
+		mux:         http.NewServeMux(),
+		adminMux:    http.NewServeMux(),
+		blockChain:  bb.BlockChain{bb.GenesisBlock},
+		difficulty:  bb.GenesisBlock.Difficulty,
+		payments:    make(map[[32]byte]payment),
+		replaced:    make(map[[32]byte]bb.Replacement),
+		banned:      make(map[string]time.Time),
+		inCh:        make(chan message),
+		outCh:       make(chan message),
+		done:        make(chan struct{}),
+		events:      eventHub{subscribers: make(map[chan event]bool)},
+		metrics:     newMetrics(),
+	}
+	logger := func(subsystem string) *slog.Logger {
+		l := logging.Logger(subsystem)
+		if cfg.Name != "" {
+			l = l.With("node", cfg.Name)
+		}
+		return l
+	}
+	n.chainLog, n.p2pLog, n.miningLog, n.mempoolLog, n.apiLog = logger(logging.Chain), logger(logging.P2P), logger(logging.Mining), logger(logging.Mempool), logger(logging.API)
+	if cfg.Light {
+		n.headerChain = bb.HeaderChain{bb.GenesisBlock.BlockHeader}
+	} else if cfg.TxIndex {
+		n.txIndex = bb.NewTxIndex(n.blockChain)
+	}
+
+	n.mux.HandleFunc("/", n.displayIndex)
+	n.mux.HandleFunc("/blocks", n.displayBlockchain)
+	n.mux.HandleFunc("/p", n.parsePost)
+	n.mux.HandleFunc("/ws", n.websocketHandler)
+	n.mux.HandleFunc("GET /utxos", n.getUnspentTxOuts)
+	n.mux.HandleFunc("POST /tx", n.postTransaction)
+	n.mux.HandleFunc("GET /tx/{id}", n.getTransaction)
+	n.mux.HandleFunc("GET /block/{id}", n.displayBlock)
+	n.mux.HandleFunc("GET /transaction/{id}", n.displayTransaction)
+	n.mux.HandleFunc("GET /address/{address}", n.displayAddress)
+	n.mux.HandleFunc("GET /search", n.search)
+	n.mux.HandleFunc("GET /events", n.streamEvents)
+	n.mux.HandleFunc("GET /metrics", n.displayMetrics)
+	n.mux.HandleFunc("GET /headers", n.getHeaders)
+	n.mux.HandleFunc("GET /proofs", n.getProofs)
+	n.mux.HandleFunc("GET /payments", n.getPayments)
+	n.mux.HandleFunc("GET /fee-estimate", n.getFeeEstimate)
+	n.adminMux.HandleFunc("/log", n.logLevelsHandler)
+	n.adminMux.HandleFunc("GET /wallet", n.getWallet)
+	n.adminMux.HandleFunc("POST /wallet/unlock", n.unlockWallet)
+	n.adminMux.HandleFunc("POST /wallet/lock", n.lockWallet)
+	n.adminMux.HandleFunc("POST /wallet/send", n.sendFromWallet)
+	return n
+}
+
+// Handler serves the node's http API, explorer and peer websocket.
+func (n *Node) Handler() http.Handler {
+	return n.mux
+}
+
+// AdminHandler serves what only the node's operator may use, without asking for a credential: the wallet, which spends its coins, and the log levels. It is not part of Handler: serve it only where the operator alone can reach it, like a loopback address.
+func (n *Node) AdminHandler() http.Handler {
+	return n.adminMux
+}
+
+// Start runs the node in the background: relaying messages, and mining or following headers if configured to.
+func (n *Node) Start() {
+	n.ticker = n.clock.NewTicker(n.cfg.MineInterval)
+	go n.wsWriter()
+	go n.updateBlockchain()
+
+	if n.cfg.Light {
+		n.chainLog.Info("verifying payments", "address", bb.NewAddress(n.cfg.Address), "fullnodes", n.cfg.FullNodes)
+		go n.runLight()
+	} else if n.cfg.Mines {
+		n.miningLog.Info("coinbase address", "address", bb.NewAddress(n.cfg.Address))
+		go n.mine()
+	}
+}
+
+// Stop ends the node's background work and closes its peer connections.
+func (n *Node) Stop() {
+	close(n.done)
+	if n.ticker != nil {
+		n.ticker.Stop()
+	}
+	n.peersMu.Lock()
+	for _, p := range n.peers {
+		p.conn.Close()
+	}
+	n.peersMu.Unlock()
+}
+
+// Mode describes what the node does, for logging.
+func (n *Node) Mode() string {
+	switch {
+	case n.cfg.Light:
+		return "light node"
+	case n.cfg.Mines:
+		return "mining node"
+	}
+	return "non-mining node"
+}
+
+// BlockChain returns the node's current best chain.
+func (n *Node) BlockChain() bb.BlockChain {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.blockChain
+}
+
+// findTransaction finds a confirmed transaction and where it is in n.blockChain, through the index if the node keeps one. mu must be held.
+func (n *Node) findTransaction(id [32]byte) (bb.Transaction, bb.TxLocation, bool) {
+	if n.txIndex != nil {
+		return n.txIndex.Find(n.blockChain, id)
+	}
+	return n.blockChain.FindTransaction(id)
+}
+
+// replaceBlockChain switches to bc if it beats the current chain and drops pooled transactions that are no longer valid. It reports whether the tip changed. mu must be held.
+func (n *Node) replaceBlockChain(bc bb.BlockChain) bool {
+	orig, origPool := n.blockChain, n.txPool
+	n.blockChain = bb.PossiblyReplaceAt(n.blockChain, bc, n.networkTime.Now())
+	var err error
+	if n.difficulty, err = bb.GetDifficulty(n.blockChain); err != nil {
+		fatal(n.chainLog, "blockchain length is 0")
+	}
+	n.unspentTxOuts, err = n.blockChain.UnspentTxOuts()
+	if err != nil {
+		fatal(n.chainLog, "current blockchain has invalid transactions", "err", err)
+	}
+	n.txPool = n.txPool.Update(n.unspentTxOuts, n.blockChain.NextContext())
+	if n.txIndex != nil {
+		n.txIndex.Update(orig, n.blockChain)
+	}
+	if bb.ForkPoint(orig, n.blockChain) < len(orig) {
+		n.metrics.reorgs.add(1)
+	}
+	n.publishChainChanges(orig, n.blockChain)
+	n.publishPoolChanges(origPool, n.txPool)
+	return orig[len(orig)-1].Hash != n.blockChain[len(n.blockChain)-1].Hash
+}
+
+func (n *Node) mine() {
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-n.ticker.C():
+		}
+		if _, err := n.MineBlock(); err != nil {
+			n.miningLog.Error("mining failed", "err", err)
+		}
+	}
+}
+
+// MineBlock mines a block paying the coinbase to the node's key on top of its current chain, switches to it and announces it to the peers. The block is stamped with the network-adjusted time, or just past the chain's median time past if that is later.
+func (n *Node) MineBlock() (bb.BasicBlock, error) {
+	newBlock, bc, err := n.mineBlock([]byte{}, true)
+	if err != nil {
+		return bb.BasicBlock{}, err
+	}
+	n.send(message{Type: msgBlockChain, BlockChain: bc})
+	return newBlock, nil
+}
+
+// mineBlock mines a block holding data, and with withTxs a coinbase and the pooled transactions, on top of the current chain and switches to it. mu is only held to take the chain and to switch, not while mining. It returns the block and the chain after switching.
+func (n *Node) mineBlock(data []byte, withTxs bool) (bb.BasicBlock, bb.BlockChain, error) {
+	n.mu.Lock()
+	base := n.blockChain[:len(n.blockChain):len(n.blockChain)]
+	latestBlock := base[len(base)-1]
+	var txs []bb.Transaction
+	if withTxs {
+		pooled, fees := n.txPool.ForBlock(n.unspentTxOuts, nil)
+		coinbase, err := bb.NewCoinbaseTransactionWithFees(n.cfg.Address, base.NextHeight(), fees)
+		if err != nil {
+			n.mu.Unlock()
+			return bb.BasicBlock{}, nil, fmt.Errorf("block %d rejected: %v", base.NextHeight(), err)
+		}
+		txs = append([]bb.Transaction{coinbase}, pooled...)
+	}
+	difficulty := n.difficulty
+	n.mu.Unlock()
+
+	start := time.Now()
+	newBlock := latestBlock.FindBlockAt(data, txs, difficulty, base.NextTimestamp(n.networkTime.Now()))
+	hashes := float64(binary.LittleEndian.Uint32(newBlock.Nonce)) + 1
+	n.metrics.minerHashes.add(hashes)
+	if elapsed := time.Since(start); elapsed > 0 { // a block at difficulty 0 can be found within the clock's resolution
+		n.metrics.minerHashRate.set(hashes / elapsed.Seconds())
+	}
+	newBlockChain := append(base, newBlock)
+	if !n.validateBlockChain(newBlockChain) {
+		return bb.BasicBlock{}, nil, fmt.Errorf("mined block %d is invalid", newBlock.Index)
+	}
+	n.mu.Lock()
+	n.replaceBlockChain(newBlockChain)
+	bc := n.blockChain
+	n.mu.Unlock()
+	return newBlock, bc, nil
+}
+
+// send hands msg to the writer for all peers, unless the node is stopping.
+func (n *Node) send(msg message) {
+	select {
+	case n.outCh <- msg:
+	case <-n.done:
+	}
+}
+
+func (n *Node) updateBlockchain() {
+	for {
+		var msg message
+		select {
+		case <-n.done:
+			return
+		case msg = <-n.inCh:
+		}
+		switch msg.Type {
+		case msgBlockChain:
+			bc := msg.BlockChain
+			if !n.validateBlockChain(bc) {
+				n.p2pLog.Warn("received invalid blockchain", "length", len(bc))
+				n.misbehaving(msg.from, scoreInvalidBlockChain, "invalid blockchain")
+				continue
+			}
+			n.chainLog.Info("received blockchain", "length", len(bc))
+			n.mu.Lock()
+			switched := n.replaceBlockChain(bc)
+			bc = n.blockChain
+			n.mu.Unlock()
+			if switched { // relay, peers that already have it will not switch again
+				n.send(message{Type: msgBlockChain, BlockChain: bc})
+			}
+		case msgTransaction:
+			if _, err := n.AddTransaction(msg.Transaction); err != nil {
+				n.mempoolLog.Debug("received transaction not added to pool", "err", err)
+			}
+		}
+	}
+}
+
+// maxReplaced bounds how many replaced transactions a node remembers for GET /tx/{id}.
+const maxReplaced = 1000
+
+// AddTransaction validates tx against the current unspent outputs and the pool, pools it and relays it to our peers. It returns the pooled transactions that tx replaced.
+func (n *Node) AddTransaction(tx bb.Transaction) ([]bb.Replacement, error) {
+	n.mu.Lock()
+	var replacements []bb.Replacement
+	var err error
+	n.txPool, replacements, err = n.txPool.Add(tx, n.unspentTxOuts, n.blockChain.NextContext())
+	if err == nil {
+		for _, r := range replacements {
+			if len(n.replaced) >= maxReplaced {
+				for id := range n.replaced { // forget an arbitrary one
+					delete(n.replaced, id)
+					break
+				}
+			}
+			n.replaced[r.Transaction.ID()] = r
+			n.events.publish(event{Type: eventMempoolRemoved, Transaction: &txEvent{Transaction: r.Transaction, Reason: "replaced-" + r.Reason, ReplacedBy: hex.EncodeToString(r.By[:])}})
+		}
+		n.events.publish(event{Type: eventMempoolAdded, Transaction: &txEvent{Transaction: tx}})
+	}
+	n.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	id := tx.ID()
+	n.mempoolLog.Info("added tx to pool", "tx", fmt.Sprintf("%x", id), "replaced", len(replacements))
+	n.send(message{Type: msgTransaction, Transaction: tx})
+	return replacements, nil
+}
+
+func (n *Node) displayBlockchain(w http.ResponseWriter, r *http.Request) {
+	for _, blk := range n.BlockChain() {
+		fmt.Fprint(w, blk.String()+"\n")
+	}
+}
+
+func (n *Node) parsePost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		fmt.Fprint(w, "please make a POST request.")
+	}
+	r.ParseForm()
+	for k, v := range r.PostForm {
+		fmt.Fprintf(w, "key is %s, val is %s \n", k, v)
+
+		if k == "data" {
+			if len(v[0]) > bb.MaxBlockDataSize {
+				fmt.Fprintf(w, "data is longer than %d bytes\n", bb.MaxBlockDataSize)
+				continue
+			}
+			if _, _, err := n.mineBlock([]byte(v[0]), false); err != nil {
+				fmt.Fprintf(w, "could not mine a block: %v\n", err)
+			}
+		}
+
+		if k == "addpeer" {
+			// We use this to manually add websocket peers, as there is no peer discovery mechanism.
+			u := url.URL{Scheme: "ws", Host: v[0], Path: "/ws"}
+			if err := n.Connect(u.String()); err != nil {
+				fmt.Fprintf(w, "could not connect to %s: %v\n", v[0], err)
+			}
+		}
+	}
+}
+
+// logLevelsHandler shows the log levels on GET and changes them on POST, e.g. with "levels=p2p=debug".
+func (n *Node) logLevelsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		if err := logging.SetLevels(r.FormValue("levels")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n.apiLog.Info("log levels changed", "levels", logging.Levels())
+	}
+	fmt.Fprintln(w, logging.Levels())
+}
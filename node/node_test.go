@@ -0,0 +1,114 @@
+package node
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+	"github.com/chronologos/naivecoin/logging"
+	"github.com/chronologos/naivecoin/wallet"
+)
+
+func TestMineOnChainAheadOfClock(t *testing.T) {
+	logging.SetOutput(io.Discard, false)
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// A peer whose clock runs 50s ahead of ours, within MaxFutureDrift, pushed the median time past beyond our clock.
+	bc := bb.BlockChain{bb.GenesisBlock}
+	for i := 1; i <= 5; i++ {
+		bc = append(bc, bc[i-1].FindBlockAt(nil, nil, 0, now.Add(time.Duration(10*i)*time.Second)))
+	}
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	n := New(Config{Clock: bb.NewManualClock(now), Address: key.PublicKey})
+	n.Start()
+	t.Cleanup(n.Stop)
+	n.mu.Lock()
+	switched := n.replaceBlockChain(bc)
+	n.mu.Unlock()
+	if !switched {
+		t.Fatalf("node did not take the chain ahead of its clock")
+	}
+
+	blk, err := n.MineBlock()
+	if err != nil {
+		t.Fatalf("MineBlock: %v", err)
+	}
+	if mtp := bc.Headers().MedianTimePast(); !blk.Timestamp.After(mtp) {
+		t.Errorf("mined block stamped %v, not after the median time past %v", blk.Timestamp, mtp)
+	}
+	if tip := n.BlockChain(); tip[len(tip)-1].Hash != blk.Hash {
+		t.Errorf("node did not switch to its mined block")
+	}
+}
+
+func TestPostDataKeepsCallersChain(t *testing.T) {
+	logging.SetOutput(io.Discard, false)
+	n := New(Config{})
+	n.Start()
+	t.Cleanup(n.Stop)
+	// A chain with room to grow, as append leaves it, which callers of BlockChain share.
+	bc := make(bb.BlockChain, 2, 3)
+	bc[0] = bb.GenesisBlock
+	bc[1] = bc[0].FindBlockAt([]byte("a"), nil, 0, bc.NextTimestamp(bb.LocalClock.Now()))
+	n.mu.Lock()
+	n.replaceBlockChain(bc)
+	n.mu.Unlock()
+	mine := append(n.BlockChain(), bc[1].FindBlockAt([]byte("mine"), nil, 0, bc.NextTimestamp(bb.LocalClock.Now())))
+
+	req := httptest.NewRequest(http.MethodPost, "/p", strings.NewReader("data=posted"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	n.Handler().ServeHTTP(rec, req)
+	if tip := n.BlockChain(); len(tip) != 3 || string(tip[2].Data) != "posted" {
+		t.Fatalf("posting data did not mine it into a block: %s", rec.Body)
+	}
+	if string(mine[2].Data) != "mine" {
+		t.Errorf("posting data overwrote a block appended to the chain BlockChain returned")
+	}
+}
+
+func TestAdminOnlyOnAdminHandler(t *testing.T) {
+	logging.SetOutput(io.Discard, false)
+	hd, err := wallet.NewHDWallet([]byte("wallet handler test seed, 32 b.."), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := New(Config{Wallet: hd})
+	for _, tt := range []struct{ method, path string }{{http.MethodGet, "/wallet"}, {http.MethodPost, "/wallet/unlock"}, {http.MethodPost, "/wallet/lock"}, {http.MethodPost, "/wallet/send"}, {http.MethodGet, "/log"}, {http.MethodPost, "/log"}} {
+		rec := httptest.NewRecorder()
+		n.Handler().ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s on the public handler answered %d, want 404", tt.method, tt.path, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	n.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wallet", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /wallet on the admin handler answered %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestMineToEd25519Key(t *testing.T) {
+	logging.SetOutput(io.Discard, false)
+	key, err := bb.Ed25519.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := New(Config{Address: key.Public()})
+	n.Start()
+	t.Cleanup(n.Stop)
+	blk, err := n.MineBlock()
+	if err != nil {
+		t.Fatalf("MineBlock: %v", err)
+	}
+	if a, ok := blk.Transactions[0].TxOuts()[0].LockingScript().Address(); !ok || a != bb.NewAddress(key.Public()) || a.Scheme != bb.Ed25519 {
+		t.Errorf("coinbase pays to %v, %t, want the Ed25519 key's address", a, ok)
+	}
+}
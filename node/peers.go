@@ -0,0 +1,256 @@
+package node
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Peers talk to each other over websockets. A peer that sends oversized or undecodable messages, sends too many of them, or relays invalid chains earns ban score. Once its score reaches the threshold it is disconnected and its host is banned for a while.
+
+// PeerLimits bound what a single peer can make a node do.
+type PeerLimits struct {
+	MaxMessageSize int64         // bytes, a larger websocket message gets the peer banned
+	MessageRate    float64       // messages per second a peer may send on average
+	MessageBurst   int           // messages a peer may send at once
+	BanThreshold   int           // ban score at which a peer gets banned
+	BanDuration    time.Duration // how long a banned host may not connect
+}
+
+// DefaultPeerLimits are used for the fields a Config leaves zero. Chains are sent whole, so MaxMessageSize also bounds the length of chain that can be relayed.
+var DefaultPeerLimits = PeerLimits{
+	MaxMessageSize: 32 << 20,
+	MessageRate:    10,
+	MessageBurst:   50,
+	BanThreshold:   100,
+	BanDuration:    24 * time.Hour,
+}
+
+func (l PeerLimits) withDefaults() PeerLimits {
+	if l.MaxMessageSize == 0 {
+		l.MaxMessageSize = DefaultPeerLimits.MaxMessageSize
+	}
+	if l.MessageRate == 0 {
+		l.MessageRate = DefaultPeerLimits.MessageRate
+	}
+	if l.MessageBurst == 0 {
+		l.MessageBurst = DefaultPeerLimits.MessageBurst
+	}
+	if l.BanThreshold == 0 {
+		l.BanThreshold = DefaultPeerLimits.BanThreshold
+	}
+	if l.BanDuration == 0 {
+		l.BanDuration = DefaultPeerLimits.BanDuration
+	}
+	return l
+}
+
+// Ban score for misbehaviour that may be an honest mistake. Malformed and oversized messages are not, they get the peer banned right away.
+const (
+	scoreRateLimited       = 1 // per message over the rate
+	scoreInvalidBlockChain = 10
+)
+
+type peer struct {
+	conn     *websocket.Conn
+	addr     string
+	host     string
+	tokens   float64   // rate limiter state, only touched by the peer's wsReader
+	last     time.Time // when tokens was last refilled
+	greeted  bool      // whether its handshake arrived, only touched by the peer's wsReader
+	banScore int       // guarded by the node's peersMu
+}
+
+// allow takes a token from the peer's bucket, which refills at limits.MessageRate up to limits.MessageBurst.
+func (p *peer) allow(now time.Time, limits PeerLimits) bool {
+	p.tokens = min(float64(limits.MessageBurst), p.tokens+now.Sub(p.last).Seconds()*limits.MessageRate)
+	p.last = now
+	if p.tokens < 1 {
+		return false
+	}
+	p.tokens--
+	return true
+}
+
+// misbehaving adds score to the peer's ban score, and bans it once that reaches the threshold. p may be nil for messages that did not come from a peer.
+func (n *Node) misbehaving(p *peer, score int, reason string) {
+	if p == nil {
+		return
+	}
+	n.metrics.misbehavior.add(reason, 1)
+	n.peersMu.Lock()
+	before := p.banScore
+	p.banScore += score
+	total := p.banScore
+	banned := before < n.cfg.BanThreshold && total >= n.cfg.BanThreshold
+	if banned {
+		n.banned[p.host] = n.clock.Now().Add(n.cfg.BanDuration)
+	}
+	n.peersMu.Unlock()
+	n.p2pLog.Debug("peer misbehaving", "peer", p.addr, "reason", reason, "banScore", total)
+	if banned {
+		n.metrics.bans.add(1)
+		n.p2pLog.Warn("banning peer", "peer", p.addr, "reason", reason, "until", n.clock.Now().Add(n.cfg.BanDuration))
+		p.conn.Close()
+	}
+}
+
+// isBanned reports whether host may not connect. peersMu must be held.
+func (n *Node) isBanned(host string) bool {
+	until, ok := n.banned[host]
+	if ok && !n.clock.Now().Before(until) {
+		delete(n.banned, host)
+		return false
+	}
+	return ok
+}
+
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+func (n *Node) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	n.peersMu.Lock()
+	banned := n.isBanned(hostOf(r.RemoteAddr))
+	n.peersMu.Unlock()
+	if banned {
+		http.Error(w, "banned", http.StatusForbidden)
+		return
+	}
+	wsconn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		n.p2pLog.Warn("websocket upgrade failed", "err", err)
+		return
+	}
+	if err := n.addPeer(wsconn); err != nil {
+		n.p2pLog.Info("refused peer", "peer", wsconn.RemoteAddr().String(), "err", err)
+		return
+	}
+	n.p2pLog.Info("⧉ connection from peer", "peer", wsconn.RemoteAddr().String())
+}
+
+// Connect dials the peer websocket at rawURL, e.g. ws://localhost:8000/ws.
+func (n *Node) Connect(rawURL string) error {
+	n.p2pLog.Info("⧉ connecting to peer", "url", rawURL)
+	wsconn, _, err := websocket.DefaultDialer.Dial(rawURL, nil)
+	if err != nil {
+		n.p2pLog.Warn("dial failed", "url", rawURL, "err", err)
+		return err
+	}
+	return n.addPeer(wsconn)
+}
+
+// addPeer sends our side of the handshake on a new connection, then starts relaying messages over it. The peer's handshake tells us its clock, which feeds the network-adjusted time.
+func (n *Node) addPeer(wsconn *websocket.Conn) error {
+	p := &peer{conn: wsconn, addr: wsconn.RemoteAddr().String(), host: hostOf(wsconn.RemoteAddr().String()), tokens: float64(n.cfg.MessageBurst), last: n.clock.Now()}
+	wsconn.SetReadLimit(n.cfg.MaxMessageSize)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(message{Type: msgVersion, Time: n.clock.Now()}); err != nil {
+		fatal(n.p2pLog, "encode failed", "err", err)
+	}
+	n.peersMu.Lock()
+	if n.isBanned(p.host) {
+		n.peersMu.Unlock()
+		wsconn.Close()
+		return fmt.Errorf("%s is banned", p.host)
+	}
+	wsconn.WriteMessage(websocket.BinaryMessage, buf.Bytes())
+	n.metrics.messagesOut.add(msgVersion.String(), 1)
+	n.peers = append(n.peers, p)
+	n.peersMu.Unlock()
+	go n.wsReader(p)
+	return nil
+}
+
+func (n *Node) removePeer(p *peer) {
+	p.conn.Close()
+	n.peersMu.Lock()
+	defer n.peersMu.Unlock()
+	for i := range n.peers {
+		if n.peers[i] == p {
+			n.peers = append(n.peers[:i:i], n.peers[i+1:]...)
+			break
+		}
+	}
+	// The clock sample belongs to the host, and stays while another connection from it does.
+	if !slices.ContainsFunc(n.peers, func(q *peer) bool { return q.host == p.host }) {
+		n.networkTime.RemoveSample(p.host)
+	}
+}
+
+// wsReader passes the messages from a peer on to updateBlockchain until the connection fails, then drops the peer.
+func (n *Node) wsReader(p *peer) {
+	defer n.removePeer(p)
+	for {
+		_, b, err := p.conn.ReadMessage()
+		if errors.Is(err, websocket.ErrReadLimit) {
+			n.misbehaving(p, n.cfg.BanThreshold, "oversized message")
+			return
+		}
+		if err != nil {
+			n.p2pLog.Info("peer disconnected", "peer", p.addr, "err", err)
+			return
+		}
+		if !p.allow(n.clock.Now(), n.cfg.PeerLimits) {
+			n.misbehaving(p, scoreRateLimited, "rate limited")
+			continue
+		}
+
+		var msg message
+		if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&msg); err != nil {
+			n.misbehaving(p, n.cfg.BanThreshold, "malformed message")
+			return
+		}
+		msg.from = p
+
+		n.p2pLog.Debug("received message", "peer", p.addr, "type", msg.Type.String(), "length", len(msg.BlockChain))
+		n.metrics.messagesIn.add(msg.Type.String(), 1)
+		if msg.Type == msgVersion {
+			// Only the handshake reports the peer's clock.
+			if !p.greeted {
+				p.greeted = true
+				n.networkTime.AddSample(p.host, msg.Time)
+			}
+			continue
+		}
+		select {
+		case n.inCh <- msg:
+		case <-n.done:
+			return
+		}
+	}
+}
+
+func (n *Node) wsWriter() {
+	for {
+		var msg message
+		select {
+		case <-n.done:
+			return
+		case msg = <-n.outCh:
+		}
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(msg); err != nil {
+			fatal(n.p2pLog, "encode failed", "err", err)
+		}
+
+		n.peersMu.Lock()
+		for _, p := range n.peers {
+			p.conn.WriteMessage(websocket.BinaryMessage, buf.Bytes())
+			n.metrics.messagesOut.add(msg.Type.String(), 1)
+			n.p2pLog.Debug("sent message", "peer", p.addr, "type", msg.Type.String())
+		}
+		n.peersMu.Unlock()
+	}
+}
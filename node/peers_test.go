@@ -0,0 +1,121 @@
+package node
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+	"github.com/chronologos/naivecoin/logging"
+	"github.com/gorilla/websocket"
+)
+
+// startNode serves a node with a clock that stands still, so rate limits never refill during a test.
+func startNode(t *testing.T, limits PeerLimits) (*Node, string) {
+	t.Helper()
+	logging.SetOutput(io.Discard, false)
+	n := New(Config{Clock: bb.NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)), PeerLimits: limits})
+	n.Start()
+	s := httptest.NewServer(n.Handler())
+	t.Cleanup(func() {
+		n.Stop()
+		s.CloseClientConnections()
+		s.Close()
+	})
+	return n, "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"
+}
+
+func encode(t *testing.T, msg message) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(msg); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// waitClosed reads until the node hangs up on us.
+func waitClosed(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if ne, ok := err.(interface{ Timeout() bool }); ok && ne.Timeout() {
+				t.Fatalf("node did not disconnect the peer")
+			}
+			return
+		}
+	}
+}
+
+func TestPeerBans(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		limits   PeerLimits
+		messages func(t *testing.T) [][]byte
+	}{
+		{"malformed", PeerLimits{}, func(t *testing.T) [][]byte { return [][]byte{[]byte("not gob")} }},
+		{"oversized", PeerLimits{MaxMessageSize: 1024}, func(t *testing.T) [][]byte { return [][]byte{make([]byte, 2048)} }},
+		{"rate", PeerLimits{MessageBurst: 5, BanThreshold: 3}, func(t *testing.T) [][]byte {
+			var res [][]byte
+			for i := 0; i < 8; i++ {
+				res = append(res, encode(t, message{Type: msgVersion, Time: time.Now()}))
+			}
+			return res
+		}},
+		{"invalid blockchain", PeerLimits{BanThreshold: scoreInvalidBlockChain}, func(t *testing.T) [][]byte {
+			return [][]byte{encode(t, message{Type: msgBlockChain, BlockChain: bb.BlockChain{{Data: []byte("not the genesis block")}}})}
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			n, url := startNode(t, tt.limits)
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			for _, b := range tt.messages(t) {
+				if err := conn.WriteMessage(websocket.BinaryMessage, b); err != nil {
+					break
+				}
+			}
+			waitClosed(t, conn)
+			if n.metrics.bans.get() != 1 {
+				t.Errorf("%g bans, want 1", n.metrics.bans.get())
+			}
+			_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+			if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+				t.Errorf("banned peer could reconnect: %v", err)
+			}
+		})
+	}
+}
+
+func TestPeerWithinLimitsIsNotBanned(t *testing.T) {
+	n, url := startNode(t, PeerLimits{MessageBurst: 5, BanThreshold: 3})
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for i := 0; i < 5; i++ {
+		if err := conn.WriteMessage(websocket.BinaryMessage, encode(t, message{Type: msgVersion, Time: time.Now()})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for n.metrics.messagesIn.get(msgVersion.String()) < 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("node did not receive the messages")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n.metrics.bans.get() != 0 {
+		t.Errorf("peer within the limits was banned")
+	}
+}
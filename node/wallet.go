@@ -0,0 +1,154 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+	"github.com/chronologos/naivecoin/wallet"
+)
+
+// The handlers in this file serve the node's own wallet, Config.Wallet, through AdminHandler. An encrypted wallet is locked until POST /wallet/unlock, and refuses to sign while locked.
+
+// DefaultUnlockTimeout is how long POST /wallet/unlock unlocks the wallet for if the request does not say.
+const DefaultUnlockTimeout = 5 * time.Minute
+
+// nodeWallet returns the node's wallet, or answers 404 if it has none.
+func (n *Node) nodeWallet(w http.ResponseWriter) *wallet.HDWallet {
+	if n.cfg.Wallet == nil {
+		http.Error(w, "this node has no wallet", http.StatusNotFound)
+	}
+	return n.cfg.Wallet
+}
+
+// getWallet reports whether the wallet is locked and its balance.
+func (n *Node) getWallet(w http.ResponseWriter, r *http.Request) {
+	hd := n.nodeWallet(w)
+	if hd == nil {
+		return
+	}
+	n.mu.Lock()
+	utxos := n.unspentTxOuts
+	n.mu.Unlock()
+	n.walletMu.Lock()
+	balance, err := hd.Balance(utxos)
+	n.walletMu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	locked, until := hd.Locked()
+	res := wallet.WalletStatus{Encrypted: hd.Encrypted(), Locked: locked, Balance: balance}
+	if !locked && !until.IsZero() {
+		res.UnlockedUntil = &until
+	}
+	writeJSON(w, res)
+}
+
+// unlockWallet unlocks the wallet with the password form value for the timeout form value, a duration like 30s, DefaultUnlockTimeout if missing and without limit if 0.
+func (n *Node) unlockWallet(w http.ResponseWriter, r *http.Request) {
+	hd := n.nodeWallet(w)
+	if hd == nil {
+		return
+	}
+	timeout := DefaultUnlockTimeout
+	if s := r.FormValue("timeout"); s != "" {
+		var err error
+		if timeout, err = time.ParseDuration(s); err != nil || timeout < 0 {
+			http.Error(w, "timeout must be a duration like 5m", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := hd.Unlock(r.FormValue("password"), timeout); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, wallet.ErrWrongPassword) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	n.apiLog.Info("wallet unlocked", "timeout", timeout)
+	n.getWallet(w, r)
+}
+
+// lockWallet locks the wallet before its unlock timeout.
+func (n *Node) lockWallet(w http.ResponseWriter, r *http.Request) {
+	hd := n.nodeWallet(w)
+	if hd == nil {
+		return
+	}
+	hd.Lock()
+	n.apiLog.Info("wallet locked")
+	n.getWallet(w, r)
+}
+
+// sendFromWallet pays the amount form value to the to form value, an address or hex locking script, from the wallet, at the feerate form value or the estimated fee rate. It spends only outputs in blocks that no pooled transaction spends yet.
+func (n *Node) sendFromWallet(w http.ResponseWriter, r *http.Request) {
+	hd := n.nodeWallet(w)
+	if hd == nil {
+		return
+	}
+	receiver, ok := parseLock(r.FormValue("to"))
+	if !ok {
+		http.Error(w, "to must be an address or a hex locking script", http.StatusBadRequest)
+		return
+	}
+	amount, err := strconv.ParseInt(r.FormValue("amount"), 10, 32)
+	if err != nil {
+		http.Error(w, "amount must be a number of coins", http.StatusBadRequest)
+		return
+	}
+	feeRate := n.estimateFeeRate()
+	if s := r.FormValue("feerate"); s != "" {
+		if feeRate, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, "feerate must be a number of coins per 1000 bytes", http.StatusBadRequest)
+			return
+		}
+	}
+
+	n.mu.Lock()
+	nextHeight := n.blockChain.NextHeight()
+	type outPoint struct {
+		id    [32]byte
+		index int32
+	}
+	spent := make(map[outPoint]bool)
+	for _, tx := range n.txPool {
+		for _, txIn := range tx.TxIns() {
+			spent[outPoint{txIn.TxOutID(), txIn.TxOutIndex()}] = true
+		}
+	}
+	var utxos []bb.UnspentTxOut
+	for _, utxo := range n.unspentTxOuts {
+		if utxo.IsMature(nextHeight) && !spent[outPoint{utxo.TxOutID(), utxo.TxOutIndex()}] {
+			utxos = append(utxos, utxo)
+		}
+	}
+	n.mu.Unlock()
+
+	n.walletMu.Lock()
+	defer n.walletMu.Unlock()
+	tx, err := hd.CreateTransaction(receiver, int32(amount), feeRate, utxos)
+	if errors.Is(err, wallet.ErrLocked) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := n.AddTransaction(tx); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if n.cfg.WalletPath != "" {
+		if err := hd.Save(n.cfg.WalletPath); err != nil {
+			n.apiLog.Error("saving the wallet failed", "path", n.cfg.WalletPath, "err", err)
+		}
+	}
+	id := tx.ID()
+	writeJSON(w, wallet.SendResult{ID: fmt.Sprintf("%x", id), Replaced: []wallet.ReplacedTx{}})
+}
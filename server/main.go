@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+	"github.com/chronologos/naivecoin/logging"
+	"github.com/chronologos/naivecoin/node"
+	"github.com/chronologos/naivecoin/wallet"
+)
+
+var ip = flag.String("ip", "80", "ip address for this server")
+var logLevels = flag.String("log", "info", "log levels, e.g. \"info,p2p=debug\". Can be changed at runtime through /log on -adminip.")
+var logJSON = flag.Bool("logjson", false, "log as JSON instead of text.")
+var mines = flag.Bool("mines", false, "True if this servdr actually mines blocks.")
+var maxDrift = flag.Duration("maxdrift", bb.MaxFutureDrift, "how far ahead of the network-adjusted time a block's timestamp may be.")
+var minRelayFee = flag.Int64("minrelayfee", bb.MinRelayFeeRate, "lowest fee, in coins per 1000 bytes, for a transaction to enter the pool.")
+var walletPath = flag.String("wallet", wallet.DefaultWalletPath, "wallet whose next receive address gets the coinbase of mined blocks, or whose payments to it a light node verifies. It is served under /wallet on -adminip, locked if encrypted.")
+var adminIP = flag.String("adminip", "", "port on 127.0.0.1 to serve the -wallet under /wallet and the log levels under /log on, which anyone who can reach it may use. Not served if empty.")
+var coinbaseKey = flag.String("coinbasekey", "", "hex public key of any signature scheme, as printed by naivecoin wallet keygen, to pay the coinbase of mined blocks to instead of -wallet, whose keys are all ECDSA.")
+var light = flag.Bool("light", false, "run as a light client: only download and validate headers, verify payments to -wallet with Merkle proofs from -fullnodes.")
+var testnet = flag.Bool("testnet", false, "use the addresses of test networks, see bb.TestParams.")
+var txIndex = flag.Bool("txindex", false, "index confirmed transactions by id, so /tx/{id} finds them without scanning the chain. Ignored with -light.")
+var fullNodes = flag.String("fullnodes", "localhost:8000", "comma separated full nodes a light client gets headers and proofs from.")
+
+var apiLog = logging.Logger(logging.API)
+
+// fatal logs at error level and exits.
+func fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+func main() {
+	flag.Parse()
+	logging.SetOutput(os.Stderr, *logJSON)
+	if err := logging.SetLevels(*logLevels); err != nil {
+		fatal(apiLog, "invalid -log flag", "err", err)
+	}
+	bb.MaxFutureDrift = *maxDrift
+	bb.MaxTimeAdjustment = *maxDrift / 2
+	bb.MinRelayFeeRate = *minRelayFee
+	if *testnet {
+		bb.Params = bb.TestParams
+	}
+
+	cfg := node.Config{Mines: *mines, Light: *light, TxIndex: *txIndex, FullNodes: strings.Split(*fullNodes, ",")}
+	if *mines || *light {
+		w, err := wallet.LoadOrCreateHDWallet(*walletPath)
+		if err == nil {
+			cfg.Address, err = w.NextPublicKey(wallet.ReceiveChain)
+		}
+		if err == nil {
+			err = w.Save(*walletPath)
+		}
+		if err != nil {
+			fatal(apiLog, "could not load wallet", "path", *walletPath, "err", err)
+		}
+		cfg.Wallet, cfg.WalletPath = w, *walletPath
+	}
+	if *coinbaseKey != "" {
+		pub, err := bb.DecodePublicKey(*coinbaseKey)
+		if err != nil {
+			fatal(apiLog, "invalid -coinbasekey flag", "err", err)
+		}
+		cfg.Address = pub
+	}
+	n := node.New(cfg)
+	n.Start()
+	defer n.Stop()
+
+	if *adminIP != "" {
+		go func() {
+			fatal(apiLog, "admin server stopped", "err", http.ListenAndServe("127.0.0.1:"+*adminIP, n.AdminHandler()))
+		}()
+	}
+	apiLog.Info("🖥 server initialized", "port", *ip, "adminport", *adminIP, "mode", n.Mode())
+	fatal(apiLog, "server stopped", "err", http.ListenAndServe("localhost:"+*ip, n.Handler()))
+}
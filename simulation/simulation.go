@@ -0,0 +1,320 @@
+// Package simulation runs a network of nodes in one process for integration tests. Every node is served by an httptest server, and every connection between two nodes goes through a relay run by the simulation, which can delay messages and partition the network. Nodes do not share state: each has its own clock and network-adjusted time, and its own loopback address, 127.0.0.2 and up, that its server and relay listen on and that its peers see it connect from, so a ban only hits the node that earned it. Where the loopback network is only 127.0.0.1, as on macOS, all nodes use that.
+package simulation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	gonet "net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+	"github.com/chronologos/naivecoin/node"
+	"github.com/gorilla/websocket"
+)
+
+// Network is a set of nodes and the links between them.
+type Network struct {
+	Nodes   []*node.Node
+	servers []*httptest.Server
+	relays  []*httptest.Server // relays[j] takes the links to node j
+
+	mu      sync.Mutex // guards latency and group
+	latency map[[2]int]time.Duration
+	group   []int // partition each node is in, nodes in different partitions can not reach each other
+}
+
+// Topology lists the pairs of nodes to connect in a network of n nodes.
+type Topology func(n int) [][2]int
+
+// Line connects every node to the next one.
+func Line(n int) [][2]int {
+	var res [][2]int
+	for i := 0; i+1 < n; i++ {
+		res = append(res, [2]int{i, i + 1})
+	}
+	return res
+}
+
+// Ring is a Line whose ends are connected too.
+func Ring(n int) [][2]int {
+	res := Line(n)
+	if n > 2 {
+		res = append(res, [2]int{n - 1, 0})
+	}
+	return res
+}
+
+// Star connects every node to node 0.
+func Star(n int) [][2]int {
+	var res [][2]int
+	for i := 1; i < n; i++ {
+		res = append(res, [2]int{0, i})
+	}
+	return res
+}
+
+// FullMesh connects every pair of nodes.
+func FullMesh(n int) [][2]int {
+	var res [][2]int
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			res = append(res, [2]int{i, j})
+		}
+	}
+	return res
+}
+
+// Options configure the nodes of a Network.
+type Options struct {
+	Clocks     []bb.Clock      // clock of each node, bb.LocalClock for nodes without one
+	PeerLimits node.PeerLimits // of every node, zero fields take their node.DefaultPeerLimits value
+	TxIndex    bool            // whether the nodes keep a transaction index, see node.Config.TxIndex
+}
+
+// maxNodes is how many nodes get a loopback address of their own.
+const maxNodes = 253
+
+// New starts n unconnected nodes, each with its own coinbase key. They do not mine on their own, tests call Mine.
+func New(n int) (*Network, error) {
+	return NewWithOptions(n, Options{})
+}
+
+// NewWithOptions is New with opts.
+func NewWithOptions(n int, opts Options) (*Network, error) {
+	if n > maxNodes {
+		return nil, fmt.Errorf("at most %d nodes, not %d", maxNodes, n)
+	}
+	net := &Network{latency: make(map[[2]int]time.Duration), group: make([]int, n)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/link/{from}/{to}", net.relayLink)
+	for i := 0; i < n; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			net.Close()
+			return nil, err
+		}
+		cfg := node.Config{Name: strconv.Itoa(i), Address: key.PublicKey, TxIndex: opts.TxIndex, PeerLimits: opts.PeerLimits}
+		if i < len(opts.Clocks) {
+			cfg.Clock = opts.Clocks[i]
+		}
+		nd := node.New(cfg)
+		nd.Start()
+		net.Nodes = append(net.Nodes, nd)
+		net.servers = append(net.servers, serve(i, nd.Handler()))
+		net.relays = append(net.relays, serve(i, mux))
+	}
+	return net, nil
+}
+
+// multipleLoopbacks reports whether addresses of 127.0.0.0/8 other than 127.0.0.1 can be listened on.
+var multipleLoopbacks = sync.OnceValue(func() bool {
+	l, err := gonet.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+})
+
+// loopback is the address of node i.
+func loopback(i int) gonet.IP {
+	if !multipleLoopbacks() {
+		return gonet.IPv4(127, 0, 0, 1)
+	}
+	return gonet.IPv4(127, 0, 0, byte(i+2))
+}
+
+// serve starts an httptest server for h on the address of node i.
+func serve(i int, h http.Handler) *httptest.Server {
+	s := httptest.NewUnstartedServer(h)
+	l, err := gonet.Listen("tcp", gonet.JoinHostPort(loopback(i).String(), "0"))
+	if err == nil {
+		s.Listener.Close()
+		s.Listener = l
+	}
+	s.Start()
+	return s
+}
+
+// dialerFrom dials websockets from the address of node i.
+func dialerFrom(i int) *websocket.Dialer {
+	d := &gonet.Dialer{LocalAddr: &gonet.TCPAddr{IP: loopback(i)}}
+	return &websocket.Dialer{NetDialContext: d.DialContext, HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout}
+}
+
+// Close stops all nodes and servers.
+func (net *Network) Close() {
+	for _, nd := range net.Nodes {
+		nd.Stop()
+	}
+	for _, servers := range [][]*httptest.Server{net.relays, net.servers} {
+		for _, s := range servers {
+			s.CloseClientConnections()
+			s.Close()
+		}
+	}
+}
+
+// Connect links nodes i and j, i dials j through the relay.
+func (net *Network) Connect(i, j int) error {
+	if i < 0 || j < 0 || i >= len(net.Nodes) || j >= len(net.Nodes) || i == j {
+		return fmt.Errorf("can not connect node %d to node %d in a network of %d", i, j, len(net.Nodes))
+	}
+	return net.Nodes[i].Connect(fmt.Sprintf("ws%s/link/%d/%d", strings.TrimPrefix(net.relays[j].URL, "http"), i, j))
+}
+
+// Apply connects the pairs of nodes in topology.
+func (net *Network) Apply(topology Topology) error {
+	for _, pair := range topology(len(net.Nodes)) {
+		if err := net.Connect(pair[0], pair[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetLatency delays the messages between nodes i and j, in both directions, by d.
+func (net *Network) SetLatency(i, j int, d time.Duration) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	net.latency[pairKey(i, j)] = d
+}
+
+// Partition splits the network into groups, messages between nodes in different groups are dropped. Nodes not in any group end up together in a group of their own.
+func (net *Network) Partition(groups ...[]int) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	for i := range net.group {
+		net.group[i] = 0
+	}
+	for g, nodes := range groups {
+		for _, i := range nodes {
+			net.group[i] = g + 1
+		}
+	}
+}
+
+// Heal undoes Partition. Messages dropped in the meantime stay lost.
+func (net *Network) Heal() {
+	net.Partition()
+}
+
+// Mine has node i mine a block and announce it.
+func (net *Network) Mine(i int) (bb.BasicBlock, error) {
+	return net.Nodes[i].MineBlock()
+}
+
+// Tip returns the last block of node i's chain.
+func (net *Network) Tip(i int) bb.BasicBlock {
+	bc := net.Nodes[i].BlockChain()
+	return bc[len(bc)-1]
+}
+
+// WaitForConvergence waits until the given nodes, or all of them if none are given, have the same tip. It returns that tip.
+func (net *Network) WaitForConvergence(timeout time.Duration, nodes ...int) (bb.BasicBlock, error) {
+	if len(nodes) == 0 {
+		for i := range net.Nodes {
+			nodes = append(nodes, i)
+		}
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		tip := net.Tip(nodes[0])
+		converged := true
+		for _, i := range nodes[1:] {
+			if net.Tip(i).Hash != tip.Hash {
+				converged = false
+				break
+			}
+		}
+		if converged {
+			return tip, nil
+		}
+		if time.Now().After(deadline) {
+			var tips []string
+			for _, i := range nodes {
+				t := net.Tip(i)
+				tips = append(tips, fmt.Sprintf("node %d at index %d (%x)", i, t.Index, t.Hash[:4]))
+			}
+			return bb.BasicBlock{}, fmt.Errorf("no convergence after %v: %s", timeout, strings.Join(tips, ", "))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func pairKey(i, j int) [2]int {
+	if i > j {
+		i, j = j, i
+	}
+	return [2]int{i, j}
+}
+
+func (net *Network) link(from, to int) (latency time.Duration, connected bool) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	return net.latency[pairKey(from, to)], net.group[from] == net.group[to]
+}
+
+var upgrader = websocket.Upgrader{}
+
+// relayLink accepts the websocket of node from, dials node to from the address of node from and passes messages between them.
+func (net *Network) relayLink(w http.ResponseWriter, r *http.Request) {
+	from, err1 := strconv.Atoi(r.PathValue("from"))
+	to, err2 := strconv.Atoi(r.PathValue("to"))
+	if err1 != nil || err2 != nil || from < 0 || to < 0 || from >= len(net.Nodes) || to >= len(net.Nodes) {
+		http.Error(w, "no such link", http.StatusNotFound)
+		return
+	}
+	dst, _, err := dialerFrom(from).Dial("ws"+strings.TrimPrefix(net.servers[to].URL, "http")+"/ws", nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	src, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		dst.Close()
+		return
+	}
+	go net.pump(src, dst, from, to)
+	go net.pump(dst, src, to, from)
+}
+
+type delivery struct {
+	at      time.Time
+	kind    int
+	payload []byte
+}
+
+// pump forwards messages read from src to dst, each after the link's latency, dropping those that are due while the nodes are partitioned. Both connections are closed when either fails.
+func (net *Network) pump(src, dst *websocket.Conn, from, to int) {
+	queue := make(chan delivery, 1024)
+	go func() {
+		defer dst.Close()
+		for d := range queue {
+			time.Sleep(time.Until(d.at))
+			if _, connected := net.link(from, to); !connected {
+				continue
+			}
+			if err := dst.WriteMessage(d.kind, d.payload); err != nil {
+				return
+			}
+		}
+	}()
+	defer close(queue)
+	defer src.Close()
+	for {
+		kind, p, err := src.ReadMessage()
+		if err != nil {
+			return
+		}
+		latency, _ := net.link(from, to)
+		queue <- delivery{time.Now().Add(latency), kind, p}
+	}
+}
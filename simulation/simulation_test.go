@@ -0,0 +1,191 @@
+package simulation
+
+import (
+	"encoding/hex"
+	"io"
+	"testing"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+	"github.com/chronologos/naivecoin/logging"
+	"github.com/chronologos/naivecoin/node"
+	"github.com/chronologos/naivecoin/wallet"
+)
+
+const convergenceTimeout = 10 * time.Second
+
+func newNetwork(t *testing.T, n int, topology Topology) *Network {
+	t.Helper()
+	return newNetworkWithOptions(t, n, topology, Options{})
+}
+
+func newNetworkWithOptions(t *testing.T, n int, topology Topology, opts Options) *Network {
+	t.Helper()
+	logging.SetOutput(io.Discard, false)
+	net, err := NewWithOptions(n, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(net.Close)
+	if err := net.Apply(topology); err != nil {
+		t.Fatal(err)
+	}
+	return net
+}
+
+// mine has node i of net mine a block and fails the test if it can not.
+func mine(t *testing.T, net *Network, i int) bb.BasicBlock {
+	t.Helper()
+	blk, err := net.Mine(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blk
+}
+
+func TestPropagation(t *testing.T) {
+	for name, topology := range map[string]Topology{"line": Line, "ring": Ring, "star": Star, "mesh": FullMesh} {
+		t.Run(name, func(t *testing.T) {
+			net := newNetwork(t, 4, topology)
+			var mined [32]byte
+			for i := 0; i < 3; i++ {
+				mined = mine(t, net, 3).Hash
+				if _, err := net.WaitForConvergence(convergenceTimeout); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tip := net.Tip(0); tip.Hash != mined {
+				t.Errorf("node 0 is at %x, want the last mined block %x", tip.Hash, mined)
+			}
+		})
+	}
+}
+
+func TestPartitionedForksResolve(t *testing.T) {
+	for name, txIndex := range map[string]bool{"txindex": true, "scan": false} {
+		t.Run(name, func(t *testing.T) {
+			testPartitionedForksResolve(t, Options{TxIndex: txIndex})
+		})
+	}
+}
+
+func testPartitionedForksResolve(t *testing.T, opts Options) {
+	net := newNetworkWithOptions(t, 4, FullMesh, opts)
+	net.Partition([]int{0, 1}, []int{2, 3})
+	for i := 0; i < 2; i++ {
+		mine(t, net, 0)
+	}
+	for i := 0; i < 3; i++ {
+		mine(t, net, 2)
+	}
+	short, err := net.WaitForConvergence(convergenceTimeout, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	long, err := net.WaitForConvergence(convergenceTimeout, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short.Hash == long.Hash {
+		t.Fatalf("partitions did not fork")
+	}
+
+	// Nothing crosses a healed link by itself, the next block announces the longer fork to everybody.
+	net.Heal()
+	next := mine(t, net, 3)
+	tip, err := net.WaitForConvergence(convergenceTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip.Hash != next.Hash || tip.Index != long.Index+1 {
+		t.Errorf("converged on %d (%x), want the longer fork's next block %d (%x)", tip.Index, tip.Hash, next.Index, next.Hash)
+	}
+
+	// Node 0 reorganized onto the longer fork, its transaction index with it if it keeps one.
+	client := wallet.NewClient(net.servers[0].URL)
+	if st, err := client.TransactionStatus(short.Transactions[0].ID()); err == nil {
+		t.Errorf("coinbase of the abandoned fork is still reported in block %s", st.BlockHash)
+	}
+	st, err := client.TransactionStatus(long.Transactions[0].ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.BlockHash != hex.EncodeToString(long.Hash[:]) || st.BlockIndex != long.Index || st.Position != 0 || st.Confirmations != 2 {
+		t.Errorf("coinbase of the longer fork's tip: got block %d (%s), position %d, %d confirmations; want block %d (%x), position 0, 2 confirmations", st.BlockIndex, st.BlockHash, st.Position, st.Confirmations, long.Index, long.Hash)
+	}
+}
+
+func TestCompetingMinersWithLatency(t *testing.T) {
+	net := newNetwork(t, 3, Line)
+	net.SetLatency(0, 1, 50*time.Millisecond)
+	net.SetLatency(1, 2, 50*time.Millisecond)
+
+	// Both ends find a block at the same height before hearing of the other's.
+	done := make(chan struct{})
+	go func() {
+		mine(t, net, 2)
+		close(done)
+	}()
+	mine(t, net, 0)
+	<-done
+	if net.Tip(0).Hash == net.Tip(2).Hash {
+		t.Fatalf("competing miners produced the same block")
+	}
+
+	// Forks of equal work do not resolve until somebody extends one of them.
+	time.Sleep(200 * time.Millisecond)
+	next := mine(t, net, 0)
+	tip, err := net.WaitForConvergence(convergenceTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip.Hash != next.Hash {
+		t.Errorf("converged on %x, want %x", tip.Hash, next.Hash)
+	}
+}
+
+func TestBansAreByNode(t *testing.T) {
+	if !multipleLoopbacks() {
+		t.Skip("all nodes share 127.0.0.1")
+	}
+	// Node 0 bans a peer for the first message past its handshake.
+	net := newNetworkWithOptions(t, 3, func(int) [][2]int { return [][2]int{{1, 0}} }, Options{PeerLimits: node.PeerLimits{MessageRate: 1e-9, MessageBurst: 1, BanThreshold: 1}})
+	mine(t, net, 1)
+	deadline := time.Now().Add(convergenceTimeout)
+	for net.Connect(1, 0) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("node 0 did not ban node 1")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := net.Connect(2, 0); err != nil {
+		t.Errorf("node 2 could not connect after node 1 got banned: %v", err)
+	}
+}
+
+func TestPeerClockAhead(t *testing.T) {
+	now := time.Now()
+	ahead := now.Add(bb.MaxFutureDrift - 10*time.Second)
+	clocks := []bb.Clock{bb.NewManualClock(now), bb.NewManualClock(ahead), bb.NewManualClock(ahead), bb.NewManualClock(now)}
+	// Every node has as many peers agreeing with its clock as disagreeing, so none adjusts its network time.
+	net := newNetworkWithOptions(t, 4, func(int) [][2]int { return [][2]int{{0, 1}, {0, 3}, {1, 2}} }, Options{Clocks: clocks})
+	for i := 0; i < 5; i++ {
+		mine(t, net, 1)
+	}
+	shared, err := net.WaitForConvergence(convergenceTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Node 0's clock is behind the median time past of the chain it took from node 1, its block has to be stamped past that instead.
+	if bc := net.Nodes[0].BlockChain(); !bc.Headers().MedianTimePast().After(now) {
+		t.Fatalf("median time past is not ahead of node 0's clock")
+	}
+	next := mine(t, net, 0)
+	tip, err := net.WaitForConvergence(convergenceTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip.Hash != next.Hash || tip.Index != shared.Index+1 {
+		t.Errorf("converged on %d (%x), want node 0's block %d (%x)", tip.Index, tip.Hash, next.Index, next.Hash)
+	}
+}
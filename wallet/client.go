@@ -0,0 +1,185 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+)
+
+// Client talks to the http API of a running node.
+type Client struct {
+	Node string // base URL of the node, e.g. http://localhost:8000
+	HTTP *http.Client
+}
+
+// TxStatus is what a node reports about a transaction. BlockHash is empty while the transaction is still in the pool, Position is its place among the block's transactions, 0 for the coinbase. ReplacedBy is set if another transaction replaced it in the pool, ReplacedReason tells whether that one spent the same txOuts ("conflict") or replaced the transaction it depended on ("descendant").
+type TxStatus struct {
+	Transaction    bb.Transaction `json:"transaction"`
+	BlockHash      string         `json:"blockHash,omitempty"`
+	BlockIndex     int32          `json:"blockIndex,omitempty"`
+	Position       int            `json:"position,omitempty"`
+	Confirmations  int            `json:"confirmations"`
+	ReplacedBy     string         `json:"replacedBy,omitempty"`
+	ReplacedReason string         `json:"replacedReason,omitempty"`
+}
+
+// SendResult is what a node answers to a submitted transaction: its id, and the pooled transactions it replaced.
+type SendResult struct {
+	ID       string       `json:"id"`
+	Replaced []ReplacedTx `json:"replaced"`
+}
+
+// ReplacedTx is a transaction evicted from the pool, with the reason like TxStatus.ReplacedReason.
+type ReplacedTx struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// FeeEstimate is what a node suggests paying, in coins per 1000 bytes, based on the fees paid in its last Blocks blocks.
+type FeeEstimate struct {
+	FeeRate         int64 `json:"feeRate"`
+	MinRelayFeeRate int64 `json:"minRelayFeeRate"`
+	Blocks          int   `json:"blocks"`
+}
+
+// WalletStatus is what a node reports about its own wallet. UnlockedUntil is set while an encrypted wallet is unlocked for a limited time.
+type WalletStatus struct {
+	Encrypted     bool       `json:"encrypted"`
+	Locked        bool       `json:"locked"`
+	UnlockedUntil *time.Time `json:"unlockedUntil,omitempty"`
+	Balance       int32      `json:"balance"`
+}
+
+// NewClient returns a Client for the node at node. A missing scheme defaults to http.
+func NewClient(node string) *Client {
+	if !strings.Contains(node, "://") {
+		node = "http://" + node
+	}
+	return &Client{Node: strings.TrimRight(node, "/"), HTTP: http.DefaultClient}
+}
+
+func (c *Client) do(req *http.Request, v interface{}) error {
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
+// UnspentTxOuts fetches the unspent outputs locked to address.
+func (c *Client) UnspentTxOuts(address string) ([]bb.UnspentTxOut, error) {
+	req, err := http.NewRequest(http.MethodGet, c.Node+"/utxos?address="+url.QueryEscape(address), nil)
+	if err != nil {
+		return nil, err
+	}
+	var res []bb.UnspentTxOut
+	err = c.do(req, &res)
+	return res, err
+}
+
+// SpendableTxOuts fetches the unspent outputs locked to address that may be spent in the next block, which leaves out coinbase outputs that are not mature yet.
+func (c *Client) SpendableTxOuts(address string) ([]bb.UnspentTxOut, error) {
+	req, err := http.NewRequest(http.MethodGet, c.Node+"/utxos?spendable=true&address="+url.QueryEscape(address), nil)
+	if err != nil {
+		return nil, err
+	}
+	var res []bb.UnspentTxOut
+	err = c.do(req, &res)
+	return res, err
+}
+
+// SpendableScriptTxOuts is SpendableTxOuts for outputs locked with lock, such as a multisig lock.
+func (c *Client) SpendableScriptTxOuts(lock bb.Script) ([]bb.UnspentTxOut, error) {
+	req, err := http.NewRequest(http.MethodGet, c.Node+"/utxos?spendable=true&script="+hex.EncodeToString(lock), nil)
+	if err != nil {
+		return nil, err
+	}
+	var res []bb.UnspentTxOut
+	err = c.do(req, &res)
+	return res, err
+}
+
+// SendTransaction submits tx to the node's transaction pool.
+func (c *Client) SendTransaction(tx bb.Transaction) (SendResult, error) {
+	var res SendResult
+	b, err := json.Marshal(tx)
+	if err != nil {
+		return res, err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.Node+"/tx", bytes.NewReader(b))
+	if err != nil {
+		return res, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	err = c.do(req, &res)
+	return res, err
+}
+
+// TransactionStatus asks the node whether the transaction with the given id is pooled or in a block.
+func (c *Client) TransactionStatus(id [32]byte) (TxStatus, error) {
+	var res TxStatus
+	req, err := http.NewRequest(http.MethodGet, c.Node+"/tx/"+hex.EncodeToString(id[:]), nil)
+	if err != nil {
+		return res, err
+	}
+	err = c.do(req, &res)
+	return res, err
+}
+
+// Headers fetches the node's headers starting at the block with index from.
+func (c *Client) Headers(from int32) (bb.HeaderChain, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/headers?from=%d", c.Node, from), nil)
+	if err != nil {
+		return nil, err
+	}
+	var res bb.HeaderChain
+	err = c.do(req, &res)
+	return res, err
+}
+
+// Proofs fetches inclusion proofs for the transactions paying to address. They are only as good as the headers they are verified against.
+func (c *Client) Proofs(address string) ([]bb.TxInclusionProof, error) {
+	req, err := http.NewRequest(http.MethodGet, c.Node+"/proofs?address="+url.QueryEscape(address), nil)
+	if err != nil {
+		return nil, err
+	}
+	var res []bb.TxInclusionProof
+	err = c.do(req, &res)
+	return res, err
+}
+
+// Used reports whether any transaction in the node's chain pays to address.
+func (c *Client) Used(address string) (bool, error) {
+	proofs, err := c.Proofs(address)
+	return len(proofs) > 0, err
+}
+
+// FeeEstimate asks the node what fee rate to pay.
+func (c *Client) FeeEstimate() (FeeEstimate, error) {
+	req, err := http.NewRequest(http.MethodGet, c.Node+"/fee-estimate", nil)
+	if err != nil {
+		return FeeEstimate{}, err
+	}
+	var res FeeEstimate
+	err = c.do(req, &res)
+	return res, err
+}
@@ -0,0 +1,250 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+)
+
+// Keys are derived from a single seed as in Bitcoin's BIP 32, on P-256 as specified by SLIP-10: every key has a chain code, and a child key is derived from its parent's key and chain code and the child's index. Hardened children, with indices from HardenedKeyStart, are derived from the parent's private key, the others from its public key: an extended public key derives the public keys of its non-hardened descendants without any private key.
+
+// HardenedKeyStart is the index of the first hardened child.
+const HardenedKeyStart uint32 = 1 << 31
+
+// masterKeySalt is the HMAC key that turns a seed into the master key, as specified by SLIP-10 for P-256.
+var masterKeySalt = []byte("Nist256p1 seed")
+
+// ExtendedKey is a private or public key together with the chain code its children are derived with.
+type ExtendedKey struct {
+	key       *ecdsa.PrivateKey // nil for extended public keys
+	pub       *ecdsa.PublicKey
+	chainCode []byte
+}
+
+// NewMasterKey derives the root of the key tree from seed, which should be 16 to 64 random bytes.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("seed must be 16 to 64 bytes, got %d", len(seed))
+	}
+	mac := hmac.New(sha512.New, masterKeySalt)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	for {
+		k, ok := scalar(sum[:32])
+		if ok {
+			return newExtendedKey(k, sum[32:])
+		}
+		mac.Reset()
+		mac.Write(sum)
+		sum = mac.Sum(nil)
+	}
+}
+
+// PrivateKey returns the private key, nil for an extended public key.
+func (k *ExtendedKey) PrivateKey() *ecdsa.PrivateKey {
+	return k.key
+}
+
+// PublicKey returns the public key.
+func (k *ExtendedKey) PublicKey() ecdsa.PublicKey {
+	return *k.pub
+}
+
+// Public returns the extended public key of k, which derives the same public keys as k for non-hardened paths.
+func (k *ExtendedKey) Public() *ExtendedKey {
+	return &ExtendedKey{pub: k.pub, chainCode: k.chainCode}
+}
+
+// Child derives the child key with index i. Extended public keys can only derive non-hardened children.
+func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
+	var data []byte
+	if i >= HardenedKeyStart {
+		if k.key == nil {
+			return nil, fmt.Errorf("cannot derive hardened child %d from a public key", i-HardenedKeyStart)
+		}
+		priv, err := k.key.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		data = append([]byte{0}, priv...)
+	} else {
+		pub, err := bb.MarshalPublicKey(*k.pub)
+		if err != nil {
+			return nil, err
+		}
+		data = pub
+	}
+	data = binary.BigEndian.AppendUint32(data, i)
+	for {
+		mac := hmac.New(sha512.New, k.chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		if tweak, ok := scalar(sum[:32]); ok {
+			if child, err := k.addTweak(tweak, sum[32:]); err != nil || child != nil {
+				return child, err
+			}
+		}
+		// Out of range, which is astronomically unlikely: SLIP-10 derives again from the right half of the hash.
+		data = binary.BigEndian.AppendUint32(append([]byte{1}, sum[32:]...), i)
+	}
+}
+
+// addTweak returns the child key k + tweak, or k's public key plus tweak times the base point for extended public keys. It returns nil if that is zero, or the point at infinity.
+func (k *ExtendedKey) addTweak(tweak *big.Int, chainCode []byte) (*ExtendedKey, error) {
+	if k.key != nil {
+		priv, err := k.key.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		child := tweak.Add(tweak, new(big.Int).SetBytes(priv))
+		if child.Mod(child, curveOrder()).Sign() == 0 {
+			return nil, nil
+		}
+		return newExtendedKey(child, chainCode)
+	}
+	t, err := newExtendedKey(tweak, nil)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := addPoints(*k.pub, *t.pub)
+	if pub == nil || err != nil {
+		return nil, err
+	}
+	return &ExtendedKey{pub: pub, chainCode: append([]byte{}, chainCode...)}, nil
+}
+
+// Derive follows path down from k.
+func (k *ExtendedKey) Derive(path DerivationPath) (*ExtendedKey, error) {
+	var err error
+	for _, i := range path {
+		if k, err = k.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// DerivationPath is a list of child indices leading from the master key to a key.
+type DerivationPath []uint32
+
+// KeyPath is the path m/account'/change/index under which the wallet keeps its keys: each account has a chain of receive addresses (change 0) and one of change addresses (change 1).
+func KeyPath(account, change, index uint32) DerivationPath {
+	return DerivationPath{account + HardenedKeyStart, change, index}
+}
+
+// ParseDerivationPath parses paths like m/0'/1/5, where ' (or h) marks hardened indices.
+func ParseDerivationPath(s string) (DerivationPath, error) {
+	parts := strings.Split(s, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q does not start with m", s)
+	}
+	var path DerivationPath
+	for _, part := range parts[1:] {
+		offset := uint32(0)
+		if trimmed := strings.TrimRight(part, "'h"); trimmed != part {
+			part, offset = trimmed, HardenedKeyStart
+		}
+		i, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(i) >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid index %q in derivation path %q", part, s)
+		}
+		path = append(path, uint32(i)+offset)
+	}
+	return path, nil
+}
+
+func (path DerivationPath) String() string {
+	s := "m"
+	for _, i := range path {
+		if i >= HardenedKeyStart {
+			s += fmt.Sprintf("/%d'", i-HardenedKeyStart)
+		} else {
+			s += fmt.Sprintf("/%d", i)
+		}
+	}
+	return s
+}
+
+func curveOrder() *big.Int {
+	return elliptic.P256().Params().N
+}
+
+// scalar interprets b as a number and reports whether it is a valid private key, that is in [1, n).
+func scalar(b []byte) (*big.Int, bool) {
+	k := new(big.Int).SetBytes(b)
+	return k, k.Sign() != 0 && k.Cmp(curveOrder()) < 0
+}
+
+func newExtendedKey(k *big.Int, chainCode []byte) (*ExtendedKey, error) {
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), k.FillBytes(make([]byte, 32)))
+	if err != nil {
+		return nil, err
+	}
+	return &ExtendedKey{key, &key.PublicKey, append([]byte{}, chainCode...)}, nil
+}
+
+// String encodes an extended public key as the hex of its uncompressed public key followed by its chain code.
+func (k *ExtendedKey) String() string {
+	pub, err := k.pub.Bytes()
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(append(pub, k.chainCode...))
+}
+
+// ParseExtendedPublicKey decodes what String returns.
+func ParseExtendedPublicKey(s string) (*ExtendedKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 65+32 {
+		return nil, fmt.Errorf("extended public key must be 97 hex encoded bytes")
+	}
+	pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), b[:65])
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended public key: %v", err)
+	}
+	return &ExtendedKey{pub: pub, chainCode: b[65:]}, nil
+}
+
+// addPoints adds two points of P-256 in affine coordinates. Only public keys are added, so it need not run in constant time. It returns nil for the point at infinity.
+func addPoints(a, b ecdsa.PublicKey) (*ecdsa.PublicKey, error) {
+	aBytes, err := a.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	bBytes, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	p := elliptic.P256().Params().P
+	x1, y1 := new(big.Int).SetBytes(aBytes[1:33]), new(big.Int).SetBytes(aBytes[33:])
+	x2, y2 := new(big.Int).SetBytes(bBytes[1:33]), new(big.Int).SetBytes(bBytes[33:])
+	var slope *big.Int
+	if x1.Cmp(x2) == 0 {
+		if y1.Cmp(y2) != 0 {
+			return nil, nil
+		}
+		// Doubling, the slope of the tangent is (3x² + a) / 2y with a = -3.
+		num := new(big.Int).Mul(x1, x1)
+		num.Mul(num, big.NewInt(3)).Sub(num, big.NewInt(3))
+		slope = num.Mul(num, new(big.Int).ModInverse(new(big.Int).Lsh(y1, 1), p))
+	} else {
+		num := new(big.Int).Sub(y2, y1)
+		slope = num.Mul(num, new(big.Int).ModInverse(new(big.Int).Sub(x2, x1), p))
+	}
+	slope.Mod(slope, p)
+	x3 := new(big.Int).Mul(slope, slope)
+	x3.Sub(x3, x1).Sub(x3, x2).Mod(x3, p)
+	y3 := new(big.Int).Sub(x1, x3)
+	y3.Mul(y3, slope).Sub(y3, y1).Mod(y3, p)
+	out := append([]byte{4}, x3.FillBytes(make([]byte, 32))...)
+	return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(out, y3.FillBytes(make([]byte, 32))...))
+}
@@ -0,0 +1,97 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestDerivation(t *testing.T) {
+	// Test vector 1 for nist256p1 from SLIP-10.
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		path, chainCode, key string
+	}{
+		{"m", "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea", "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2"},
+		{"m/0'", "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11", "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c"},
+		{"m/0'/1", "4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c", "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129"},
+	} {
+		path, err := ParseDerivationPath(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, err := master.Derive(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, _ := k.PrivateKey().Bytes()
+		if hex.EncodeToString(k.chainCode) != tt.chainCode || hex.EncodeToString(priv) != tt.key {
+			t.Errorf("%s: chain code %x, key %x, want %s, %s", tt.path, k.chainCode, priv, tt.chainCode, tt.key)
+		}
+	}
+}
+
+func TestParseDerivationPath(t *testing.T) {
+	path, err := ParseDerivationPath("m/3'/1/7h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (DerivationPath{3 + HardenedKeyStart, 1, 7 + HardenedKeyStart}); path.String() != want.String() || len(path) != 3 {
+		t.Errorf("ParseDerivationPath = %v, want %v", path, want)
+	}
+	for _, s := range []string{"", "0/1", "m/x", "m/2147483648", "m/-1"} {
+		if _, err := ParseDerivationPath(s); err == nil {
+			t.Errorf("ParseDerivationPath(%q) succeeded", s)
+		}
+	}
+}
+
+func TestPublicDerivation(t *testing.T) {
+	master, err := NewMasterKey(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := master.Child(HardenedKeyStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := ParseExtendedPublicKey(account.Public().String())
+	if err != nil {
+		t.Fatalf("ParseExtendedPublicKey failed: %v", err)
+	}
+	for _, path := range []DerivationPath{{0, 0}, {0, 5}, {1, 3}, {7}} {
+		priv, err := account.Derive(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := public.Derive(path)
+		if err != nil {
+			t.Fatalf("public derivation of %v failed: %v", path, err)
+		}
+		if want, got := priv.PublicKey(), pub.PublicKey(); !want.Equal(&got) {
+			t.Errorf("%v: public derivation gives another key than private derivation", path)
+		}
+		if pub.PrivateKey() != nil {
+			t.Errorf("%v: public derivation gave a private key", path)
+		}
+	}
+	if _, err := public.Child(HardenedKeyStart); err == nil {
+		t.Errorf("derived a hardened child from a public key")
+	}
+
+	// Doubling is the one case of the point addition that derivation hardly ever hits: check 3G = 2G + G = (G + G) + G.
+	g, _ := newExtendedKey(big.NewInt(1), nil)
+	three, _ := newExtendedKey(big.NewInt(3), nil)
+	double, err := addPoints(g.PublicKey(), g.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := addPoints(*double, g.PublicKey())
+	if want := three.PublicKey(); err != nil || !sum.Equal(&want) {
+		t.Errorf("G + G + G = %v, %v, want 3G", sum, err)
+	}
+}
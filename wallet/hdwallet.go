@@ -0,0 +1,433 @@
+package wallet
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+)
+
+// An HDWallet derives all its keys from one seed, so backing up the seed backs up every address it ever handed out. It gives out a fresh receive address per payment and sends change to fresh change addresses. Restored from the seed alone, or from the mnemonic it was derived from, it finds the addresses in use by asking the chain about them, until GapLimit addresses in a row were never paid to.
+
+// DefaultWalletPath is where the wallet keeps its seed unless told otherwise.
+var DefaultWalletPath = filepath.Join(os.Getenv("HOME"), ".naivecoin", "wallet.json")
+
+// GapLimit is how many unused addresses in a row address discovery looks at before it concludes that no later one was used.
+var GapLimit uint32 = 20
+
+// The two chains of keys of an account.
+const (
+	ReceiveChain uint32 = 0
+	ChangeChain  uint32 = 1
+)
+
+// entropySize is the entropy of the mnemonics generated for new wallets, 24 words.
+const entropySize = 32
+
+// HDWallet is a hierarchical deterministic wallet: the keys of one account, derived from a seed, and how many of them were handed out. An encrypted wallet is locked until unlocked with its password; it derives public keys but no private keys while locked.
+type HDWallet struct {
+	account    uint32
+	accountPub *ExtendedKey     // m/account', public only
+	encrypted  *encryptedSecret // the walletSecrets, nil if the wallet is not encrypted
+	next       [2]uint32        // next unused index of the receive and the change chain
+
+	mu            sync.Mutex // guards secrets, accountKey, clock, stopLock and unlockedUntil, which the unlock timeout changes
+	secrets       *walletSecrets
+	accountKey    *ExtendedKey  // m/account', nil while locked
+	clock         bb.Clock      // times the unlock timeout, bb.LocalClock if nil
+	stopLock      chan struct{} // closed to cancel the pending unlock timeout, nil if there is none
+	unlockedUntil time.Time
+}
+
+// walletSecrets is what an encrypted wallet encrypts.
+type walletSecrets struct {
+	Mnemonic string `json:"mnemonic,omitempty"` // that Seed was derived from, empty for wallets created from a seed
+	Seed     string `json:"seed"`
+}
+
+// hdWalletFile is how an HDWallet is saved. Unencrypted wallets save Mnemonic and Seed, encrypted ones Encrypted.
+type hdWalletFile struct {
+	Mnemonic   string           `json:"mnemonic,omitempty"`
+	Seed       string           `json:"seed,omitempty"`
+	Encrypted  *encryptedSecret `json:"encrypted,omitempty"`
+	AccountKey string           `json:"accountKey"`
+	Account    uint32           `json:"account"`
+	Receive    uint32           `json:"receive"`
+	Change     uint32           `json:"change"`
+}
+
+// NewHDWallet returns the wallet for account whose keys are derived from seed.
+func NewHDWallet(seed []byte, account uint32) (*HDWallet, error) {
+	if account >= HardenedKeyStart {
+		return nil, fmt.Errorf("invalid account %d", account)
+	}
+	w := &HDWallet{account: account}
+	if err := w.setSecrets(&walletSecrets{Seed: hex.EncodeToString(seed)}); err != nil {
+		return nil, err
+	}
+	w.accountPub = w.accountKey.Public()
+	return w, nil
+}
+
+// NewHDWalletFromMnemonic returns the wallet for account whose keys are derived from mnemonic and passphrase.
+func NewHDWalletFromMnemonic(mnemonic, passphrase string, account uint32) (*HDWallet, error) {
+	seed, err := MnemonicSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewHDWallet(seed, account)
+	if err != nil {
+		return nil, err
+	}
+	w.secrets.Mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	return w, nil
+}
+
+// CreateHDWallet creates a wallet with a new random mnemonic, protected by passphrase, and saves it at path, which must not exist yet.
+func CreateHDWallet(path, passphrase string) (*HDWallet, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%s already exists", path)
+	}
+	entropy := make([]byte, entropySize)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, err
+	}
+	mnemonic, err := NewMnemonic(entropy)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewHDWalletFromMnemonic(mnemonic, passphrase, 0)
+	if err != nil {
+		return nil, err
+	}
+	return w, w.Save(path)
+}
+
+// LoadOrCreateHDWallet reads the wallet saved at path, creating and saving one with a new random mnemonic and no passphrase if the file does not exist yet. Encrypted wallets are loaded locked.
+func LoadOrCreateHDWallet(path string) (*HDWallet, error) {
+	w, err := LoadHDWallet(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return CreateHDWallet(path, "")
+	}
+	return w, err
+}
+
+// LoadHDWallet reads the wallet saved at path, which has to exist. Encrypted wallets are loaded locked.
+func LoadHDWallet(path string) (*HDWallet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f hdWalletFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	var w *HDWallet
+	if f.Encrypted == nil {
+		seed, err := hex.DecodeString(f.Seed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: seed is not hex: %v", path, err)
+		}
+		if w, err = NewHDWallet(seed, f.Account); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		w.secrets.Mnemonic = f.Mnemonic
+	} else {
+		accountPub, err := ParseExtendedPublicKey(f.AccountKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		w = &HDWallet{account: f.Account, accountPub: accountPub, encrypted: f.Encrypted}
+	}
+	w.next = [2]uint32{f.Receive, f.Change}
+	return w, nil
+}
+
+// Save writes the wallet to path, readable only by the user. An encrypted wallet writes its secrets only encrypted, also while unlocked.
+func (w *HDWallet) Save(path string) error {
+	f := hdWalletFile{Encrypted: w.encrypted, AccountKey: w.accountPub.String(), Account: w.account, Receive: w.next[ReceiveChain], Change: w.next[ChangeChain]}
+	if w.encrypted == nil {
+		w.mu.Lock()
+		f.Mnemonic, f.Seed = w.secrets.Mnemonic, w.secrets.Seed
+		w.mu.Unlock()
+	}
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0600)
+}
+
+// Encrypt encrypts the wallet's secrets with password, or changes the password of an encrypted wallet. The wallet has to be unlocked, and stays so until Lock is called.
+func (w *HDWallet) Encrypt(password string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.secrets == nil {
+		return ErrLocked
+	}
+	plaintext, err := json.Marshal(w.secrets)
+	if err != nil {
+		return err
+	}
+	encrypted, err := encryptSecret(plaintext, password)
+	if err != nil {
+		return err
+	}
+	w.encrypted = encrypted
+	return nil
+}
+
+// Encrypted reports whether the wallet is encrypted.
+func (w *HDWallet) Encrypted() bool {
+	return w.encrypted != nil
+}
+
+// Unlock decrypts the wallet's private keys with password. If timeout is positive the wallet locks itself again after it.
+func (w *HDWallet) Unlock(password string, timeout time.Duration) error {
+	if w.encrypted == nil {
+		return fmt.Errorf("the wallet is not encrypted")
+	}
+	plaintext, err := w.encrypted.decrypt(password)
+	if err != nil {
+		return err
+	}
+	var secrets walletSecrets
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.setSecrets(&secrets); err != nil {
+		return err
+	}
+	if pub := w.accountKey.PublicKey(); !pub.Equal(w.accountPub.pub) {
+		w.clearSecrets()
+		return fmt.Errorf("the encrypted seed does not belong to the wallet's account key")
+	}
+	w.stopLockTimeout()
+	w.unlockedUntil = time.Time{}
+	if timeout > 0 {
+		clock := w.clock
+		if clock == nil {
+			clock = bb.LocalClock
+		}
+		stop, ticker := make(chan struct{}), clock.NewTicker(timeout)
+		go func() {
+			defer ticker.Stop()
+			select {
+			case <-ticker.C():
+				w.mu.Lock()
+				defer w.mu.Unlock()
+				if w.stopLock == stop { // not unlocked again in the meantime
+					w.clearSecrets()
+				}
+			case <-stop:
+			}
+		}()
+		w.stopLock, w.unlockedUntil = stop, clock.Now().Add(timeout)
+	}
+	return nil
+}
+
+// Lock forgets the private keys of an encrypted wallet until it is unlocked again. It does nothing to unencrypted wallets.
+func (w *HDWallet) Lock() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.encrypted == nil {
+		return
+	}
+	w.clearSecrets()
+}
+
+// SetClock makes the wallet time its unlock timeout with clock instead of bb.LocalClock.
+func (w *HDWallet) SetClock(clock bb.Clock) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.clock = clock
+}
+
+// stopLockTimeout cancels the pending unlock timeout, if any. w.mu is held.
+func (w *HDWallet) stopLockTimeout() {
+	if w.stopLock != nil {
+		close(w.stopLock)
+		w.stopLock = nil
+	}
+}
+
+// Locked reports whether the wallet can not sign, and until when it is unlocked otherwise, zero for no time limit.
+func (w *HDWallet) Locked() (bool, time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.accountKey == nil, w.unlockedUntil
+}
+
+// setSecrets derives the account key from secrets. w.mu is held, or w is not shared yet.
+func (w *HDWallet) setSecrets(secrets *walletSecrets) error {
+	seed, err := hex.DecodeString(secrets.Seed)
+	if err != nil {
+		return fmt.Errorf("seed is not hex: %v", err)
+	}
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return err
+	}
+	accountKey, err := master.Child(w.account + HardenedKeyStart)
+	if err != nil {
+		return err
+	}
+	w.secrets, w.accountKey = secrets, accountKey
+	return nil
+}
+
+// clearSecrets drops the secrets and cancels the unlock timeout. w.mu is held.
+func (w *HDWallet) clearSecrets() {
+	w.stopLockTimeout()
+	w.secrets, w.accountKey, w.unlockedUntil = nil, nil, time.Time{}
+}
+
+// Mnemonic returns the words to write down to restore the wallet, together with its passphrase.
+func (w *HDWallet) Mnemonic() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.secrets == nil {
+		return "", ErrLocked
+	}
+	if w.secrets.Mnemonic == "" {
+		return "", fmt.Errorf("the wallet was created from a seed, not a mnemonic")
+	}
+	return w.secrets.Mnemonic, nil
+}
+
+// Key derives the private key with index on chain, ReceiveChain or ChangeChain. It fails with ErrLocked while the wallet is locked.
+func (w *HDWallet) Key(chain, index uint32) (*ecdsa.PrivateKey, error) {
+	w.mu.Lock()
+	accountKey := w.accountKey
+	w.mu.Unlock()
+	if accountKey == nil {
+		return nil, ErrLocked
+	}
+	k, err := accountKey.Derive(DerivationPath{chain, index})
+	if err != nil {
+		return nil, err
+	}
+	return k.PrivateKey(), nil
+}
+
+// PublicKey derives the public key with index on chain, also while the wallet is locked.
+func (w *HDWallet) PublicKey(chain, index uint32) (ecdsa.PublicKey, error) {
+	k, err := w.accountPub.Derive(DerivationPath{chain, index})
+	if err != nil {
+		return ecdsa.PublicKey{}, err
+	}
+	return k.PublicKey(), nil
+}
+
+// NextPublicKey hands out the next key of chain. The wallet has to be saved for it to stay handed out.
+func (w *HDWallet) NextPublicKey(chain uint32) (ecdsa.PublicKey, error) {
+	pub, err := w.PublicKey(chain, w.next[chain])
+	if err != nil {
+		return ecdsa.PublicKey{}, err
+	}
+	w.next[chain]++
+	return pub, nil
+}
+
+// PublicKeys returns the public keys of all keys handed out so far, the ones that may have been paid to.
+func (w *HDWallet) PublicKeys() ([]ecdsa.PublicKey, error) {
+	var res []ecdsa.PublicKey
+	for _, chain := range []uint32{ReceiveChain, ChangeChain} {
+		for i := uint32(0); i < w.next[chain]; i++ {
+			pub, err := w.PublicKey(chain, i)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, pub)
+		}
+	}
+	return res, nil
+}
+
+// Keys returns the private keys of all keys handed out so far. It fails with ErrLocked while the wallet is locked.
+func (w *HDWallet) Keys() ([]crypto.Signer, error) {
+	var res []crypto.Signer
+	for _, chain := range []uint32{ReceiveChain, ChangeChain} {
+		for i := uint32(0); i < w.next[chain]; i++ {
+			k, err := w.Key(chain, i)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, k)
+		}
+	}
+	return res, nil
+}
+
+// Discover looks for the keys used on the chain, according to used, and moves past them, so that a wallet restored from its seed knows its addresses again. On each chain it stops after GapLimit unused keys in a row.
+func (w *HDWallet) Discover(used func(ecdsa.PublicKey) (bool, error)) error {
+	for _, chain := range []uint32{ReceiveChain, ChangeChain} {
+		for i, gap := uint32(0), uint32(0); gap < GapLimit; i++ {
+			pub, err := w.PublicKey(chain, i)
+			if err != nil {
+				return err
+			}
+			ok, err := used(pub)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				gap++
+				continue
+			}
+			gap = 0
+			w.next[chain] = max(w.next[chain], i+1)
+		}
+	}
+	return nil
+}
+
+// Balance sums the amounts of the outputs of aUnspentTxOuts locked to any key of the wallet.
+func (w *HDWallet) Balance(aUnspentTxOuts []bb.UnspentTxOut) (int32, error) {
+	pubs, err := w.PublicKeys()
+	if err != nil {
+		return 0, err
+	}
+	var balance int32
+	for _, pub := range pubs {
+		balance += Balance(pub, aUnspentTxOuts)
+	}
+	return balance, nil
+}
+
+// CreateTransaction builds and signs a transaction sending amount coins to receiver, spending outputs locked to any key of the wallet and sending any change to a fresh change address. It fails with ErrLocked while the wallet is locked. The wallet has to be saved once the transaction is sent.
+func (w *HDWallet) CreateTransaction(receiver bb.Script, amount int32, feeRate int64, aUnspentTxOuts []bb.UnspentTxOut) (bb.Transaction, error) {
+	keys, err := w.Keys()
+	if err != nil {
+		return bb.Transaction{}, err
+	}
+	change, err := w.PublicKey(ChangeChain, w.next[ChangeChain])
+	if err != nil {
+		return bb.Transaction{}, err
+	}
+	tx, err := createTransaction(receiver, bb.PayToPubKeyHash(change), amount, feeRate, keys, aUnspentTxOuts)
+	if err != nil {
+		return bb.Transaction{}, err
+	}
+	if len(tx.TxOuts()) > 1 {
+		w.next[ChangeChain]++
+	}
+	return tx, nil
+}
@@ -0,0 +1,152 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+)
+
+func TestLoadOrCreateHDWallet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	created, err := LoadOrCreateHDWallet(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateHDWallet failed: %v", err)
+	}
+	first, _ := created.NextPublicKey(ReceiveChain)
+	second, _ := created.NextPublicKey(ReceiveChain)
+	if first.Equal(&second) {
+		t.Errorf("NextPublicKey handed out the same key twice")
+	}
+	if err := created.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadOrCreateHDWallet(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateHDWallet failed: %v", err)
+	}
+	if third, _ := loaded.NextPublicKey(ReceiveChain); third.Equal(&first) || third.Equal(&second) {
+		t.Errorf("loaded wallet handed out a key again")
+	}
+	if k, _ := loaded.Key(ReceiveChain, 1); !k.PublicKey.Equal(&second) {
+		t.Errorf("loaded wallet derives different keys")
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	if _, err := LoadHDWallet(missing); err == nil {
+		t.Errorf("LoadHDWallet of a missing file succeeded")
+	}
+	if _, err := os.Stat(missing); err == nil {
+		t.Errorf("LoadHDWallet created a wallet")
+	}
+}
+
+func TestHDWallet(t *testing.T) {
+	origParams := bb.Params
+	bb.Params.CoinbaseMaturity = 1
+	t.Cleanup(func() { bb.Params = origParams })
+	seed := make([]byte, 32)
+	w, err := NewHDWallet(seed, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := func(chain, index uint32) *ecdsa.PrivateKey {
+		k, err := w.Key(chain, index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	// Mine to receive addresses 0 and 3 of the wallet, and to 30, beyond the gap limit.
+	blockChain := bb.BlockChain{bb.GenesisBlock}
+	for i, index := range []uint32{0, 3, 30} {
+		blockChain = append(blockChain, blockChain[i].FindBlockWithTransactions([]byte{}, []bb.Transaction{bb.NewCoinbaseTransaction(key(ReceiveChain, index).PublicKey, int32(i+1))}))
+	}
+	utxos, err := blockChain.UnspentTxOuts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := func(pub ecdsa.PublicKey) (bool, error) { return len(mine(bb.PayToPubKeyHash(pub), utxos)) > 0, nil }
+
+	restored, err := NewHDWallet(seed, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Discover(used); err != nil {
+		t.Fatal(err)
+	}
+	if restored.next != [2]uint32{4, 0} {
+		t.Errorf("discovered next indices %v, want [4 0]", restored.next)
+	}
+	if b, _ := restored.Balance(utxos); b != 2*bb.CoinbaseAmount {
+		t.Errorf("balance = %d, want %d", b, 2*bb.CoinbaseAmount)
+	}
+	if other, _ := NewHDWallet(seed, 1); other.Discover(used) != nil || other.next != [2]uint32{0, 0} {
+		t.Errorf("another account discovered keys of account 0: %v", other.next)
+	}
+
+	// Spending more than one coinbase takes both keys, and the change goes to a fresh change address.
+	receiver := bb.PayToPubKeyHash(key(ReceiveChain, 100).PublicKey)
+	tx, err := restored.CreateTransaction(receiver, bb.CoinbaseAmount+10, 1, utxos)
+	if err != nil {
+		t.Fatalf("CreateTransaction failed: %v", err)
+	}
+	if len(tx.TxIns()) != 2 || !tx.TxOuts()[1].LockingScript().Equal(bb.PayToPubKeyHash(key(ChangeChain, 0).PublicKey)) {
+		t.Errorf("transaction does not spend both coinbases and send change to change address 0")
+	}
+	if restored.next[ChangeChain] != 1 {
+		t.Errorf("change address was not handed out")
+	}
+	if _, _, err := (bb.TransactionPool{}).Add(tx, utxos, blockChain.NextContext()); err != nil {
+		t.Errorf("created transaction was rejected: %v", err)
+	}
+}
+
+func TestRestoreHDWallet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	w, err := CreateHDWallet(path, "correct horse")
+	if err != nil {
+		t.Fatalf("CreateHDWallet failed: %v", err)
+	}
+	if _, err := CreateHDWallet(path, ""); err == nil {
+		t.Errorf("CreateHDWallet overwrote a wallet")
+	}
+	loaded, err := LoadOrCreateHDWallet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnemonic, err := loaded.Mnemonic()
+	if err != nil {
+		t.Fatalf("Mnemonic failed: %v", err)
+	}
+	if n := len(strings.Fields(mnemonic)); n != 24 {
+		t.Errorf("mnemonic has %d words, want 24", n)
+	}
+
+	want, _ := w.Key(ChangeChain, 7)
+	restored, err := NewHDWalletFromMnemonic(mnemonic, "correct horse", 0)
+	if err != nil {
+		t.Fatalf("NewHDWalletFromMnemonic failed: %v", err)
+	}
+	if k, _ := restored.Key(ChangeChain, 7); !k.Equal(want) {
+		t.Errorf("restored wallet derives different keys")
+	}
+	other, err := NewHDWalletFromMnemonic(mnemonic, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, _ := other.Key(ChangeChain, 7); k.Equal(want) {
+		t.Errorf("the passphrase does not change the keys")
+	}
+	seedOnly, err := NewHDWallet(make([]byte, 32), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := seedOnly.Mnemonic(); err == nil {
+		t.Errorf("wallet created from a seed has a mnemonic")
+	}
+}
@@ -0,0 +1,118 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// An encrypted wallet keeps its mnemonic and seed encrypted with AES-256-GCM, under a key derived from the wallet password with scrypt. Only the extended public key of the account stays in the clear, so a locked wallet still hands out addresses and watches its balance, but can not sign.
+
+// ErrLocked is returned for anything needing the private keys of a locked wallet.
+var ErrLocked = errors.New("wallet is locked")
+
+// ErrWrongPassword is returned when a wallet can not be decrypted with the given password.
+var ErrWrongPassword = errors.New("wrong wallet password")
+
+// ScryptN is the scrypt cost parameter for newly encrypted wallets, about 100ms and 32MiB of memory with r = 8.
+var ScryptN = 1 << 15
+
+const (
+	scryptR      = 8
+	scryptP      = 1
+	saltSize     = 32
+	walletKeyLen = 32 // AES-256
+)
+
+// Bounds on the scrypt parameters read from a wallet file, so that a tampered file can not make unlocking it take all memory or forever.
+const (
+	maxScryptMemory = 1 << 30 // bytes, scrypt takes 128·N·r
+	maxScryptP      = 16
+)
+
+// encryptedSecret is the ciphertext of a wallet's secrets and what it takes to derive its key from the password.
+type encryptedSecret struct {
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// encryptSecret encrypts plaintext with a key derived from password and a new random salt.
+func encryptSecret(plaintext []byte, password string) (*encryptedSecret, error) {
+	if password == "" {
+		return nil, fmt.Errorf("the wallet password must not be empty")
+	}
+	e := &encryptedSecret{KDF: "scrypt", N: ScryptN, R: scryptR, P: scryptP}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	e.Salt = hex.EncodeToString(salt)
+	aead, err := e.aead(password)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	e.Nonce = hex.EncodeToString(nonce)
+	e.Ciphertext = hex.EncodeToString(aead.Seal(nil, nonce, plaintext, nil))
+	return e, nil
+}
+
+// decrypt returns the plaintext, or ErrWrongPassword if password does not decrypt it.
+func (e *encryptedSecret) decrypt(password string) ([]byte, error) {
+	nonce, err := hex.DecodeString(e.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("nonce is not hex: %v", err)
+	}
+	ciphertext, err := hex.DecodeString(e.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("ciphertext is not hex: %v", err)
+	}
+	aead, err := e.aead(password)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("nonce must be %d bytes, got %d", aead.NonceSize(), len(nonce))
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	return plaintext, nil
+}
+
+// aead derives the key from password and returns the cipher encrypting with it.
+func (e *encryptedSecret) aead(password string) (cipher.AEAD, error) {
+	if e.KDF != "scrypt" {
+		return nil, fmt.Errorf("unknown key derivation function %q", e.KDF)
+	}
+	if e.N < 2 || e.R < 1 || e.P < 1 || e.P > maxScryptP || e.R > maxScryptMemory/128/e.N {
+		return nil, fmt.Errorf("scrypt parameters n=%d, r=%d, p=%d are out of bounds", e.N, e.R, e.P)
+	}
+	salt, err := hex.DecodeString(e.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("salt is not hex: %v", err)
+	}
+	key, err := scrypt.Key([]byte(password), salt, e.N, e.R, e.P, walletKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,105 @@
+package wallet
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+)
+
+func TestEncryptedWallet(t *testing.T) {
+	origN := ScryptN
+	ScryptN = 1 << 10
+	t.Cleanup(func() { ScryptN = origN })
+
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	w, err := CreateHDWallet(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnemonic, _ := w.Mnemonic()
+	address, _ := w.NextPublicKey(ReceiveChain)
+	if err := w.Encrypt(""); err == nil {
+		t.Errorf("encrypted with an empty password")
+	}
+	if err := w.Encrypt("hunter2"); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if err := w.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path)
+	if strings.Contains(string(b), strings.Fields(mnemonic)[0]+" ") || strings.Contains(string(b), `"seed"`) {
+		t.Errorf("encrypted wallet file contains its secrets:\n%s", b)
+	}
+
+	loaded, err := LoadOrCreateHDWallet(path)
+	if err != nil {
+		t.Fatalf("loading the encrypted wallet failed: %v", err)
+	}
+	if locked, _ := loaded.Locked(); !locked || !loaded.Encrypted() {
+		t.Errorf("encrypted wallet was not loaded locked")
+	}
+	if pub, err := loaded.PublicKey(ReceiveChain, 0); err != nil || !pub.Equal(&address) {
+		t.Errorf("locked wallet derives %v, %v, want the address it handed out", pub, err)
+	}
+	if _, err := loaded.Key(ReceiveChain, 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("Key of a locked wallet = %v, want ErrLocked", err)
+	}
+	if _, err := loaded.Mnemonic(); !errors.Is(err, ErrLocked) {
+		t.Errorf("Mnemonic of a locked wallet = %v, want ErrLocked", err)
+	}
+	if _, err := loaded.CreateTransaction(bb.PayToPubKeyHash(address), 1, 1, nil); !errors.Is(err, ErrLocked) {
+		t.Errorf("locked wallet signed a transaction, error %v", err)
+	}
+	if err := loaded.Unlock("hunter3", 0); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Unlock with the wrong password = %v, want ErrWrongPassword", err)
+	}
+	// A tampered file must not make unlocking take all memory.
+	for _, e := range []encryptedSecret{{N: 1 << 30, R: 8, P: 1}, {N: 1 << 10, R: 1 << 30, P: 1}, {N: 1 << 10, R: 8, P: 1 << 20}, {N: 0, R: 8, P: 1}} {
+		e.KDF, e.Salt = "scrypt", loaded.encrypted.Salt
+		if _, err := e.aead("hunter2"); err == nil {
+			t.Errorf("derived a key with scrypt parameters n=%d, r=%d, p=%d", e.N, e.R, e.P)
+		}
+	}
+
+	if err := loaded.Unlock("hunter2", 0); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if k, err := loaded.Key(ReceiveChain, 0); err != nil || !k.PublicKey.Equal(&address) {
+		t.Errorf("unlocked wallet derives %v, %v, want the key of the address it handed out", k, err)
+	}
+	if got, _ := loaded.Mnemonic(); got != mnemonic {
+		t.Errorf("decrypted mnemonic differs")
+	}
+	loaded.Lock()
+	if locked, _ := loaded.Locked(); !locked {
+		t.Errorf("Lock did not lock the wallet")
+	}
+
+	clock := bb.NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	loaded.SetClock(clock)
+	if err := loaded.Unlock("hunter2", 5*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if locked, until := loaded.Locked(); locked || !until.Equal(clock.Now().Add(5*time.Minute)) {
+		t.Errorf("Locked() = %t, %v, want unlocked until 5 minutes from now", locked, until)
+	}
+	clock.Advance(4 * time.Minute)
+	if locked, _ := loaded.Locked(); locked {
+		t.Errorf("wallet locked itself before the timeout")
+	}
+	clock.Advance(time.Minute)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if locked, _ := loaded.Locked(); locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("wallet did not lock itself after the timeout")
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package wallet
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// A wallet's seed can be written down as a mnemonic, a list of 12 to 24 words, as in Bitcoin's BIP 39: the words encode random entropy and a checksum of it, and the seed is derived from the words and an optional passphrase. A different passphrase gives a different, equally valid wallet.
+
+//go:embed wordlist_english.txt
+var wordlistFile string
+
+// wordlist is the BIP 39 English word list, wordIndex the index of each word in it.
+var wordlist = strings.Fields(wordlistFile)
+var wordIndex = func() map[string]int {
+	res := make(map[string]int, len(wordlist))
+	for i, w := range wordlist {
+		res[w] = i
+	}
+	return res
+}()
+
+// mnemonicSeedRounds is the number of PBKDF2 rounds turning a mnemonic into a seed.
+const mnemonicSeedRounds = 2048
+
+// NewMnemonic encodes entropy, 16 to 32 bytes in steps of 4, as a mnemonic of 12 to 24 words.
+func NewMnemonic(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("entropy must be 16 to 32 bytes in steps of 4, got %d", len(entropy))
+	}
+	// Every word encodes 11 bits; the entropy is followed by the first len(entropy)/4 bits of its hash.
+	checksumBits := uint(len(entropy) / 4)
+	hash := sha256.Sum256(entropy)
+	n := new(big.Int).SetBytes(entropy)
+	n.Lsh(n, checksumBits).Or(n, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+	words := make([]string, (len(entropy)*8+int(checksumBits))/11)
+	mask := big.NewInt(1<<11 - 1)
+	for i := len(words) - 1; i >= 0; i-- {
+		words[i] = wordlist[new(big.Int).And(n, mask).Int64()]
+		n.Rsh(n, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicEntropy decodes mnemonic into the entropy it encodes, checking its words and checksum.
+func MnemonicEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words, got %d", len(words))
+	}
+	n := new(big.Int)
+	for _, w := range words {
+		i, ok := wordIndex[w]
+		if !ok {
+			return nil, fmt.Errorf("%q is not a mnemonic word", w)
+		}
+		n.Lsh(n, 11).Or(n, big.NewInt(int64(i)))
+	}
+	checksumBits := uint(len(words) / 3)
+	checksum := byte(new(big.Int).And(n, big.NewInt(1<<checksumBits-1)).Int64())
+	entropy := n.Rsh(n, checksumBits).FillBytes(make([]byte, len(words)*4/3))
+	if hash := sha256.Sum256(entropy); hash[0]>>(8-checksumBits) != checksum {
+		return nil, fmt.Errorf("mnemonic checksum does not match, a word is wrong or missing")
+	}
+	return entropy, nil
+}
+
+// MnemonicSeed checks mnemonic and derives the 64 byte seed from it and passphrase. Unlike BIP 39 it does not normalize the passphrase to Unicode NFKD, which only matters for passphrases with non-ASCII characters.
+func MnemonicSeed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := MnemonicEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+	return pbkdf2.Key(sha512.New, strings.Join(strings.Fields(mnemonic), " "), []byte("mnemonic"+passphrase), mnemonicSeedRounds, 64)
+}
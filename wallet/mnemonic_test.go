@@ -0,0 +1,43 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestMnemonic(t *testing.T) {
+	// Test vectors from BIP 39, with the passphrase TREZOR.
+	for _, tt := range []struct {
+		entropy, mnemonic, seed string
+	}{
+		{"00000000000000000000000000000000", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"},
+		{"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f", "legal winner thank year wave sausage worth useful legal winner thank yellow", "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607"},
+		{"808080808080808080808080808080808080808080808080", "letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter always", "107d7c02a5aa6f38c58083ff74f04c607c2d2c0ecc55501dadd72d025b751bc27fe913ffb796f841c49b1d33b610cf0e91d3aa239027f5e99fe4ce9e5088cd65"},
+		{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote", "dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad"},
+	} {
+		entropy, _ := hex.DecodeString(tt.entropy)
+		mnemonic, err := NewMnemonic(entropy)
+		if err != nil || mnemonic != tt.mnemonic {
+			t.Errorf("NewMnemonic(%s) = %q, %v, want %q", tt.entropy, mnemonic, err, tt.mnemonic)
+		}
+		if got, err := MnemonicEntropy(tt.mnemonic); err != nil || !bytes.Equal(got, entropy) {
+			t.Errorf("MnemonicEntropy(%q) = %x, %v, want %s", tt.mnemonic, got, err, tt.entropy)
+		}
+		if seed, err := MnemonicSeed(tt.mnemonic, "TREZOR"); err != nil || hex.EncodeToString(seed) != tt.seed {
+			t.Errorf("MnemonicSeed(%q) = %x, %v, want %s", tt.mnemonic, seed, err, tt.seed)
+		}
+	}
+
+	valid := strings.Fields("legal winner thank year wave sausage worth useful legal winner thank yellow")
+	for _, words := range [][]string{
+		valid[:11],
+		append(append([]string{}, valid[:11]...), "yard"), // checksum does not match
+		append(append([]string{}, valid[:11]...), "yellows"),
+	} {
+		if _, err := MnemonicSeed(strings.Join(words, " "), ""); err == nil {
+			t.Errorf("invalid mnemonic %q was accepted", strings.Join(words, " "))
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package wallet
+
+import (
+	"crypto"
+	"encoding/hex"
+	"fmt"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+)
+
+// Spending a multisig output takes signatures from several co-signers, usually on different machines. The transaction goes around as a PartialTransaction: one co-signer creates it with CreateMultiSigTransaction, each adds their signatures with Sign, and once enough are collected Finalize builds the unlocking scripts.
+
+// maxSignatureSize is the longest ASN.1 ECDSA P-256 signature followed by its bb.SigHashType, fees are paid as if every signature had this size.
+const maxSignatureSize = 72 + 1
+
+// PartialTransaction is a transaction spending multisig outputs together with the signatures collected so far. It is what co-signers pass around, as JSON.
+type PartialTransaction struct {
+	Transaction bb.Transaction `json:"transaction"`
+	Inputs      []PartialInput `json:"inputs"`
+}
+
+// PartialInput is the lock and amount of an output spent by a PartialTransaction, which signatures commit to, and the signatures for it, keyed by the public key of the signer as encoded by bb.EncodePublicKey.
+type PartialInput struct {
+	LockingScript string            `json:"lockingScript"`
+	Amount        int32             `json:"amount"`
+	Signatures    map[string]string `json:"signatures"`
+}
+
+// CreateMultiSigTransaction builds an unsigned transaction sending amount coins to receiver, spending outputs locked with lock, a PayToMultiSig script, and sending any change back to it. It pays a fee of feeRate coins per 1000 bytes of the transaction once it is signed.
+func CreateMultiSigTransaction(lock, receiver bb.Script, amount int32, feeRate int64, aUnspentTxOuts []bb.UnspentTxOut) (*PartialTransaction, error) {
+	m, _, ok := lock.MultiSig()
+	if !ok {
+		return nil, fmt.Errorf("%s is not a multisig lock", lock)
+	}
+	placeholder := make([][]byte, m)
+	for i := range placeholder {
+		placeholder[i] = make([]byte, maxSignatureSize)
+	}
+	var spent []bb.UnspentTxOut
+	tx, err := buildTransaction(receiver, lock, amount, feeRate, mine(lock, aUnspentTxOuts), func(tx *bb.Transaction, included []bb.UnspentTxOut) error {
+		spent = included
+		for i := range tx.TxIns() {
+			tx.SetUnlockingScript(i, bb.MultiSigUnlockingScript(placeholder))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	p := &PartialTransaction{Transaction: tx}
+	for i, utxo := range spent {
+		p.Transaction.SetUnlockingScript(i, nil)
+		p.Inputs = append(p.Inputs, PartialInput{hex.EncodeToString(lock), utxo.Amount(), map[string]string{}})
+	}
+	return p, nil
+}
+
+// Sign adds the signatures of keys to every input whose lock lists them. It fails if none of keys is listed by any input.
+func (p *PartialTransaction) Sign(keys ...crypto.Signer) error {
+	signed := 0
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		_, pubs, err := in.multiSig()
+		if err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+		lock, _ := hex.DecodeString(in.LockingScript)
+		for _, pub := range pubs {
+			for _, k := range keys {
+				if bb.EncodePublicKey(k.Public()) != bb.EncodePublicKey(pub) {
+					continue
+				}
+				sig, err := p.Transaction.Signature(k, i, bb.NewTxOutWithScript(lock, in.Amount), bb.SigHashAll)
+				if err != nil {
+					return err
+				}
+				if in.Signatures == nil {
+					in.Signatures = make(map[string]string)
+				}
+				in.Signatures[bb.EncodePublicKey(pub)] = hex.EncodeToString(sig)
+				signed++
+			}
+		}
+	}
+	if signed == 0 {
+		return fmt.Errorf("none of the %d keys is a key of any input", len(keys))
+	}
+	return nil
+}
+
+// Missing returns how many more signatures each input needs.
+func (p *PartialTransaction) Missing() ([]int, error) {
+	var res []int
+	for i, in := range p.Inputs {
+		m, pubs, err := in.multiSig()
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %v", i, err)
+		}
+		res = append(res, max(0, m-len(in.signatures(pubs))))
+	}
+	return res, nil
+}
+
+// Finalize returns the transaction with the unlocking scripts built from the collected signatures. Every input needs at least as many as its lock requires.
+func (p *PartialTransaction) Finalize() (bb.Transaction, error) {
+	tx := p.Transaction.Clone()
+	if len(p.Inputs) != len(tx.TxIns()) {
+		return bb.Transaction{}, fmt.Errorf("%d inputs but %d locks", len(tx.TxIns()), len(p.Inputs))
+	}
+	for i, in := range p.Inputs {
+		m, pubs, err := in.multiSig()
+		if err != nil {
+			return bb.Transaction{}, fmt.Errorf("input %d: %v", i, err)
+		}
+		sigs := in.signatures(pubs)
+		if len(sigs) < m {
+			return bb.Transaction{}, fmt.Errorf("input %d has %d of %d signatures", i, len(sigs), m)
+		}
+		tx.SetUnlockingScript(i, bb.MultiSigUnlockingScript(sigs[:m]))
+	}
+	return tx, nil
+}
+
+func (in *PartialInput) multiSig() (int, []crypto.PublicKey, error) {
+	b, err := hex.DecodeString(in.LockingScript)
+	if err != nil {
+		return 0, nil, fmt.Errorf("locking script is not hex: %v", err)
+	}
+	m, pubs, ok := bb.Script(b).MultiSig()
+	if !ok {
+		return 0, nil, fmt.Errorf("%s is not a multisig lock", bb.Script(b))
+	}
+	return m, pubs, nil
+}
+
+// signatures returns the decodable signatures of in in the order of pubs, which OpCheckMultiSig expects.
+func (in *PartialInput) signatures(pubs []crypto.PublicKey) [][]byte {
+	var res [][]byte
+	for _, pub := range pubs {
+		if sig, err := hex.DecodeString(in.Signatures[bb.EncodePublicKey(pub)]); err == nil && len(sig) > 0 {
+			res = append(res, sig)
+		}
+	}
+	return res
+}
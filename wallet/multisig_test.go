@@ -0,0 +1,92 @@
+package wallet
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+)
+
+func TestMultiSigTransaction(t *testing.T) {
+	origParams := bb.Params
+	bb.Params.CoinbaseMaturity = 1
+	t.Cleanup(func() { bb.Params = origParams })
+
+	var keys []*ecdsa.PrivateKey
+	var pubs []crypto.PublicKey
+	for i := 0; i < 3; i++ {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys, pubs = append(keys, k), append(pubs, k.PublicKey)
+	}
+	lock, err := bb.PayToMultiSig(2, pubs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockChain := bb.BlockChain{bb.GenesisBlock}
+	blockChain = append(blockChain, bb.GenesisBlock.FindBlockWithTransactions([]byte{}, []bb.Transaction{bb.NewCoinbaseTransaction(keys[0].PublicKey, 1)}))
+	utxos, err := blockChain.UnspentTxOuts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fund, err := CreateTransaction(lock, 40, 1, keys[0], utxos)
+	if err != nil {
+		t.Fatalf("funding the multisig lock failed: %v", err)
+	}
+	coinbase, err := bb.NewCoinbaseTransactionWithFees(keys[0].PublicKey, 2, fund.Fee(utxos))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockChain = append(blockChain, blockChain[1].FindBlockWithTransactions([]byte{}, []bb.Transaction{coinbase, fund}))
+	if utxos, err = blockChain.UnspentTxOuts(); err != nil {
+		t.Fatal(err)
+	}
+
+	receiver := bb.PayToPubKeyHash(keys[2].PublicKey)
+	p, err := CreateMultiSigTransaction(lock, receiver, 30, 10, utxos)
+	if err != nil {
+		t.Fatalf("CreateMultiSigTransaction failed: %v", err)
+	}
+	if _, err := p.Finalize(); err == nil {
+		t.Errorf("Finalize succeeded without signatures")
+	}
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err := p.Sign(other); err == nil {
+		t.Errorf("a key that is not part of the lock signed")
+	}
+
+	// Co-signers pass the partial transaction around as JSON, and sign in any order.
+	for _, k := range []*ecdsa.PrivateKey{keys[2], keys[0]} {
+		b, err := json.Marshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p = &PartialTransaction{}
+		if err := json.Unmarshal(b, p); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Sign(k); err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+	}
+	if missing, err := p.Missing(); err != nil || len(missing) != 1 || missing[0] != 0 {
+		t.Errorf("Missing() = %v, %v; want [0]", missing, err)
+	}
+	tx, err := p.Finalize()
+	if err != nil {
+		t.Fatalf("Finalize failed: %v", err)
+	}
+	if fee := tx.Fee(utxos); fee < bb.FeeForSize(tx.Size(), 10) {
+		t.Errorf("fee %d is below the fee rate for %d bytes", fee, tx.Size())
+	}
+	if _, _, err := (bb.TransactionPool{}).Add(tx, utxos, blockChain.NextContext()); err != nil {
+		t.Errorf("multisig transaction was rejected: %v", err)
+	}
+}
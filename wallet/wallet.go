@@ -0,0 +1,202 @@
+package wallet
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+)
+
+// DefaultKeyPath is where the wallet keeps its private key unless told otherwise.
+var DefaultKeyPath = filepath.Join(os.Getenv("HOME"), ".naivecoin", "wallet.pem")
+
+// LoadOrCreateKey reads the PEM encoded private key at path, generating and saving a new P-256 key if the file does not exist yet.
+func LoadOrCreateKey(path string) (*ecdsa.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return createKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "EC PRIVATE KEY" {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded EC private key", path)
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+func createKey(path string) (*ecdsa.PrivateKey, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeKey(path, &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}, false); err != nil {
+		return nil, err
+	}
+	return privateKey, nil
+}
+
+// CreateKey generates a standalone private key of scheme, outside of any HD wallet, and saves it PEM encoded as PKCS #8 at path, which must not exist yet.
+func CreateKey(path string, scheme bb.SignatureScheme) (crypto.Signer, error) {
+	privateKey, err := scheme.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeKey(path, &pem.Block{Type: "PRIVATE KEY", Bytes: der}, true); err != nil {
+		return nil, err
+	}
+	return privateKey, nil
+}
+
+// LoadKey reads a private key saved by CreateKey or LoadOrCreateKey.
+func LoadKey(path string) (crypto.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded private key", path)
+	}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if _, supported := bb.SchemeOf(signer.Public()); !ok || !supported {
+			return nil, fmt.Errorf("%s holds a %T, not a P-256 ECDSA or Ed25519 key", path, key)
+		}
+		return signer, nil
+	}
+	return nil, fmt.Errorf("%s holds a PEM block of type %q, not a private key", path, block.Type)
+}
+
+// writeKey saves block at path, readable only by the user, refusing to overwrite an existing file if exclusive is set.
+func writeKey(path string, block *pem.Block, exclusive bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if exclusive {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, block); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Balance sums the amounts of all unspent outputs locked to address.
+func Balance(address crypto.PublicKey, aUnspentTxOuts []bb.UnspentTxOut) int32 {
+	var balance int32
+	for _, utxo := range mine(bb.PayToPubKeyHash(address), aUnspentTxOuts) {
+		balance += utxo.Amount()
+	}
+	return balance
+}
+
+// findTxOutsForAmount picks unspent outputs until they cover amount, and returns them together with the change that has to be sent back. It sums in int64, as the outputs together may hold more than an int32; the change is less than the last output picked, so it fits in one.
+func findTxOutsForAmount(amount int64, myUnspentTxOuts []bb.UnspentTxOut) ([]bb.UnspentTxOut, int32, error) {
+	var currentAmount int64
+	var included []bb.UnspentTxOut
+	for _, utxo := range myUnspentTxOuts {
+		included = append(included, utxo)
+		currentAmount += int64(utxo.Amount())
+		if currentAmount >= amount {
+			return included, int32(currentAmount - amount), nil
+		}
+	}
+	return nil, 0, fmt.Errorf("cannot send %d coins, only %d available", amount, currentAmount)
+}
+
+// CreateTransaction builds and signs a transaction sending amount coins to receiver, a locking script, spending outputs locked to privateKey, an ECDSA or Ed25519 key, and sending any change back to it. It pays a fee of feeRate coins per 1000 bytes of the transaction.
+func CreateTransaction(receiver bb.Script, amount int32, feeRate int64, privateKey crypto.Signer, aUnspentTxOuts []bb.UnspentTxOut) (bb.Transaction, error) {
+	return createTransaction(receiver, bb.PayToPubKeyHash(privateKey.Public()), amount, feeRate, []crypto.Signer{privateKey}, aUnspentTxOuts)
+}
+
+// createTransaction is CreateTransaction for outputs locked to any of keys, sending change to change.
+func createTransaction(receiver, change bb.Script, amount int32, feeRate int64, keys []crypto.Signer, aUnspentTxOuts []bb.UnspentTxOut) (bb.Transaction, error) {
+	var myUnspentTxOuts []bb.UnspentTxOut
+	owner := map[string]crypto.Signer{} // by locking script
+	for _, k := range keys {
+		lock := bb.PayToPubKeyHash(k.Public())
+		owner[string(lock)] = k
+		myUnspentTxOuts = append(myUnspentTxOuts, mine(lock, aUnspentTxOuts)...)
+	}
+	return buildTransaction(receiver, change, amount, feeRate, myUnspentTxOuts, func(tx *bb.Transaction, included []bb.UnspentTxOut) error {
+		for i, utxo := range included {
+			if err := tx.SignTxIn(i, owner[string(utxo.LockingScript())], included); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// mine returns the outputs of aUnspentTxOuts locked with lock.
+func mine(lock bb.Script, aUnspentTxOuts []bb.UnspentTxOut) []bb.UnspentTxOut {
+	var res []bb.UnspentTxOut
+	for _, utxo := range aUnspentTxOuts {
+		if utxo.LockingScript().Equal(lock) {
+			res = append(res, utxo)
+		}
+	}
+	return res
+}
+
+// buildTransaction builds a transaction sending amount coins to receiver, spending myUnspentTxOuts and sending any change to change. unlock sets the unlocking scripts of the inputs, which count towards the size that the fee at feeRate is paid for.
+func buildTransaction(receiver, change bb.Script, amount int32, feeRate int64, myUnspentTxOuts []bb.UnspentTxOut, unlock func(*bb.Transaction, []bb.UnspentTxOut) error) (bb.Transaction, error) {
+	if amount <= 0 {
+		return bb.Transaction{}, fmt.Errorf("amount must be positive, got %d", amount)
+	}
+	// The fee depends on the size, which depends on how many outputs it takes to cover the fee and on the unlocking scripts. The fee only grows, so this settles quickly.
+	var fee int64
+	for {
+		included, leftOver, err := findTxOutsForAmount(int64(amount)+fee, myUnspentTxOuts)
+		if err != nil {
+			return bb.Transaction{}, err
+		}
+		var txIns []bb.TxIn
+		for _, utxo := range included {
+			txIns = append(txIns, bb.NewTxIn(utxo.TxOutID(), utxo.TxOutIndex()))
+		}
+		txOuts := []bb.TxOut{bb.NewTxOutWithScript(receiver, amount)}
+		if leftOver > 0 {
+			txOuts = append(txOuts, bb.NewTxOutWithScript(change, leftOver))
+		}
+		tx := bb.NewTransaction(txIns, txOuts)
+		if err := unlock(&tx, included); err != nil {
+			return bb.Transaction{}, err
+		}
+		if required := bb.FeeForSize(tx.Size(), feeRate); fee < required {
+			fee = required
+			continue
+		}
+		return tx, nil
+	}
+}
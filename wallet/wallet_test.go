@@ -0,0 +1,139 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+
+	bb "github.com/chronologos/naivecoin/basicblock"
+)
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.pem")
+	created, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey failed: %v", err)
+	}
+	loaded, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey failed: %v", err)
+	}
+	if !created.Equal(loaded) {
+		t.Errorf("loaded key differs from the created one")
+	}
+}
+
+func TestCreateKey(t *testing.T) {
+	origParams := bb.Params
+	bb.Params.CoinbaseMaturity = 1
+	t.Cleanup(func() { bb.Params = origParams })
+	for _, scheme := range []bb.SignatureScheme{bb.ECDSA, bb.Ed25519} {
+		path := filepath.Join(t.TempDir(), "key.pem")
+		created, err := CreateKey(path, scheme)
+		if err != nil {
+			t.Fatalf("CreateKey(%s) failed: %v", scheme, err)
+		}
+		if _, err := CreateKey(path, scheme); err == nil {
+			t.Errorf("CreateKey(%s) overwrote an existing key", scheme)
+		}
+		loaded, err := LoadKey(path)
+		if err != nil {
+			t.Fatalf("LoadKey(%s) failed: %v", scheme, err)
+		}
+		if got, _ := bb.SchemeOf(loaded.Public()); got != scheme || bb.EncodePublicKey(loaded.Public()) != bb.EncodePublicKey(created.Public()) {
+			t.Errorf("loaded %s key differs from the created one", scheme)
+		}
+
+		blockChain := bb.BlockChain{bb.GenesisBlock}
+		blockChain = append(blockChain, bb.GenesisBlock.FindBlockWithTransactions([]byte{}, []bb.Transaction{bb.NewCoinbaseTransaction(loaded.Public(), 1)}))
+		utxos, err := blockChain.UnspentTxOuts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx, err := CreateTransaction(bb.PayToPubKeyHash(created.Public()), 20, 1, loaded, utxos)
+		if err != nil {
+			t.Fatalf("CreateTransaction with a %s key failed: %v", scheme, err)
+		}
+		if _, _, err := (bb.TransactionPool{}).Add(tx, utxos, blockChain.NextContext()); err != nil {
+			t.Errorf("transaction signed with a %s key was rejected: %v", scheme, err)
+		}
+	}
+	if _, err := LoadKey(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Errorf("LoadKey of a missing file succeeded")
+	}
+}
+
+func TestCreateTransaction(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origParams := bb.Params
+	bb.Params.CoinbaseMaturity = 1
+	t.Cleanup(func() { bb.Params = origParams })
+	blockChain := bb.BlockChain{bb.GenesisBlock}
+	blockChain = append(blockChain, bb.GenesisBlock.FindBlockWithTransactions([]byte{}, []bb.Transaction{bb.NewCoinbaseTransaction(privateKey.PublicKey, 1)}))
+	utxos, err := blockChain.UnspentTxOuts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CreateTransaction(bb.PayToPubKeyHash(receiver.PublicKey), bb.CoinbaseAmount, 1, privateKey, utxos); err == nil {
+		t.Errorf("CreateTransaction spent more than the balance")
+	}
+	tx, err := CreateTransaction(bb.PayToPubKeyHash(receiver.PublicKey), 20, 10, privateKey, utxos)
+	if err != nil {
+		t.Fatalf("CreateTransaction failed: %v", err)
+	}
+	fee := tx.Fee(utxos)
+	if want := bb.FeeForSize(tx.Size(), 10); fee < want || fee > want+1 { // signatures vary in length by a byte or two
+		t.Errorf("fee = %d, want %d", fee, want)
+	}
+	if _, _, err := (bb.TransactionPool{}).Add(tx, utxos, blockChain.NextContext()); err != nil {
+		t.Errorf("created transaction was rejected: %v", err)
+	}
+
+	coinbase, err := bb.NewCoinbaseTransactionWithFees(privateKey.PublicKey, 2, fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockChain = append(blockChain, blockChain[1].FindBlockWithTransactions([]byte{}, []bb.Transaction{coinbase, tx}))
+	utxos, err = blockChain.UnspentTxOuts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := Balance(receiver.PublicKey, utxos); b != 20 {
+		t.Errorf("receiver balance = %d, want 20", b)
+	}
+	if b := Balance(privateKey.PublicKey, utxos); b != 2*bb.CoinbaseAmount-20 {
+		t.Errorf("sender balance = %d, want %d, the fee went to its own coinbase", b, 2*bb.CoinbaseAmount-20)
+	}
+}
+
+func TestFindTxOutsForAmountDoesNotOverflow(t *testing.T) {
+	var utxos []bb.UnspentTxOut
+	for i := 1; i <= 2; i++ {
+		var utxo bb.UnspentTxOut
+		w := fmt.Sprintf(`{"txOutId":"%064x","txOutIndex":0,"script":"","amount":%d,"blockHeight":1}`, i, math.MaxInt32-10)
+		if err := json.Unmarshal([]byte(w), &utxo); err != nil {
+			t.Fatal(err)
+		}
+		utxos = append(utxos, utxo)
+	}
+	included, change, err := findTxOutsForAmount(math.MaxInt32, utxos)
+	if err != nil {
+		t.Fatalf("findTxOutsForAmount failed although the outputs cover the amount: %v", err)
+	}
+	if len(included) != 2 || change != math.MaxInt32-20 {
+		t.Errorf("picked %d outputs with change %d, want 2 with change %d", len(included), change, math.MaxInt32-20)
+	}
+}